@@ -2,84 +2,280 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/seanankenbruck/blog/internal/auth"
+	"github.com/seanankenbruck/blog/internal/config"
 	"github.com/seanankenbruck/blog/internal/content"
+	"github.com/seanankenbruck/blog/internal/database"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/feed"
 	"github.com/seanankenbruck/blog/internal/handler"
+	"github.com/seanankenbruck/blog/internal/markdown"
 	"github.com/seanankenbruck/blog/internal/repository"
+	"github.com/seanankenbruck/blog/internal/server"
 	"github.com/seanankenbruck/blog/internal/service"
+	"github.com/seanankenbruck/blog/internal/sitemap"
 )
 
 func main() {
 	// Load .env file if it exists (ignore error if not found)
 	_ = godotenv.Load()
 
+	// Defaults, then CONFIG_FILE or -config, then the environment, then flags
+	cfg, err := config.Load(os.Args[1:]...)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	gin.SetMode(cfg.GinMode)
+	if gin.IsDebugging() {
+		log.Printf("Effective configuration:\n%s", cfg)
+	}
+
+	// SIGTERM from Kubernetes or SIGINT from Ctrl-C starts a graceful
+	// shutdown. ctx is shared with the background goroutines, which stop
+	// when it is cancelled; background waits for them.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
+
 	// Initialize router
 	r := gin.Default()
 
-	// Set up static file serving
-	r.Static("/static", "./static")
-
 	// Set up templates
-	if err := handler.SetupTemplates(r); err != nil {
+	if err := handler.SetupTemplates(r, cfg.TemplatesDir); err != nil {
 		log.Fatalf("Failed to set up templates: %v", err)
 	}
 
-	// Determine content directory path
-	contentDir := os.Getenv("CONTENT_DIR")
-	if contentDir == "" {
-		// Try container path first, then fall back to local development path
-		if _, err := os.Stat("/content/posts"); err == nil {
-			contentDir = "/content/posts"
-		} else {
-			contentDir = "content/posts"
-		}
+	// Client IPs, used for rate limiting, are only read from X-Forwarded-For
+	// when the request comes from one of the trusted proxies
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
 	}
 
+	// Security headers and the CSP nonce for every response; /admin
+	// replaces the policy with a stricter one
+	publicPolicy := handler.DefaultSecurityPolicy()
+	publicPolicy.HSTSMaxAge = cfg.HSTSMaxAge
+	r.Use(handler.SecurityHeaders(publicPolicy))
+
+	// Set up static file serving
+	r.Static("/static", cfg.StaticDir)
+
 	// Determine if we're in development mode
 	isDev := gin.Mode() == gin.DebugMode
 
-	// Initialize content loader
-	content.Init(contentDir, isDev)
-	if err := content.LoadPosts(); err != nil {
-		log.Fatalf("Failed to load posts: %v", err)
+	// Posts, the SQL store and the editor preview share one markdown renderer
+	// so previews match published output. /preview is public, so its output
+	// is sanitized with the strict policy rather than the trusted one.
+	renderer := markdown.New(markdown.DefaultExtensions)
+	highlightCSS, err := markdown.HighlightCSS(markdown.DefaultLightStyle, markdown.DefaultDarkStyle)
+	if err != nil {
+		log.Fatalf("Failed to generate highlight stylesheet: %v", err)
 	}
-	log.Printf("Loaded posts from %s", contentDir)
-
 
 	// Initialize repositories
-	postRepo := repository.NewFilePostRepository()
+	var postRepo domain.PostRepository
+	var userRepo domain.UserRepository
+	var db *sql.DB
+	// Validate has checked PostStore is "file" or "sql"
+	switch cfg.PostStore {
+	case "file":
+		postRepo = setupFileRepository(ctx, &background, cfg, renderer, isDev)
+		userRepo = repository.NewFileUserRepository(cfg.UsersFile)
+	case "sql":
+		var dialect database.Dialect
+		db, dialect = setupDatabase(cfg)
+		sqlRepo := repository.NewSQLPostRepository(db, dialect, renderer, isDev)
+		if err := sqlRepo.RenderOutlines(ctx); err != nil {
+			log.Fatalf("Failed to render post outlines: %v", err)
+		}
+		postRepo = sqlRepo
+		userRepo = repository.NewSQLUserRepository(db, dialect)
+	}
 
 	// Initialize services
 	postService := service.NewPostService(postRepo)
+	userService := service.NewUserService(userRepo)
+	if cfg.EnableAdmin {
+		bootstrapAdmin(userService, cfg)
+	}
+
+	// Absolute URLs in feeds and the sitemap are built from BASE_URL
+	baseURL := cfg.BaseURL
 
 	// Initialize handlers
-	postHandler := handler.NewPostHandler(postService)
+	postHandler := handler.NewPostHandler(postService, renderer)
+	feedHandler := handler.NewFeedHandler(postService, feed.Site{
+		Title:       "Sean Ankenbruck",
+		Description: "Posts on software development, observability and infrastructure",
+		BaseURL:     baseURL,
+		Author:      "Sean Ankenbruck",
+		Language:    "en-us",
+	})
+	sitemapHandler := handler.NewSitemapHandler(postService, baseURL, []sitemap.Page{
+		{Path: "/", ChangeFreq: "monthly"},
+		{Path: "/posts", ChangeFreq: "weekly", LatestPost: true},
+		{Path: "/tags", ChangeFreq: "weekly", LatestPost: true},
+		{Path: "/portfolio", ChangeFreq: "monthly"},
+	}, sitemap.RobotsConfig{
+		DisallowAll: cfg.RobotsDisallowAll,
+		Disallow:    cfg.RobotsDisallow,
+	})
 
 	// Set up routes
-	setupRoutes(r, postHandler)
+	readiness := &server.Readiness{}
+	setupRoutes(r, cfg, readiness, postHandler, feedHandler, sitemapHandler)
+	r.GET("/highlight.css", handler.Stylesheet(highlightCSS))
+
+	if cfg.EnableAdmin {
+		authHandler := handler.NewAuthHandler(userService, setupSessions(cfg), cfg.SessionCookieSecure, cfg.AdminToken)
+		setupAdminRoutes(r, cfg, handler.NewAdminHandler(postService, renderer), authHandler)
+	}
 
 	// Start server
-	if err := r.Run(":8080"); err != nil {
+	srv := server.New(r, cfg)
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+	log.Printf("Listening on %s", srv.Addr)
+
+	// A second signal exits at once instead of waiting for the drain
+	context.AfterFunc(ctx, stop)
+	err = server.Serve(ctx, srv, ln, readiness, cfg.ShutdownDelay, cfg.ShutdownTimeout)
+
+	// Stop the background goroutines too if the server failed by itself
+	stop()
+	background.Wait()
+	if db != nil {
+		db.Close()
+	}
+	if err != nil {
+		log.Fatalf("Server stopped: %v", err)
+	}
+}
+
+// setupFileRepository loads posts from the markdown content directory and,
+// where enabled, watches it for changes until ctx is cancelled
+func setupFileRepository(ctx context.Context, background *sync.WaitGroup, cfg *config.Config, renderer *markdown.Renderer, isDev bool) *repository.FilePostRepository {
+	contentDir := cfg.ContentDir
+
+	// Initialize content store
+	store := content.NewContentStore(contentDir, isDev)
+	store.SetRenderer(renderer)
+
+	// Front matter dates without a zone offset are interpreted in CONTENT_TIMEZONE
+	if cfg.ContentTimezone != "" {
+		loc, err := time.LoadLocation(cfg.ContentTimezone)
+		if err != nil {
+			log.Fatalf("Invalid CONTENT_TIMEZONE %q: %v", cfg.ContentTimezone, err)
+		}
+		store.SetLocation(loc)
+	}
+
+	if err := store.LoadPosts(); err != nil {
+		log.Fatalf("Failed to load posts: %v", err)
+	}
+	log.Printf("Loaded posts from %s", contentDir)
+
+	// Hot-reload posts in development, or when explicitly enabled for
+	// deployments that mount content from a ConfigMap
+	if isDev || cfg.ContentWatch {
+		watcher, err := content.NewWatcher(store, content.DefaultDebounce)
+		if err != nil {
+			log.Fatalf("Failed to watch content directory: %v", err)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			if err := watcher.Run(ctx); err != nil {
+				log.Printf("Content watcher stopped: %v", err)
+			}
+		}()
+		log.Printf("Watching %s for changes", contentDir)
+	}
+
+	return repository.NewFilePostRepository(store)
 }
 
-func setupRoutes(r *gin.Engine, postHandler *handler.PostHandler) {
+// setupDatabase connects to the configured database and migrates its schema
+func setupDatabase(cfg *config.Config) (*sql.DB, database.Dialect) {
+	ctx := context.Background()
+	db, dialect, err := database.Open(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	if err := database.Migrate(ctx, db, dialect); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	log.Printf("Serving posts from %s database", dialect)
+
+	return db, dialect
+}
+
+// setupSessions creates the session cookie signer from SESSION_SECRET
+func setupSessions(cfg *config.Config) *auth.Sessions {
+	key := []byte(cfg.SessionSecret)
+	if len(key) == 0 {
+		var err error
+		if key, err = auth.RandomKey(); err != nil {
+			log.Fatalf("Failed to generate session key: %v", err)
+		}
+		// Validate only allows this in debug mode
+		log.Println("SESSION_SECRET not set; admin sessions will end when the server restarts")
+	}
+	return auth.NewSessions(key, cfg.SessionTTL)
+}
+
+// bootstrapAdmin creates the ADMIN_USERNAME user on first start, so there is
+// someone who can sign in
+func bootstrapAdmin(userService domain.UserService, cfg *config.Config) {
+	if cfg.AdminUsername == "" {
+		return
+	}
+	_, err := userService.Register(context.Background(), cfg.AdminUsername, cfg.AdminPassword)
+	switch {
+	case err == nil:
+		log.Printf("Created admin user %s", cfg.AdminUsername)
+	case errors.Is(err, domain.ErrUserExists):
+		// Already bootstrapped; the stored password wins
+	default:
+		log.Fatalf("Failed to create admin user %s: %v", cfg.AdminUsername, err)
+	}
+}
+
+func setupRoutes(r *gin.Engine, cfg *config.Config, readiness *server.Readiness, postHandler *handler.PostHandler, feedHandler *handler.FeedHandler, sitemapHandler *handler.SitemapHandler) {
 	// Add context timeout middleware
 	r.Use(func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.RequestTimeout)
 		defer cancel()
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	})
 
+	// Cap every request body; routes can lower the cap further
+	r.Use(handler.BodyLimit(cfg.MaxBodyBytes))
+
 
 	// Custom 404 handler for nonexistent routes
 	r.NoRoute(func(c *gin.Context) {
@@ -98,10 +294,69 @@ func setupRoutes(r *gin.Engine, postHandler *handler.PostHandler) {
 		public.GET("/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 		})
+		// Readiness fails once shutdown starts, so traffic moves to other
+		// replicas before connections are drained
+		public.GET("/ready", func(c *gin.Context) {
+			if !readiness.Ready() {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"status": "ready"})
+		})
 		public.GET("/posts", postHandler.GetPosts)
 		public.GET("/posts/:slug", postHandler.GetPost)
+		public.GET("/search", postHandler.Search)
+		public.GET("/tags", postHandler.GetTags)
+		public.GET("/tags/:tag", postHandler.GetTagPosts)
+		public.GET("/tags/:tag/feed.xml", feedHandler.TagRSS)
+		public.GET("/series/:name", postHandler.GetSeries)
+		public.GET("/feed.xml", feedHandler.RSS)
+		public.GET("/atom.xml", feedHandler.Atom)
+		public.GET("/sitemap.xml", sitemapHandler.Sitemap)
+		public.GET("/sitemaps/:page", sitemapHandler.SitemapPage)
+		public.GET("/robots.txt", sitemapHandler.Robots)
 		public.GET("/portfolio", handler.PortfolioPage())
-		public.POST("/preview", postHandler.PreviewMarkdown())
+		if cfg.EnablePreview {
+			// Anyone can render markdown, so limit how often and how much
+			previewLimiter := handler.NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+			public.POST("/preview", previewLimiter.Limit, handler.BodyLimit(cfg.PreviewMaxBodyBytes), postHandler.PreviewMarkdown())
+		}
 	}
 
-}
\ No newline at end of file
+}
+
+func setupAdminRoutes(r *gin.Engine, cfg *config.Config, adminHandler *handler.AdminHandler, authHandler *handler.AuthHandler) {
+	adminPolicy := handler.AdminSecurityPolicy()
+	adminPolicy.HSTSMaxAge = cfg.HSTSMaxAge
+	adminRoutes := r.Group("/admin", handler.SecurityHeaders(adminPolicy))
+
+	// Rate limiting sign-in slows down password guessing
+	loginLimiter := handler.NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+	adminRoutes.GET("/login", authHandler.LoginPage)
+	adminRoutes.POST("/login", loginLimiter.Limit, authHandler.Login)
+	adminRoutes.POST("/logout", authHandler.Logout)
+
+	// Everything else under /admin requires a session
+	admin := adminRoutes.Group("", authHandler.RequireAuth)
+	{
+		admin.GET("", adminHandler.Dashboard)
+		admin.GET("/posts/new", adminHandler.NewPostPage)
+		admin.POST("/posts", adminHandler.SaveNewPost)
+		admin.GET("/posts/:slug/edit", adminHandler.EditPostPage)
+		admin.POST("/posts/:slug", adminHandler.SavePost)
+		admin.POST("/posts/:slug/delete", adminHandler.DeletePostForm)
+		// The editor's preview renders as published posts are, unlike the
+		// public /preview, and works with ENABLE_PREVIEW off
+		admin.POST("/preview", handler.BodyLimit(cfg.PreviewMaxBodyBytes), adminHandler.Preview)
+
+		api := admin.Group("/api")
+		api.GET("/posts", adminHandler.ListPosts)
+		api.POST("/posts", adminHandler.CreatePost)
+		api.GET("/posts/:slug", adminHandler.GetPost)
+		api.PUT("/posts/:slug", adminHandler.UpdatePost)
+		api.DELETE("/posts/:slug", adminHandler.DeletePost)
+		api.POST("/posts/:slug/publish", adminHandler.PublishPost)
+		api.GET("/users", authHandler.ListUsers)
+		api.POST("/users", authHandler.CreateUser)
+	}
+}
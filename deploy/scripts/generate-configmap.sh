@@ -21,6 +21,18 @@ source .env
 SERVER_PORT="${SERVER_PORT:-8080}"
 GIN_MODE="${GIN_MODE:-release}"
 CONTENT_DIR="${CONTENT_DIR:-/content/posts}"
+CONTENT_WATCH="${CONTENT_WATCH:-false}"
+BASE_URL="${BASE_URL:-https://${APP_DOMAIN}}"
+ROBOTS_DISALLOW="${ROBOTS_DISALLOW:-/admin,/preview,/search}"
+ROBOTS_DISALLOW_ALL="${ROBOTS_DISALLOW_ALL:-false}"
+TRUSTED_PROXIES="${TRUSTED_PROXIES:-}"
+RATE_LIMIT_PER_MINUTE="${RATE_LIMIT_PER_MINUTE:-30}"
+RATE_LIMIT_BURST="${RATE_LIMIT_BURST:-10}"
+MAX_BODY_BYTES="${MAX_BODY_BYTES:-1048576}"
+PREVIEW_MAX_BODY_BYTES="${PREVIEW_MAX_BODY_BYTES:-262144}"
+HSTS_MAX_AGE="${HSTS_MAX_AGE:-8760h}"
+SHUTDOWN_DELAY="${SHUTDOWN_DELAY:-5s}"
+SHUTDOWN_TIMEOUT="${SHUTDOWN_TIMEOUT:-15s}"
 
 # Generate configmap YAML with values
 cat > deploy/manifests/configmaps/generated-configmap.yaml << EOF
@@ -34,6 +46,18 @@ data:
   SERVER_PORT: "${SERVER_PORT}"
   GIN_MODE: "${GIN_MODE}"
   CONTENT_DIR: "${CONTENT_DIR}"
+  CONTENT_WATCH: "${CONTENT_WATCH}"
+  BASE_URL: "${BASE_URL}"
+  ROBOTS_DISALLOW: "${ROBOTS_DISALLOW}"
+  ROBOTS_DISALLOW_ALL: "${ROBOTS_DISALLOW_ALL}"
+  TRUSTED_PROXIES: "${TRUSTED_PROXIES}"
+  RATE_LIMIT_PER_MINUTE: "${RATE_LIMIT_PER_MINUTE}"
+  RATE_LIMIT_BURST: "${RATE_LIMIT_BURST}"
+  MAX_BODY_BYTES: "${MAX_BODY_BYTES}"
+  PREVIEW_MAX_BODY_BYTES: "${PREVIEW_MAX_BODY_BYTES}"
+  HSTS_MAX_AGE: "${HSTS_MAX_AGE}"
+  SHUTDOWN_DELAY: "${SHUTDOWN_DELAY}"
+  SHUTDOWN_TIMEOUT: "${SHUTDOWN_TIMEOUT}"
 EOF
 
 echo "✅ Generated deploy/manifests/configmaps/generated-configmap.yaml"
@@ -1,5 +1,5 @@
 #!/bin/bash
-# scripts/generate-secrets.sh - Secret generation for static content blog
+# scripts/generate-secrets.sh - Secret generation for the blog
 
 set -e
 
@@ -17,19 +17,24 @@ fi
 # Source environment variables
 source .env
 
-# Generate empty secrets YAML (no secrets needed for static content)
+# The admin pages need a session secret shared by every replica
+if [ -z "${SESSION_SECRET}" ]; then
+    echo "❌ SESSION_SECRET is not set in .env"
+    echo "Generate one with: openssl rand -hex 32"
+    exit 1
+fi
+
+# Generate secrets YAML with values
 cat > deploy/manifests/secrets/generated-secrets.yaml << EOF
-# Generated for static content blog on $(date)
-# No secrets required for static content deployment
+# Generated from .env file on $(date)
 apiVersion: v1
 kind: Secret
 metadata:
   name: blog-secrets
   namespace: blog-app
 type: Opaque
-data:
-  # No secrets needed for static content blog
-  # This file is kept for future extensibility
+stringData:
+  SESSION_SECRET: "${SESSION_SECRET}"
 EOF
 
 # Create template file for reference (safe to commit)
@@ -42,12 +47,13 @@ metadata:
   name: blog-secrets
   namespace: blog-app
 type: Opaque
-data:
-  # No secrets needed for static content blog
-  # This file is kept for future extensibility
+stringData:
+  # Signs admin session cookies; at least 32 characters, the same on every
+  # replica, e.g. from `openssl rand -hex 32`
+  SESSION_SECRET: ""
 EOF
 
 echo "✅ Generated deploy/manifests/secrets/generated-secrets.yaml"
 echo "ℹ️  Template available at deploy/manifests/secrets/app-secrets-template.yaml"
 echo ""
-echo "ℹ️  No secrets required for static content blog deployment"
\ No newline at end of file
+echo "ℹ️  The secret holds the admin session key"
\ No newline at end of file
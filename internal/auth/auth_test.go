@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswords(t *testing.T) {
+	if _, err := HashPassword("short"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("HashPassword() expected ErrWeakPassword, got %v", err)
+	}
+	if _, err := HashPassword(strings.Repeat("a", MaxPasswordLength+1)); !errors.Is(err, ErrLongPassword) {
+		t.Errorf("HashPassword() expected ErrLongPassword, got %v", err)
+	}
+
+	hash, err := HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$2a$") {
+		t.Errorf("Expected a bcrypt hash, got %q", hash)
+	}
+	if !CheckPassword(hash, "correct horse battery") {
+		t.Error("CheckPassword() rejected the correct password")
+	}
+	if CheckPassword(hash, "correct horse battery!") {
+		t.Error("CheckPassword() accepted a wrong password")
+	}
+	if CheckPassword("not a hash", "correct horse battery") {
+		t.Error("CheckPassword() accepted a malformed hash")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sessions := NewSessions([]byte(strings.Repeat("k", 32)), time.Hour)
+	sessions.now = func() time.Time { return now }
+
+	value, err := sessions.New("admin", "stamp")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	t.Run("Valid session", func(t *testing.T) {
+		session, err := sessions.Verify(value)
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+		if session.Username != "admin" || session.Stamp != "stamp" || !session.ExpiresAt.Equal(now.Add(time.Hour)) {
+			t.Errorf("Unexpected session %+v", session)
+		}
+	})
+
+	t.Run("Tampered sessions are rejected", func(t *testing.T) {
+		payload, sig, _ := strings.Cut(value, ".")
+		forged, _ := NewSessions([]byte(strings.Repeat("x", 32)), time.Hour).New("admin", "stamp")
+
+		for _, v := range []string{
+			"",
+			payload,
+			payload + ".",
+			payload + "." + sig + "x",
+			"eyJ1IjoiZXZlIn0." + sig, // {"u":"eve"} with admin's signature
+			forged,
+		} {
+			if _, err := sessions.Verify(v); !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("Verify(%q) expected ErrInvalidSession, got %v", v, err)
+			}
+		}
+	})
+
+	t.Run("Expired sessions are rejected", func(t *testing.T) {
+		sessions.now = func() time.Time { return now.Add(time.Hour) }
+		defer func() { sessions.now = func() time.Time { return now } }()
+
+		if _, err := sessions.Verify(value); !errors.Is(err, ErrInvalidSession) {
+			t.Errorf("Verify() expected ErrInvalidSession for expired session, got %v", err)
+		}
+	})
+}
+
+func TestCSRFTokens(t *testing.T) {
+	sessions := NewSessions([]byte(strings.Repeat("k", 32)), time.Hour)
+	first, _ := sessions.New("admin", "stamp")
+	second, _ := sessions.New("admin", "stamp")
+
+	token := sessions.CSRFToken(first)
+	if !sessions.CheckCSRF(first, token) {
+		t.Error("CheckCSRF() rejected the session's token")
+	}
+	for _, tc := range []struct{ value, token string }{
+		{first, ""},
+		{first, token + "x"},
+		{second, token},
+	} {
+		if sessions.CheckCSRF(tc.value, tc.token) {
+			t.Errorf("CheckCSRF() accepted token %q", tc.token)
+		}
+	}
+}
+
+func TestStamp(t *testing.T) {
+	stamp := Stamp("hash", 0)
+	if stamp != Stamp("hash", 0) {
+		t.Error("Stamp() is not deterministic")
+	}
+	if stamp == Stamp("other-hash", 0) || stamp == Stamp("hash", 1) {
+		t.Error("Stamp() did not change with the password hash or session version")
+	}
+}
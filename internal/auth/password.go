@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password HashPassword accepts
+const MinPasswordLength = 12
+
+// MaxPasswordLength is the longest password HashPassword accepts, in bytes;
+// bcrypt ignores anything longer
+const MaxPasswordLength = 72
+
+// ErrWeakPassword is returned for passwords shorter than MinPasswordLength
+var ErrWeakPassword = errors.New("password must be at least 12 characters")
+
+// ErrLongPassword is returned for passwords longer than MaxPasswordLength
+var ErrLongPassword = errors.New("password must be at most 72 bytes")
+
+// HashPassword hashes a password with bcrypt
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+	if len(password) > MaxPasswordLength {
+		return "", ErrLongPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
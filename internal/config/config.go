@@ -1,34 +1,377 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the server's configuration. Load fills it from, in increasing
+// precedence, the defaults, a YAML file, environment variables and
+// command-line flags. YAML keys are the lowercase environment variable
+// names, e.g. server_port for SERVER_PORT.
 type Config struct {
-	DBHost     string
-	DBPort     int
-	DBUser     string
-	DBPassword string
-	DBName     string
-	ServerPort string
-	OTLPEndpoint string
-}
-
-func Load() (*Config, error) {
-	config := &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnvAsInt("DB_PORT", 5432),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "postgres"),
-		DBName:     getEnv("DB_NAME", "blog"),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		OTLPEndpoint: getEnv("OTLP_ENDPOINT", "http://localhost:4318"),
+	// ConfigFile is the YAML file read, from CONFIG_FILE or -config
+	ConfigFile string `yaml:"-"`
+
+	DBHost     string `yaml:"db_host"`
+	DBPort     int    `yaml:"db_port"`
+	DBUser     string `yaml:"db_user"`
+	DBPassword string `yaml:"db_password"`
+	DBName     string `yaml:"db_name"`
+	// DBDriver selects the SQL database: "postgres" or "sqlite"
+	DBDriver string `yaml:"db_driver"`
+	// DBPath is the SQLite database file
+	DBPath    string `yaml:"db_path"`
+	DBSSLMode string `yaml:"db_sslmode"`
+	// PostStore selects where posts are read from: "file" (markdown in
+	// ContentDir) or "sql"
+	PostStore string `yaml:"post_store"`
+	// GinMode is "debug", "release" or "test". Debug mode reloads templates
+	// and content and lists drafts.
+	GinMode string `yaml:"gin_mode"`
+
+	// ServerHost and ServerPort are the address the server listens on; an
+	// empty host listens on all interfaces
+	ServerHost   string `yaml:"server_host"`
+	ServerPort   string `yaml:"server_port"`
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	BaseURL      string `yaml:"base_url"`
+	// RequestTimeout bounds the context of every request
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout are the
+	// http.Server timeouts, and MaxHeaderBytes its request header limit
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	// On SIGTERM or SIGINT readiness fails for ShutdownDelay, then
+	// in-flight requests get ShutdownTimeout to finish. Together they
+	// should stay under the pod's termination grace period.
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// TemplatesDir and StaticDir hold the HTML templates and the files
+	// served under /static
+	TemplatesDir string `yaml:"templates_dir"`
+	StaticDir    string `yaml:"static_dir"`
+
+	// ContentDir holds the markdown posts when PostStore is "file"
+	ContentDir string `yaml:"content_dir"`
+	// ContentTimezone is the IANA zone of front matter dates without an
+	// offset; empty means UTC
+	ContentTimezone string `yaml:"content_timezone"`
+	// ContentWatch reloads posts when ContentDir changes. It is always on
+	// in debug mode.
+	ContentWatch bool `yaml:"content_watch"`
+
+	// EnableAdmin serves the /admin pages and API, and EnablePreview the
+	// public markdown preview used by the editor
+	EnableAdmin   bool `yaml:"enable_admin"`
+	EnablePreview bool `yaml:"enable_preview"`
+
+	// RobotsDisallow lists path prefixes disallowed in robots.txt
+	RobotsDisallow []string `yaml:"robots_disallow"`
+	// RobotsDisallowAll blocks all crawlers, e.g. on staging
+	RobotsDisallowAll bool `yaml:"robots_disallow_all"`
+	// AdminToken is an optional bearer token accepted by the /admin/api
+	// endpoints alongside session cookies, for scripts
+	AdminToken string `yaml:"admin_token"`
+	// UsersFile stores admin users when PostStore is "file"
+	UsersFile string `yaml:"users_file"`
+	// AdminUsername and AdminPassword create the first admin user at
+	// startup if it doesn't exist yet
+	AdminUsername string `yaml:"admin_username"`
+	AdminPassword string `yaml:"admin_password"`
+	// SessionSecret signs session cookies. If empty a random key is used,
+	// so sessions end when the server restarts.
+	SessionSecret string        `yaml:"session_secret"`
+	SessionTTL    time.Duration `yaml:"session_ttl"`
+	// SessionCookieSecure marks session cookies Secure (HTTPS only)
+	SessionCookieSecure bool `yaml:"session_cookie_secure"`
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For
+	// header is believed when identifying clients. Empty trusts none.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// RateLimitPerMinute and RateLimitBurst limit each client IP on the
+	// public POST endpoints; a RateLimitPerMinute of 0 disables the limit
+	RateLimitPerMinute int `yaml:"rate_limit_per_minute"`
+	RateLimitBurst     int `yaml:"rate_limit_burst"`
+	// MaxBodyBytes caps every request body, and PreviewMaxBodyBytes the
+	// markdown posted to /preview; 0 disables a cap
+	MaxBodyBytes        int `yaml:"max_body_bytes"`
+	PreviewMaxBodyBytes int `yaml:"preview_max_body_bytes"`
+	// HSTSMaxAge is sent in Strict-Transport-Security on HTTPS requests;
+	// 0 leaves the header out
+	HSTSMaxAge time.Duration `yaml:"hsts_max_age"`
+}
+
+// Default returns the configuration used where nothing overrides it
+func Default() *Config {
+	return &Config{
+		DBHost:              "localhost",
+		DBPort:              5432,
+		DBUser:              "postgres",
+		DBPassword:          "postgres",
+		DBName:              "blog",
+		DBDriver:            "postgres",
+		DBPath:              "blog.db",
+		DBSSLMode:           "disable",
+		PostStore:           "file",
+		GinMode:             "debug",
+		ServerPort:          "8080",
+		OTLPEndpoint:        "http://localhost:4318",
+		BaseURL:             "http://localhost:8080",
+		RequestTimeout:      5 * time.Second,
+		ReadTimeout:         10 * time.Second,
+		ReadHeaderTimeout:   5 * time.Second,
+		WriteTimeout:        30 * time.Second,
+		IdleTimeout:         2 * time.Minute,
+		MaxHeaderBytes:      64 << 10,
+		ShutdownDelay:       5 * time.Second,
+		ShutdownTimeout:     15 * time.Second,
+		TemplatesDir:        "templates",
+		StaticDir:           "static",
+		ContentDir:          "content/posts",
+		EnableAdmin:         true,
+		EnablePreview:       true,
+		RobotsDisallow:      []string{"/admin", "/preview", "/search"},
+		UsersFile:           "users.json",
+		SessionTTL:          12 * time.Hour,
+		SessionCookieSecure: true,
+		RateLimitPerMinute:  30,
+		RateLimitBurst:      10,
+		MaxBodyBytes:        1 << 20,
+		PreviewMaxBodyBytes: 256 << 10,
+		HSTSMaxAge:          365 * 24 * time.Hour,
+	}
+}
+
+// Load returns the configuration from the defaults, the YAML file named by
+// -config or CONFIG_FILE, the environment and the flags in args, each
+// overriding the one before. It doesn't check the result; see Validate.
+func Load(args ...string) (*Config, error) {
+	// Parse the flags once up front to find -config
+	flags := Default()
+	fs := flags.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	config.ConfigFile = getEnv("CONFIG_FILE", "")
+	if flags.ConfigFile != "" {
+		config.ConfigFile = flags.ConfigFile
+	}
+	if config.ConfigFile != "" {
+		if err := config.loadFile(config.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return nil, err
 	}
 
+	// Flags given on the command line win over everything else
+	if err := config.flagSet().Parse(args); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
+// loadFile reads the YAML file at path over the current values. Unknown
+// keys are an error, so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv reads the environment variables that are set over the current
+// values. Variables that fail to parse are reported together.
+func (c *Config) loadEnv() error {
+	env := &envLoader{}
+	env.string("DB_HOST", &c.DBHost)
+	env.int("DB_PORT", &c.DBPort)
+	env.string("DB_USER", &c.DBUser)
+	env.string("DB_PASSWORD", &c.DBPassword)
+	env.string("DB_NAME", &c.DBName)
+	env.string("DB_DRIVER", &c.DBDriver)
+	env.string("DB_PATH", &c.DBPath)
+	env.string("DB_SSLMODE", &c.DBSSLMode)
+	env.string("POST_STORE", &c.PostStore)
+	env.string("GIN_MODE", &c.GinMode)
+	env.string("SERVER_HOST", &c.ServerHost)
+	env.string("SERVER_PORT", &c.ServerPort)
+	env.string("OTLP_ENDPOINT", &c.OTLPEndpoint)
+	env.string("BASE_URL", &c.BaseURL)
+	env.duration("REQUEST_TIMEOUT", &c.RequestTimeout)
+	env.duration("READ_TIMEOUT", &c.ReadTimeout)
+	env.duration("READ_HEADER_TIMEOUT", &c.ReadHeaderTimeout)
+	env.duration("WRITE_TIMEOUT", &c.WriteTimeout)
+	env.duration("IDLE_TIMEOUT", &c.IdleTimeout)
+	env.int("MAX_HEADER_BYTES", &c.MaxHeaderBytes)
+	env.duration("SHUTDOWN_DELAY", &c.ShutdownDelay)
+	env.duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	env.string("TEMPLATES_DIR", &c.TemplatesDir)
+	env.string("STATIC_DIR", &c.StaticDir)
+	env.string("CONTENT_DIR", &c.ContentDir)
+	env.string("CONTENT_TIMEZONE", &c.ContentTimezone)
+	env.bool("CONTENT_WATCH", &c.ContentWatch)
+	env.bool("ENABLE_ADMIN", &c.EnableAdmin)
+	env.bool("ENABLE_PREVIEW", &c.EnablePreview)
+	env.list("ROBOTS_DISALLOW", &c.RobotsDisallow)
+	env.bool("ROBOTS_DISALLOW_ALL", &c.RobotsDisallowAll)
+	env.string("ADMIN_TOKEN", &c.AdminToken)
+	env.string("USERS_FILE", &c.UsersFile)
+	env.string("ADMIN_USERNAME", &c.AdminUsername)
+	env.string("ADMIN_PASSWORD", &c.AdminPassword)
+	env.string("SESSION_SECRET", &c.SessionSecret)
+	env.duration("SESSION_TTL", &c.SessionTTL)
+	env.bool("SESSION_COOKIE_SECURE", &c.SessionCookieSecure)
+	env.list("TRUSTED_PROXIES", &c.TrustedProxies)
+	env.int("RATE_LIMIT_PER_MINUTE", &c.RateLimitPerMinute)
+	env.int("RATE_LIMIT_BURST", &c.RateLimitBurst)
+	env.int("MAX_BODY_BYTES", &c.MaxBodyBytes)
+	env.int("PREVIEW_MAX_BODY_BYTES", &c.PreviewMaxBodyBytes)
+	env.duration("HSTS_MAX_AGE", &c.HSTSMaxAge)
+	return errors.Join(env.errs...)
+}
+
+// flagSet returns the command-line flags, bound to c with its current
+// values as defaults. Only the settings commonly changed per run have flags.
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("blog", flag.ContinueOnError)
+	fs.StringVar(&c.ConfigFile, "config", c.ConfigFile, "YAML config `file` (CONFIG_FILE)")
+	fs.StringVar(&c.ServerHost, "host", c.ServerHost, "host to listen on (SERVER_HOST)")
+	fs.StringVar(&c.ServerPort, "port", c.ServerPort, "port to listen on (SERVER_PORT)")
+	fs.StringVar(&c.BaseURL, "base-url", c.BaseURL, "public URL of the site (BASE_URL)")
+	fs.DurationVar(&c.RequestTimeout, "request-timeout", c.RequestTimeout, "timeout of each request (REQUEST_TIMEOUT)")
+	fs.DurationVar(&c.ShutdownDelay, "shutdown-delay", c.ShutdownDelay, "time readiness fails before draining (SHUTDOWN_DELAY)")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time in-flight requests get to finish (SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&c.TemplatesDir, "templates-dir", c.TemplatesDir, "HTML templates `directory` (TEMPLATES_DIR)")
+	fs.StringVar(&c.StaticDir, "static-dir", c.StaticDir, "static files `directory` (STATIC_DIR)")
+	fs.StringVar(&c.PostStore, "post-store", c.PostStore, "where posts are stored: file or sql (POST_STORE)")
+	fs.StringVar(&c.ContentDir, "content-dir", c.ContentDir, "markdown posts `directory` (CONTENT_DIR)")
+	fs.BoolVar(&c.ContentWatch, "content-watch", c.ContentWatch, "reload posts when they change (CONTENT_WATCH)")
+	fs.BoolVar(&c.EnableAdmin, "enable-admin", c.EnableAdmin, "serve the admin pages (ENABLE_ADMIN)")
+	fs.BoolVar(&c.EnablePreview, "enable-preview", c.EnablePreview, "serve the markdown preview (ENABLE_PREVIEW)")
+	return fs
+}
+
+// Addr returns the address the server listens on
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.ServerHost, c.ServerPort)
+}
+
+// Validate checks the configuration, returning every problem found
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.ServerPort)
+	check(err == nil && port > 0 && port < 65536, "SERVER_PORT %q is not a port number", c.ServerPort)
+	base, err := url.Parse(c.BaseURL)
+	check(err == nil && (base.Scheme == "http" || base.Scheme == "https") && base.Host != "",
+		"BASE_URL %q must be an absolute http or https URL", c.BaseURL)
+	check(c.RequestTimeout > 0, "REQUEST_TIMEOUT must be positive, got %v", c.RequestTimeout)
+	// Zero leaves an http.Server timeout unlimited
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"READ_TIMEOUT", c.ReadTimeout},
+		{"READ_HEADER_TIMEOUT", c.ReadHeaderTimeout},
+		{"WRITE_TIMEOUT", c.WriteTimeout},
+		{"IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_DELAY", c.ShutdownDelay},
+	} {
+		check(timeout.value >= 0, "%s must not be negative, got %v", timeout.name, timeout.value)
+	}
+	check(c.WriteTimeout == 0 || c.WriteTimeout >= c.RequestTimeout,
+		"WRITE_TIMEOUT %v is shorter than REQUEST_TIMEOUT %v, so responses would be cut off", c.WriteTimeout, c.RequestTimeout)
+	check(c.MaxHeaderBytes >= 0, "MAX_HEADER_BYTES must not be negative")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive, got %v", c.ShutdownTimeout)
+	check(isDir(c.TemplatesDir), "TEMPLATES_DIR %q is not a directory", c.TemplatesDir)
+	check(isDir(c.StaticDir), "STATIC_DIR %q is not a directory", c.StaticDir)
+
+	switch c.PostStore {
+	case "file":
+		check(isDir(c.ContentDir), "CONTENT_DIR %q is not a directory", c.ContentDir)
+		check(c.UsersFile != "", "USERS_FILE is required when POST_STORE is \"file\"")
+	case "sql":
+		check(c.DBDriver == "postgres" || c.DBDriver == "sqlite", "DB_DRIVER %q is not supported (want \"postgres\" or \"sqlite\")", c.DBDriver)
+	default:
+		check(false, "POST_STORE %q is not supported (want \"file\" or \"sql\")", c.PostStore)
+	}
+	if c.ContentTimezone != "" {
+		_, err := time.LoadLocation(c.ContentTimezone)
+		check(err == nil, "CONTENT_TIMEZONE %q is not a time zone", c.ContentTimezone)
+	}
+
+	check(c.GinMode == "debug" || c.GinMode == "release" || c.GinMode == "test",
+		"GIN_MODE %q is not supported (want \"debug\", \"release\" or \"test\")", c.GinMode)
+	check(c.SessionSecret == "" || len(c.SessionSecret) >= 32, "SESSION_SECRET must be at least 32 characters")
+	// Without a shared secret each process signs with its own random key, so
+	// sessions end on restart and fail on other replicas
+	check(!c.EnableAdmin || c.SessionSecret != "" || c.GinMode == "debug",
+		"SESSION_SECRET is required when ENABLE_ADMIN is on outside debug mode")
+	check(c.SessionTTL > 0, "SESSION_TTL must be positive, got %v", c.SessionTTL)
+	for _, proxy := range c.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES entry %q is not an IP address or CIDR", proxy)
+	}
+	check(c.RateLimitPerMinute >= 0, "RATE_LIMIT_PER_MINUTE must not be negative")
+	check(c.RateLimitBurst >= 1 || c.RateLimitPerMinute == 0, "RATE_LIMIT_BURST must be at least 1")
+	check(c.MaxBodyBytes >= 0, "MAX_BODY_BYTES must not be negative")
+	check(c.PreviewMaxBodyBytes >= 0, "PREVIEW_MAX_BODY_BYTES must not be negative")
+	check(c.HSTSMaxAge >= 0, "HSTS_MAX_AGE must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// String returns the configuration as YAML with secrets redacted, for
+// logging
+func (c *Config) String() string {
+	redacted := *c
+	for _, secret := range []*string{&redacted.DBPassword, &redacted.AdminPassword, &redacted.AdminToken, &redacted.SessionSecret} {
+		if *secret != "" {
+			*secret = "[redacted]"
+		}
+	}
+	out, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -36,11 +379,60 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value, exists := os.LookupEnv(key); exists {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// envLoader sets values from the environment variables that are set,
+// collecting the ones that don't parse
+type envLoader struct {
+	errs []error
+}
+
+func (e *envLoader) string(key string, value *string) {
+	*value = getEnv(key, *value)
+}
+
+func (e *envLoader) int(key string, value *int) {
+	if s, exists := os.LookupEnv(key); exists {
+		intValue, err := strconv.Atoi(s)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s %q is not a number", key, s))
+			return
 		}
+		*value = intValue
 	}
-	return defaultValue
-}
\ No newline at end of file
+}
+
+func (e *envLoader) bool(key string, value *bool) {
+	if s, exists := os.LookupEnv(key); exists {
+		boolValue, err := strconv.ParseBool(s)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s %q is not true or false", key, s))
+			return
+		}
+		*value = boolValue
+	}
+}
+
+func (e *envLoader) duration(key string, value *time.Duration) {
+	if s, exists := os.LookupEnv(key); exists {
+		durationValue, err := time.ParseDuration(s)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s %q is not a duration such as 30s or 12h", key, s))
+			return
+		}
+		*value = durationValue
+	}
+}
+
+// list splits a comma-separated variable, dropping empty entries
+func (e *envLoader) list(key string, value *[]string) {
+	s, exists := os.LookupEnv(key)
+	if !exists {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*value = list
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -92,4 +95,256 @@ func TestLoad_Defaults(t *testing.T) {
 	if config.OTLPEndpoint != "http://localhost:4318" {
 		t.Errorf("OTLPEndpoint = %v, want %v", config.OTLPEndpoint, "http://localhost:4318")
 	}
-}
\ No newline at end of file
+}
+func TestLoad_Robots(t *testing.T) {
+	os.Setenv("ROBOTS_DISALLOW", "/admin, /preview,,")
+	os.Setenv("ROBOTS_DISALLOW_ALL", "true")
+	defer func() {
+		os.Unsetenv("ROBOTS_DISALLOW")
+		os.Unsetenv("ROBOTS_DISALLOW_ALL")
+	}()
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(config.RobotsDisallow) != 2 || config.RobotsDisallow[0] != "/admin" || config.RobotsDisallow[1] != "/preview" {
+		t.Errorf("RobotsDisallow = %v, want %v", config.RobotsDisallow, []string{"/admin", "/preview"})
+	}
+	if !config.RobotsDisallowAll {
+		t.Errorf("RobotsDisallowAll = %v, want %v", config.RobotsDisallowAll, true)
+	}
+}
+
+func TestLoad_Sessions(t *testing.T) {
+	os.Setenv("SESSION_TTL", "30m")
+	os.Setenv("SESSION_COOKIE_SECURE", "false")
+	defer func() {
+		os.Unsetenv("SESSION_TTL")
+		os.Unsetenv("SESSION_COOKIE_SECURE")
+	}()
+
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if config.SessionTTL != 30*time.Minute {
+		t.Errorf("SessionTTL = %v, want %v", config.SessionTTL, 30*time.Minute)
+	}
+	if config.SessionCookieSecure {
+		t.Errorf("SessionCookieSecure = %v, want %v", config.SessionCookieSecure, false)
+	}
+	if config.UsersFile != "users.json" {
+		t.Errorf("UsersFile = %v, want %v", config.UsersFile, "users.json")
+	}
+}
+
+func TestLoad_Limits(t *testing.T) {
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(config.TrustedProxies) != 0 {
+		t.Errorf("TrustedProxies = %v, want none by default", config.TrustedProxies)
+	}
+	if config.RateLimitPerMinute != 30 || config.RateLimitBurst != 10 {
+		t.Errorf("Rate limit = %d/min burst %d, want 30/min burst 10", config.RateLimitPerMinute, config.RateLimitBurst)
+	}
+	if config.PreviewMaxBodyBytes != 256<<10 {
+		t.Errorf("PreviewMaxBodyBytes = %v, want %v", config.PreviewMaxBodyBytes, 256<<10)
+	}
+
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.1")
+	os.Setenv("RATE_LIMIT_PER_MINUTE", "0")
+	os.Setenv("MAX_BODY_BYTES", "4096")
+	defer func() {
+		os.Unsetenv("TRUSTED_PROXIES")
+		os.Unsetenv("RATE_LIMIT_PER_MINUTE")
+		os.Unsetenv("MAX_BODY_BYTES")
+	}()
+
+	config, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(config.TrustedProxies) != 2 || config.TrustedProxies[1] != "192.168.1.1" {
+		t.Errorf("TrustedProxies = %v, want [10.0.0.0/8 192.168.1.1]", config.TrustedProxies)
+	}
+	if config.RateLimitPerMinute != 0 {
+		t.Errorf("RateLimitPerMinute = %v, want 0", config.RateLimitPerMinute)
+	}
+	if config.MaxBodyBytes != 4096 {
+		t.Errorf("MaxBodyBytes = %v, want 4096", config.MaxBodyBytes)
+	}
+}
+
+func TestLoad_HSTS(t *testing.T) {
+	config, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if config.HSTSMaxAge != 365*24*time.Hour {
+		t.Errorf("HSTSMaxAge = %v, want %v", config.HSTSMaxAge, 365*24*time.Hour)
+	}
+
+	os.Setenv("HSTS_MAX_AGE", "0s")
+	defer os.Unsetenv("HSTS_MAX_AGE")
+	if config, _ = Load(); config.HSTSMaxAge != 0 {
+		t.Errorf("HSTSMaxAge = %v, want 0", config.HSTSMaxAge)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blog.yaml")
+	yaml := "server_port: \"7070\"\nbase_url: https://file.example.com\ncontent_dir: /srv/posts\nsession_ttl: 1h\nrobots_disallow: [/drafts]\n"
+	if err := os.WriteFile(file, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CONFIG_FILE", file)
+	os.Setenv("BASE_URL", "https://env.example.com")
+	os.Setenv("CONTENT_DIR", "/env/posts")
+	defer func() {
+		os.Unsetenv("CONFIG_FILE")
+		os.Unsetenv("BASE_URL")
+		os.Unsetenv("CONTENT_DIR")
+	}()
+
+	config, err := Load("-content-dir", "/flag/posts", "-enable-preview=false")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Each layer overrides only what it sets
+	if config.ServerPort != "7070" || config.SessionTTL != time.Hour || len(config.RobotsDisallow) != 1 {
+		t.Errorf("File values not applied: port %v, ttl %v, robots %v", config.ServerPort, config.SessionTTL, config.RobotsDisallow)
+	}
+	if config.BaseURL != "https://env.example.com" {
+		t.Errorf("BaseURL = %v, want the environment's", config.BaseURL)
+	}
+	if config.ContentDir != "/flag/posts" || config.EnablePreview {
+		t.Errorf("Flags not applied: content dir %v, preview %v", config.ContentDir, config.EnablePreview)
+	}
+	if config.DBHost != "localhost" || !config.EnableAdmin {
+		t.Errorf("Defaults lost: DBHost %v, EnableAdmin %v", config.DBHost, config.EnableAdmin)
+	}
+	if config.Addr() != ":7070" {
+		t.Errorf("Addr() = %v, want :7070", config.Addr())
+	}
+
+	// -config wins over CONFIG_FILE
+	other := filepath.Join(t.TempDir(), "other.yaml")
+	if err := os.WriteFile(other, []byte("server_host: 127.0.0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err = Load("-config", other)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if config.Addr() != "127.0.0.1:8080" || config.ConfigFile != other {
+		t.Errorf("Addr() = %v from %v, want 127.0.0.1:8080 from %v", config.Addr(), config.ConfigFile, other)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	typo := filepath.Join(dir, "typo.yaml")
+	if err := os.WriteFile(typo, []byte("server_prot: \"8080\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("-config", typo); err == nil || !strings.Contains(err.Error(), "server_prot") {
+		t.Errorf("Expected an error naming the unknown key, got %v", err)
+	}
+	if _, err := Load("-config", filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+	if _, err := Load("-no-such-flag"); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+
+	os.Setenv("DB_PORT", "five")
+	os.Setenv("SESSION_TTL", "a day")
+	defer func() {
+		os.Unsetenv("DB_PORT")
+		os.Unsetenv("SESSION_TTL")
+	}()
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "DB_PORT") || !strings.Contains(err.Error(), "SESSION_TTL") {
+		t.Errorf("Expected errors for DB_PORT and SESSION_TTL, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := Default()
+	valid.TemplatesDir, valid.StaticDir, valid.ContentDir = dir, dir, dir
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	invalid := *valid
+	invalid.ServerPort = "http"
+	invalid.BaseURL = "example.com"
+	invalid.ContentDir = filepath.Join(dir, "missing")
+	invalid.ContentTimezone = "Mars/Olympus"
+	invalid.SessionSecret = "short"
+	invalid.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"}
+	invalid.RequestTimeout = 0
+
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("Validate() accepted an invalid configuration")
+	}
+	for _, want := range []string{"SERVER_PORT", "BASE_URL", "CONTENT_DIR", "CONTENT_TIMEZONE", "SESSION_SECRET", "proxy.local", "REQUEST_TIMEOUT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error doesn't mention %s: %v", want, err)
+		}
+	}
+
+	// Release mode needs a shared session secret while admin is enabled
+	invalid = *valid
+	invalid.GinMode = "release"
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), "SESSION_SECRET is required") {
+		t.Errorf("Validate() error = %v, want one about SESSION_SECRET", err)
+	}
+	invalid.EnableAdmin = false
+	if err := invalid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v with admin disabled", err)
+	}
+
+	invalid = *valid
+	invalid.PostStore = "s3"
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), "POST_STORE") {
+		t.Errorf("Validate() error = %v, want one about POST_STORE", err)
+	}
+
+	invalid = *valid
+	invalid.WriteTimeout = time.Second
+	invalid.ShutdownTimeout = 0
+	invalid.IdleTimeout = -time.Second
+	err = invalid.Validate()
+	for _, want := range []string{"WRITE_TIMEOUT", "SHUTDOWN_TIMEOUT", "IDLE_TIMEOUT"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want one about %s", err, want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	config := Default()
+	config.SessionSecret = "0123456789abcdef0123456789abcdef"
+	config.AdminToken = "token"
+
+	s := config.String()
+	if strings.Contains(s, config.SessionSecret) || strings.Contains(s, "token\n") || strings.Contains(s, "db_password: postgres") {
+		t.Errorf("String() leaks a secret:\n%s", s)
+	}
+	if !strings.Contains(s, "server_port: \"8080\"") || !strings.Contains(s, "session_secret: '[redacted]'") {
+		t.Errorf("String() =\n%s", s)
+	}
+	if config.SessionSecret == "[redacted]" {
+		t.Error("String() modified the config")
+	}
+}
@@ -0,0 +1,344 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterFormat identifies the syntax of a front matter block
+type frontMatterFormat string
+
+const (
+	formatYAML frontMatterFormat = "YAML"
+	formatTOML frontMatterFormat = "TOML"
+	formatJSON frontMatterFormat = "JSON"
+)
+
+// byteOrderMark is stripped from the start of files saved by some editors
+const byteOrderMark = "\ufeff"
+
+// FrontMatterError describes a front matter problem at a location in a file
+type FrontMatterError struct {
+	File string // Path of the file, if known
+	Line int    // 1-based line number in the file, or 0 if unknown
+	Msg  string
+	Err  error // Underlying decoder error, if any
+}
+
+func (e *FrontMatterError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteString(":")
+	}
+	if e.Line > 0 {
+		if e.File == "" {
+			b.WriteString("line ")
+		}
+		b.WriteString(strconv.Itoa(e.Line))
+		b.WriteString(":")
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+func (e *FrontMatterError) Unwrap() error {
+	return e.Err
+}
+
+// withFileName sets the file name on a *FrontMatterError
+func withFileName(err error, name string) error {
+	var fmErr *FrontMatterError
+	if errors.As(err, &fmErr) {
+		fmErr.File = name
+	}
+	return err
+}
+
+// Timestamp is a front matter date. It accepts RFC3339 values, date-only
+// values and local date-times without a zone offset; the latter two are
+// interpreted in the content store's default timezone.
+type Timestamp struct {
+	Time time.Time
+
+	raw  string // Value as written in the file
+	line int    // Line of the value within the front matter block, if known
+}
+
+// UnmarshalYAML captures the raw scalar so it can be parsed leniently
+func (t *Timestamp) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected a date, got a %s", node.Line, yamlKindName(node.Kind))
+	}
+	if node.Tag != "!!null" {
+		t.raw = node.Value
+	}
+	t.line = node.Line
+	return nil
+}
+
+// UnmarshalTOML captures the raw date-time or string value
+func (t *Timestamp) UnmarshalTOML(node *unstable.Node) error {
+	switch node.Kind {
+	case unstable.DateTime, unstable.LocalDateTime, unstable.LocalDate, unstable.String:
+		t.raw = string(node.Data)
+		return nil
+	default:
+		return fmt.Errorf("expected a date, got %s", node.Kind)
+	}
+}
+
+// UnmarshalJSON captures the raw string value
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &t.raw); err != nil {
+		return errors.New("expected a date string")
+	}
+	return nil
+}
+
+// timestampLayouts are tried in order. Layouts without a zone are parsed in
+// the default timezone.
+var timestampLayouts = []struct {
+	layout string
+	zoned  bool
+}{
+	{time.RFC3339Nano, true},
+	{"2006-01-02 15:04:05Z07:00", true},
+	{"2006-01-02 15:04:05 -07:00", true},
+	{"2006-01-02 15:04:05 -0700", true},
+	{"2006-01-02T15:04:05", false},
+	{"2006-01-02 15:04:05", false},
+	{"2006-01-02T15:04", false},
+	{"2006-01-02 15:04", false},
+	{"2006-01-02", false},
+}
+
+// resolve parses the raw value, using loc for values without a zone offset
+func (t *Timestamp) resolve(loc *time.Location) error {
+	raw := strings.TrimSpace(t.raw)
+	if raw == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	for _, l := range timestampLayouts {
+		var (
+			parsed time.Time
+			err    error
+		)
+		if l.zoned {
+			parsed, err = time.Parse(l.layout, raw)
+		} else {
+			parsed, err = time.ParseInLocation(l.layout, raw, loc)
+		}
+		if err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid date %q (expected YYYY-MM-DD, YYYY-MM-DDTHH:MM:SS or RFC3339)", t.raw)
+}
+
+// extractFrontMatter splits a file into its front matter block and body.
+// Front matter is only recognised at the start of the file, after an optional
+// byte order mark and blank lines. It returns the block's format, the block
+// itself, the 1-based file line the block starts on, and the body.
+func extractFrontMatter(content string) (frontMatterFormat, string, int, string, error) {
+	content = strings.TrimPrefix(content, byteOrderMark)
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	lines := strings.SplitAfter(content, "\n")
+	first := 0
+	for first < len(lines) && strings.TrimSpace(lines[first]) == "" {
+		first++
+	}
+	if first == len(lines) {
+		return "", "", 0, "", &FrontMatterError{Msg: "missing front matter: file is empty"}
+	}
+
+	opening := strings.TrimRight(lines[first], " \t\n")
+	switch {
+	case opening == "---" || opening == "+++":
+		format, delim := formatYAML, "---"
+		if opening == "+++" {
+			format, delim = formatTOML, "+++"
+		}
+		for i := first + 1; i < len(lines); i++ {
+			if strings.TrimRight(lines[i], " \t\n") == delim {
+				block := strings.Join(lines[first+1:i], "")
+				body := strings.Join(lines[i+1:], "")
+				return format, block, first + 2, body, nil
+			}
+		}
+		return "", "", 0, "", &FrontMatterError{
+			Line: first + 1,
+			Msg:  fmt.Sprintf("unterminated %s front matter: no closing %q found", format, delim),
+		}
+
+	case strings.HasPrefix(opening, "{"):
+		rest := strings.Join(lines[first:], "")
+		dec := json.NewDecoder(strings.NewReader(rest))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return "", "", 0, "", jsonFrontMatterError(err, rest, first+1)
+		}
+		end := int(dec.InputOffset())
+		body := rest[end:]
+		// The closing brace must end its line
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			if strings.TrimSpace(body[:nl]) != "" {
+				return "", "", 0, "", &FrontMatterError{
+					Line: first + 1 + strings.Count(rest[:end], "\n"),
+					Msg:  "unexpected content after closing '}' of JSON front matter",
+				}
+			}
+			body = body[nl+1:]
+		} else if strings.TrimSpace(body) != "" {
+			return "", "", 0, "", &FrontMatterError{
+				Line: first + 1 + strings.Count(rest[:end], "\n"),
+				Msg:  "unexpected content after closing '}' of JSON front matter",
+			}
+		}
+		return formatJSON, string(raw), first + 1, body, nil
+
+	default:
+		return "", "", 0, "", &FrontMatterError{
+			Line: first + 1,
+			Msg:  "missing front matter: expected '---' (YAML), '+++' (TOML) or '{' (JSON) at the start of the file",
+		}
+	}
+}
+
+// yamlLinePattern matches the line references in yaml.v3 error messages
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// decodeFrontMatter decodes a front matter block into fm. startLine is the
+// file line of the block's first line and is used to make error locations
+// relative to the file.
+func decodeFrontMatter(format frontMatterFormat, block string, startLine int, fm *FrontMatter) error {
+	switch format {
+	case formatYAML:
+		if err := yaml.Unmarshal([]byte(block), fm); err != nil {
+			return yamlFrontMatterError(err, startLine)
+		}
+
+	case formatTOML:
+		dec := toml.NewDecoder(strings.NewReader(block)).EnableUnmarshalerInterface()
+		if err := dec.Decode(fm); err != nil {
+			var decodeErr *toml.DecodeError
+			if errors.As(err, &decodeErr) {
+				row, _ := decodeErr.Position()
+				return &FrontMatterError{
+					Line: startLine + row - 1,
+					Msg:  "invalid TOML front matter: " + decodeErr.Error(),
+					Err:  err,
+				}
+			}
+			return &FrontMatterError{Line: startLine, Msg: "invalid TOML front matter: " + err.Error(), Err: err}
+		}
+
+	case formatJSON:
+		if err := json.Unmarshal([]byte(block), fm); err != nil {
+			return jsonFrontMatterError(err, block, startLine)
+		}
+	}
+
+	return nil
+}
+
+// yamlFrontMatterError rewrites the block-relative line numbers reported by
+// yaml.v3 into file line numbers
+func yamlFrontMatterError(err error, startLine int) *FrontMatterError {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	line := startLine
+
+	if m := yamlLinePattern.FindStringSubmatch(msg); m != nil {
+		if n, convErr := strconv.Atoi(m[1]); convErr == nil {
+			line = startLine + n - 1
+		}
+	}
+	msg = yamlLinePattern.ReplaceAllStringFunc(msg, func(s string) string {
+		n, _ := strconv.Atoi(yamlLinePattern.FindStringSubmatch(s)[1])
+		return fmt.Sprintf("line %d", startLine+n-1)
+	})
+
+	// Strip the now redundant "line N: " prefix of single errors
+	msg = strings.TrimPrefix(msg, fmt.Sprintf("line %d: ", line))
+	msg = strings.ReplaceAll(msg, "\n  ", "; ")
+
+	return &FrontMatterError{Line: line, Msg: "invalid YAML front matter: " + msg, Err: err}
+}
+
+// jsonFrontMatterError converts a JSON decoding error offset into a file line
+func jsonFrontMatterError(err error, block string, startLine int) *FrontMatterError {
+	offset := int64(-1)
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+
+	line := startLine
+	if offset >= 0 && offset <= int64(len(block)) {
+		line += bytes.Count([]byte(block[:offset]), []byte("\n"))
+	}
+
+	return &FrontMatterError{Line: line, Msg: "invalid JSON front matter: " + err.Error(), Err: err}
+}
+
+// resolveTimestamps parses the front matter's date fields in loc, reporting
+// the offending field on failure
+func resolveTimestamps(fm *FrontMatter, startLine int, loc *time.Location) error {
+	fields := []struct {
+		name string
+		ts   *Timestamp
+	}{
+		{"date", &fm.Date},
+		{"publish_at", &fm.PublishAt},
+		{"updated", &fm.Updated},
+	}
+
+	for _, f := range fields {
+		if err := f.ts.resolve(loc); err != nil {
+			line := startLine
+			if f.ts.line > 0 {
+				line = startLine + f.ts.line - 1
+			}
+			return &FrontMatterError{Line: line, Msg: fmt.Sprintf("%s: %v", f.name, err)}
+		}
+	}
+
+	return nil
+}
+
+func yamlKindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.MappingNode:
+		return "mapping"
+	default:
+		return "non-scalar value"
+	}
+}
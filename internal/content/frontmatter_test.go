@@ -0,0 +1,251 @@
+package content
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFrontMatterFormats(t *testing.T) {
+	want := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		content string
+	}{
+		{
+			name: "YAML",
+			input: `---
+title: "Test Post"
+date: 2024-01-15T10:00:00Z
+tags: ["go", "yaml"]
+published: true
+---
+
+Body text.`,
+			content: "Body text.",
+		},
+		{
+			name: "TOML",
+			input: `+++
+title = "Test Post"
+date = 2024-01-15T10:00:00Z
+tags = ["go", "yaml"]
+published = true
++++
+
+Body text.`,
+			content: "Body text.",
+		},
+		{
+			name: "JSON",
+			input: `{
+  "title": "Test Post",
+  "date": "2024-01-15T10:00:00Z",
+  "tags": ["go", "yaml"],
+  "published": true
+}
+
+Body text.`,
+			content: "Body text.",
+		},
+		{
+			name:    "Byte order mark and leading blank lines",
+			input:   "\ufeff\n\n---\ntitle: \"Test Post\"\ndate: 2024-01-15T10:00:00Z\ntags: [go, yaml]\npublished: true\n---\nBody text.",
+			content: "Body text.",
+		},
+		{
+			name:    "Windows line endings",
+			input:   "---\r\ntitle: \"Test Post\"\r\ndate: 2024-01-15T10:00:00Z\r\ntags: [go, yaml]\r\npublished: true\r\n---\r\nBody text.",
+			content: "Body text.",
+		},
+		{
+			name: "Horizontal rules in body are preserved",
+			input: `---
+title: "Test Post"
+date: 2024-01-15T10:00:00Z
+tags: [go, yaml]
+published: true
+---
+
+Above.
+
+---
+
+Below.`,
+			content: "Above.\n\n---\n\nBelow.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, content, err := parseFrontMatter("test.md", tt.input, time.UTC)
+			if err != nil {
+				t.Fatalf("parseFrontMatter() unexpected error: %v", err)
+			}
+			if fm.Title != "Test Post" {
+				t.Errorf("Expected title 'Test Post', got '%s'", fm.Title)
+			}
+			if !fm.Date.Time.Equal(want) {
+				t.Errorf("Expected date %v, got %v", want, fm.Date.Time)
+			}
+			if len(fm.Tags) != 2 || fm.Tags[1] != "yaml" {
+				t.Errorf("Expected tags [go yaml], got %v", fm.Tags)
+			}
+			if !fm.Published {
+				t.Error("Expected published to be true")
+			}
+			if content != tt.content {
+				t.Errorf("Expected content %q, got %q", tt.content, content)
+			}
+		})
+	}
+}
+
+func TestParseFrontMatterDelimiterInValue(t *testing.T) {
+	input := `---
+title: "Before --- After"
+description: |
+  A block scalar
+  ---
+  with a dashed line
+published: true
+---
+
+Body text.`
+
+	fm, content, err := parseFrontMatter("test.md", input, time.UTC)
+	if err != nil {
+		t.Fatalf("parseFrontMatter() unexpected error: %v", err)
+	}
+	if fm.Title != "Before --- After" {
+		t.Errorf("Expected title 'Before --- After', got '%s'", fm.Title)
+	}
+	if !strings.Contains(fm.Description, "---") {
+		t.Errorf("Expected description to contain '---', got %q", fm.Description)
+	}
+	if content != "Body text." {
+		t.Errorf("Expected content 'Body text.', got %q", content)
+	}
+}
+
+func TestParseFrontMatterDates(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"RFC3339 UTC", "2024-01-15T10:00:00Z", time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"RFC3339 with offset", "2024-01-15T10:00:00+02:00", time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"Date only", "2024-01-15", time.Date(2024, 1, 15, 0, 0, 0, 0, newYork)},
+		{"Local date-time", "2024-01-15T10:00:00", time.Date(2024, 1, 15, 10, 0, 0, 0, newYork)},
+		{"Local date-time with space", "2024-01-15 10:00", time.Date(2024, 1, 15, 10, 0, 0, 0, newYork)},
+		{"Quoted date only", `"2024-01-15"`, time.Date(2024, 1, 15, 0, 0, 0, 0, newYork)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "---\ntitle: Dates\ndate: " + tt.value + "\n---\nBody."
+			fm, _, err := parseFrontMatter("test.md", input, newYork)
+			if err != nil {
+				t.Fatalf("parseFrontMatter() unexpected error: %v", err)
+			}
+			if !fm.Date.Time.Equal(tt.want) {
+				t.Errorf("Expected date %v, got %v", tt.want, fm.Date.Time)
+			}
+		})
+	}
+
+	t.Run("TOML local date uses default timezone", func(t *testing.T) {
+		input := "+++\ntitle = \"Dates\"\ndate = 2024-01-15\npublish_at = 2024-01-16T09:30:00\n+++\nBody."
+		fm, _, err := parseFrontMatter("test.md", input, newYork)
+		if err != nil {
+			t.Fatalf("parseFrontMatter() unexpected error: %v", err)
+		}
+		if want := time.Date(2024, 1, 15, 0, 0, 0, 0, newYork); !fm.Date.Time.Equal(want) {
+			t.Errorf("Expected date %v, got %v", want, fm.Date.Time)
+		}
+		if want := time.Date(2024, 1, 16, 9, 30, 0, 0, newYork); !fm.PublishAt.Time.Equal(want) {
+			t.Errorf("Expected publish_at %v, got %v", want, fm.PublishAt.Time)
+		}
+	})
+}
+
+func TestParseFrontMatterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantLine int
+		wantMsg  string
+	}{
+		{
+			name:     "Missing front matter",
+			input:    "\n\n# Just markdown",
+			wantLine: 3,
+			wantMsg:  "missing front matter",
+		},
+		{
+			name:     "Unterminated YAML",
+			input:    "\n---\ntitle: Test\n\nBody.",
+			wantLine: 2,
+			wantMsg:  "unterminated YAML front matter",
+		},
+		{
+			name:     "Invalid YAML syntax",
+			input:    "---\ntitle: Test\ndescription: a: b\n---\nBody.",
+			wantLine: 3,
+			wantMsg:  "invalid YAML front matter",
+		},
+		{
+			name:     "Invalid YAML date",
+			input:    "---\ntitle: Test\n\ndate: not-a-date\n---\nBody.",
+			wantLine: 4,
+			wantMsg:  `date: invalid date "not-a-date"`,
+		},
+		{
+			name:     "Invalid TOML",
+			input:    "+++\ntitle = \"Test\"\npublished = maybe\n+++\nBody.",
+			wantLine: 3,
+			wantMsg:  "invalid TOML front matter",
+		},
+		{
+			name:     "Invalid JSON",
+			input:    "{\n  \"title\": \"Test\",\n  \"published\": \"yes\"\n}\nBody.",
+			wantLine: 3,
+			wantMsg:  "invalid JSON front matter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseFrontMatter("posts/broken.md", tt.input, time.UTC)
+			if err == nil {
+				t.Fatal("parseFrontMatter() expected error, got nil")
+			}
+
+			var fmErr *FrontMatterError
+			if !errors.As(err, &fmErr) {
+				t.Fatalf("Expected *FrontMatterError, got %T: %v", err, err)
+			}
+			if fmErr.File != "posts/broken.md" {
+				t.Errorf("Expected file 'posts/broken.md', got '%s'", fmErr.File)
+			}
+			if fmErr.Line != tt.wantLine {
+				t.Errorf("Expected line %d, got %d (%v)", tt.wantLine, fmErr.Line, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Expected error containing %q, got %q", tt.wantMsg, err.Error())
+			}
+			if !strings.HasPrefix(err.Error(), "posts/broken.md:") {
+				t.Errorf("Expected error prefixed with file name, got %q", err.Error())
+			}
+		})
+	}
+}
@@ -1,136 +1,330 @@
 package content
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/html"
-	"github.com/gomarkdown/markdown/parser"
-	"gopkg.in/yaml.v3"
+	"github.com/seanankenbruck/blog/internal/markdown"
+	"github.com/seanankenbruck/blog/internal/search"
 )
 
 // Post represents a blog post loaded from a markdown file
 type Post struct {
-	Title       string    `yaml:"title"`
-	Slug        string    `yaml:"slug"`
-	Date        time.Time `yaml:"date"`
-	Tags        []string  `yaml:"tags"`
-	Description string    `yaml:"description"`
-	Published   bool      `yaml:"published"` // Controls whether post is visible
-	Content     string    `yaml:"-"`         // Raw markdown content
-	HTMLContent string    `yaml:"-"`         // Rendered HTML
+	Title       string             `yaml:"title"`
+	Slug        string             `yaml:"slug"`
+	Date        time.Time          `yaml:"date"`
+	Tags        []string           `yaml:"tags"`
+	Description string             `yaml:"description"`
+	Published   bool               `yaml:"published"`    // Controls whether post is visible
+	PublishAt   time.Time          `yaml:"publish_at"`   // Optional time the post becomes visible
+	Updated     time.Time          `yaml:"updated"`      // Optional time the post was last revised
+	Series      string             `yaml:"series"`       // Optional series the post is part of
+	SeriesOrder int                `yaml:"series_order"` // Optional position within the series
+	Related     []string           `yaml:"related"`      // Optional slugs of posts pinned as related
+	NoTOC       bool               `yaml:"-"`            // Set by toc: false to hide the table of contents
+	Content     string             `yaml:"-"`            // Raw markdown content
+	HTMLContent string             `yaml:"-"`            // Rendered HTML
+	Summary     string             `yaml:"-"`            // Rendered excerpt for listings
+	Headings    []markdown.Heading `yaml:"-"`            // Headings of the rendered content
+	WordCount   int                `yaml:"-"`            // Words in the content, including code
+	Path        string             `yaml:"-"`            // File the post was loaded from
 }
 
-// FrontMatter represents the YAML front matter in a markdown file
+var (
+	// ErrPostNotFound is returned when no post has the requested slug
+	ErrPostNotFound = errors.New("post not found")
+	// ErrPostExists is returned when creating a post whose slug is taken
+	ErrPostExists = errors.New("post already exists")
+)
+
+// IsVisibleAt reports whether the post is publicly visible at time t. A post is
+// visible once it is published and its publish_at time, if any, has passed.
+func (p *Post) IsVisibleAt(t time.Time) bool {
+	return p.Published && !p.PublishAt.After(t)
+}
+
+// FrontMatter represents the front matter of a markdown file. It may be
+// written as YAML (---), TOML (+++) or JSON ({...}).
 type FrontMatter struct {
-	Title       string    `yaml:"title"`
-	Slug        string    `yaml:"slug"`
-	Date        time.Time `yaml:"date"`
-	Tags        []string  `yaml:"tags"`
-	Description string    `yaml:"description"`
-	Published   bool      `yaml:"published"` // Controls whether post is visible
+	Title       string    `yaml:"title" toml:"title" json:"title"`
+	Slug        string    `yaml:"slug" toml:"slug" json:"slug"`
+	Date        Timestamp `yaml:"date" toml:"date" json:"date"`
+	Tags        []string  `yaml:"tags" toml:"tags" json:"tags"`
+	Description string    `yaml:"description" toml:"description" json:"description"`
+	Published   bool      `yaml:"published" toml:"published" json:"published"`          // Controls whether post is visible
+	PublishAt   Timestamp `yaml:"publish_at" toml:"publish_at" json:"publish_at"`       // Optional time the post becomes visible
+	Updated     Timestamp `yaml:"updated" toml:"updated" json:"updated"`                // Optional time the post was last revised
+	TOC         *bool     `yaml:"toc" toml:"toc" json:"toc"`                            // Optional; false hides the table of contents
+	Series      string    `yaml:"series" toml:"series" json:"series"`                   // Optional series the post is part of
+	SeriesOrder int       `yaml:"series_order" toml:"series_order" json:"series_order"` // Optional position within the series
+	Related     []string  `yaml:"related" toml:"related" json:"related"`                // Optional slugs of posts pinned as related
 }
 
-var (
-	posts     []*Post
-	postsMap  map[string]*Post
-	isLoaded  bool
-	isDev     bool
-	contentDir string
-)
+// SnippetsDir is the directory beside posts holding the files that code
+// blocks can include with {include="file"}. It is not searched for posts.
+const SnippetsDir = "snippets"
+
+// ContentStore loads posts from a content directory and serves them to
+// concurrent readers. Each load builds a complete, immutable snapshot which is
+// swapped in atomically, so readers never observe a partially loaded set.
+type ContentStore struct {
+	dir      string
+	isDev    bool
+	location *time.Location // Timezone for front matter dates without an offset
+	renderer *markdown.Renderer
+	now      func() time.Time
+
+	// loadMu serializes loads so overlapping reloads cannot publish out of order
+	loadMu   sync.Mutex
+	snapshot atomic.Pointer[snapshot]
+}
+
+// snapshot is an immutable view of the loaded posts. It must not be modified
+// once it has been published to a ContentStore.
+type snapshot struct {
+	posts    []*Post
+	postsMap map[string]*Post
+
+	// hidden is set when any post was a draft or scheduled at load time, in
+	// which case reads must filter by visibility
+	hidden bool
+
+	// index is the full-text search index over posts
+	index *search.Index
+	// related ranks each post's similar posts by tags and text
+	related *search.Related
+}
+
+// SearchResult is a post matching a search query
+type SearchResult struct {
+	Post  *Post
+	Score float64
+}
 
-// Init initializes the content loader with the content directory path
-func Init(dir string, devMode bool) {
-	contentDir = dir
-	isDev = devMode
+// NewContentStore creates a ContentStore for the given content directory
+func NewContentStore(dir string, devMode bool) *ContentStore {
+	if dir == "" {
+		dir = "content/posts"
+	}
+	return &ContentStore{
+		dir:      dir,
+		isDev:    devMode,
+		location: time.UTC,
+		renderer: markdown.New(markdown.DefaultExtensions),
+		now:      time.Now,
+	}
 }
 
-// LoadPosts loads all markdown posts from the content directory
-func LoadPosts() error {
-	if contentDir == "" {
-		contentDir = "content/posts"
+// SetLocation sets the timezone used for front matter dates that carry no
+// zone offset, such as date-only values. It applies from the next load.
+func (s *ContentStore) SetLocation(loc *time.Location) {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	if loc == nil {
+		loc = time.UTC
 	}
+	s.location = loc
+}
+
+// SetRenderer sets the markdown renderer posts are converted with. It
+// applies from the next load.
+func (s *ContentStore) SetRenderer(r *markdown.Renderer) {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	s.renderer = r
+}
+
+// Dir returns the content directory the store loads posts from
+func (s *ContentStore) Dir() string {
+	return s.dir
+}
+
+// IsDev reports whether the store was created in development mode
+func (s *ContentStore) IsDev() bool {
+	return s.isDev
+}
+
+// LoadPosts loads all markdown posts from the content directory and replaces
+// the current snapshot. On error the previous snapshot is left in place.
+func (s *ContentStore) LoadPosts() error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	return s.reloadLocked()
+}
 
+// buildSnapshot reads every post from disk into a new snapshot
+func (s *ContentStore) buildSnapshot() (*snapshot, error) {
 	// Check if content directory exists
-	if _, err := os.Stat(contentDir); os.IsNotExist(err) {
-		return fmt.Errorf("content directory does not exist: %s", contentDir)
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("content directory does not exist: %s", s.dir)
 	}
 
-	posts = make([]*Post, 0)
-	postsMap = make(map[string]*Post)
+	now := s.now()
+	snap := &snapshot{
+		posts:    make([]*Post, 0),
+		postsMap: make(map[string]*Post),
+	}
 
 	// Walk the content directory and load all .md files
-	err := filepath.WalkDir(contentDir, func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories and non-markdown files
-		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+		// Skip hidden directories such as the ..<timestamp> revisions of a
+		// ConfigMap volume; their files are reached through the top-level symlinks
+		if d.IsDir() {
+			if path != s.dir && (isHidden(d.Name()) || d.Name() == SnippetsDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Skip hidden files (editor lock files) and non-markdown files
+		if isHidden(d.Name()) || !strings.HasSuffix(d.Name(), ".md") {
 			return nil
 		}
 
 		// Load the post from the file
-		post, err := loadPostFromFile(path)
+		// Errors from loadPostFromFile already carry the file path
+		post, err := loadPostFromFile(path, s.location, s.renderer)
 		if err != nil {
-			return fmt.Errorf("error loading %s: %w", path, err)
+			return err
 		}
 
-		// Only include published posts
-		if !post.Published {
-			return nil // Skip unpublished posts in production
+		// Drafts and scheduled posts are kept for editing and so scheduled
+		// posts appear once publish_at passes; reads filter them out
+		if !post.IsVisibleAt(now) {
+			snap.hidden = true
 		}
 
-		posts = append(posts, post)
-		postsMap[post.Slug] = post
+		snap.posts = append(snap.posts, post)
+		snap.postsMap[post.Slug] = post
 
 		return nil
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to load posts: %w", err)
+		return nil, fmt.Errorf("failed to load posts: %w", err)
 	}
 
 	// Sort posts by date (newest first)
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Date.After(posts[j].Date)
+	sort.Slice(snap.posts, func(i, j int) bool {
+		return snap.posts[i].Date.After(snap.posts[j].Date)
 	})
 
-	isLoaded = true
-	return nil
+	docs := searchDocuments(snap.posts)
+	snap.index = search.NewIndex(docs)
+	snap.related = search.NewRelated(docs)
+
+	return snap, nil
+}
+
+// searchDocuments returns the posts as documents for the search index and
+// related post ranking, keyed by slug
+func searchDocuments(posts []*Post) []search.Document {
+	docs := make([]search.Document, len(posts))
+	for i, post := range posts {
+		docs[i] = search.Document{
+			ID:          post.Slug,
+			Title:       post.Title,
+			Description: post.Description,
+			Tags:        post.Tags,
+			Body:        post.Content,
+		}
+	}
+	return docs
 }
 
-// loadPostFromFile loads a post from a markdown file with YAML front matter
-func loadPostFromFile(path string) (*Post, error) {
+// current returns the published snapshot, loading posts on first use
+func (s *ContentStore) current() (*snapshot, error) {
+	if snap := s.snapshot.Load(); snap != nil {
+		return snap, nil
+	}
+	if err := s.LoadPosts(); err != nil {
+		return nil, err
+	}
+	return s.snapshot.Load(), nil
+}
+
+// visible reports whether a post should currently be served. Development mode
+// serves drafts and scheduled posts so they can be previewed.
+func (s *ContentStore) visible(post *Post) bool {
+	return s.isDev || post.IsVisibleAt(s.now())
+}
+
+// visiblePosts returns the snapshot's posts that should currently be served
+func (s *ContentStore) visiblePosts(snap *snapshot) []*Post {
+	if !snap.hidden || s.isDev {
+		return snap.posts
+	}
+
+	now := s.now()
+	posts := make([]*Post, 0, len(snap.posts))
+	for _, post := range snap.posts {
+		if post.IsVisibleAt(now) {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+// loadPostFromFile loads a post from a markdown file with front matter.
+// Dates without a zone offset are interpreted in loc.
+func loadPostFromFile(path string, loc *time.Location, renderer *markdown.Renderer) (*Post, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	// Parse front matter and content
-	frontMatter, markdown, err := parseFrontMatter(string(content))
+	frontMatter, body, err := parseFrontMatter(path, string(content), loc)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing front matter: %w", err)
+		return nil, err
 	}
 
-	// Render markdown to HTML
-	htmlContent := renderMarkdown(markdown)
+	// Render markdown to HTML, including code from the snippets directory
+	snippets := os.DirFS(filepath.Join(filepath.Dir(path), SnippetsDir))
+	doc, err := renderer.RenderDocument(body, snippets)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to render markdown: %w", path, err)
+	}
 
 	post := &Post{
 		Title:       frontMatter.Title,
 		Slug:        frontMatter.Slug,
-		Date:        frontMatter.Date,
+		Date:        frontMatter.Date.Time,
 		Tags:        frontMatter.Tags,
 		Description: frontMatter.Description,
 		Published:   frontMatter.Published,
-		Content:     markdown,
-		HTMLContent: htmlContent,
+		PublishAt:   frontMatter.PublishAt.Time,
+		Updated:     frontMatter.Updated.Time,
+		Series:      strings.TrimSpace(frontMatter.Series),
+		SeriesOrder: frontMatter.SeriesOrder,
+		Related:     frontMatter.Related,
+		NoTOC:       frontMatter.TOC != nil && !*frontMatter.TOC,
+		Content:     body,
+		HTMLContent: doc.HTML,
+		Summary:     doc.Summary,
+		Headings:    doc.Headings,
+		WordCount:   doc.Words,
+		Path:        path,
+	}
+
+	// Scheduled posts without an explicit date are dated by their publish time
+	if post.Date.IsZero() {
+		post.Date = post.PublishAt
 	}
 
 	// If slug is empty, generate it from the filename
@@ -141,38 +335,24 @@ func loadPostFromFile(path string) (*Post, error) {
 	return post, nil
 }
 
-// parseFrontMatter extracts YAML front matter and markdown content from a file
-func parseFrontMatter(content string) (*FrontMatter, string, error) {
-	// Split by front matter delimiter (---)
-	parts := strings.Split(content, "---")
-	if len(parts) < 3 {
-		return nil, "", fmt.Errorf("invalid front matter format: expected at least 3 parts separated by '---'")
+// parseFrontMatter extracts the front matter and markdown content from a file.
+// name is only used to qualify error messages; errors are *FrontMatterError
+// values carrying the file line of the problem.
+func parseFrontMatter(name, content string, loc *time.Location) (*FrontMatter, string, error) {
+	format, block, startLine, body, err := extractFrontMatter(content)
+	if err != nil {
+		return nil, "", withFileName(err, name)
 	}
 
-	// Parse YAML front matter (parts[1])
 	var fm FrontMatter
-	if err := yaml.Unmarshal([]byte(parts[1]), &fm); err != nil {
-		return nil, "", fmt.Errorf("error parsing YAML: %w", err)
+	if err := decodeFrontMatter(format, block, startLine, &fm); err != nil {
+		return nil, "", withFileName(err, name)
+	}
+	if err := resolveTimestamps(&fm, startLine, loc); err != nil {
+		return nil, "", withFileName(err, name)
 	}
 
-	// The rest is the markdown content
-	markdownContent := strings.Join(parts[2:], "---")
-	markdownContent = strings.TrimSpace(markdownContent)
-
-	return &fm, markdownContent, nil
-}
-
-// renderMarkdown converts markdown to HTML
-func renderMarkdown(md string) string {
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
-	p := parser.NewWithExtensions(extensions)
-	doc := p.Parse([]byte(md))
-
-	htmlFlags := html.CommonFlags | html.HrefTargetBlank
-	opts := html.RendererOptions{Flags: htmlFlags}
-	renderer := html.NewRenderer(opts)
-
-	return string(markdown.Render(doc, renderer))
+	return &fm, strings.TrimSpace(body), nil
 }
 
 // generateSlugFromFilename extracts a slug from a filename
@@ -192,40 +372,67 @@ func generateSlugFromFilename(path string) string {
 }
 
 // GetPostBySlug returns a post by its slug
-func GetPostBySlug(slug string) (*Post, error) {
-	if !isLoaded {
-		if err := LoadPosts(); err != nil {
-			return nil, err
-		}
+func (s *ContentStore) GetPostBySlug(slug string) (*Post, error) {
+	snap, err := s.current()
+	if err != nil {
+		return nil, err
 	}
 
-	post, ok := postsMap[slug]
+	post, ok := snap.postsMap[slug]
+	if !ok || !s.visible(post) {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+
+	return post, nil
+}
+
+// GetPostBySlugIncludingDrafts returns a post by its slug whether or not it
+// is publicly visible, for editing
+func (s *ContentStore) GetPostBySlugIncludingDrafts(slug string) (*Post, error) {
+	snap, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+
+	post, ok := snap.postsMap[slug]
 	if !ok {
-		return nil, fmt.Errorf("post not found: %s", slug)
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
 	}
 
 	return post, nil
 }
 
-// GetAllPosts returns all loaded posts
-func GetAllPosts() ([]*Post, error) {
-	if !isLoaded {
-		if err := LoadPosts(); err != nil {
-			return nil, err
-		}
+// GetAllPosts returns all loaded posts. The returned slice is shared with the
+// store and must not be modified.
+func (s *ContentStore) GetAllPosts() ([]*Post, error) {
+	snap, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.visiblePosts(snap), nil
+}
+
+// GetAllPostsIncludingDrafts returns every loaded post, including drafts and
+// scheduled posts. The returned slice is shared with the store and must not
+// be modified.
+func (s *ContentStore) GetAllPostsIncludingDrafts() ([]*Post, error) {
+	snap, err := s.current()
+	if err != nil {
+		return nil, err
 	}
 
-	return posts, nil
+	return snap.posts, nil
 }
 
 // GetRecentPosts returns the N most recent posts
-func GetRecentPosts(limit int) ([]*Post, error) {
-	if !isLoaded {
-		if err := LoadPosts(); err != nil {
-			return nil, err
-		}
+func (s *ContentStore) GetRecentPosts(limit int) ([]*Post, error) {
+	snap, err := s.current()
+	if err != nil {
+		return nil, err
 	}
 
+	posts := s.visiblePosts(snap)
 	if limit > len(posts) {
 		limit = len(posts)
 	}
@@ -233,8 +440,62 @@ func GetRecentPosts(limit int) ([]*Post, error) {
 	return posts[:limit], nil
 }
 
-// Reload reloads all posts from disk (useful for hot-reload in development)
-func Reload() error {
-	isLoaded = false
-	return LoadPosts()
+// Search returns the visible posts matching query, best match first
+func (s *ContentStore) Search(query string) ([]SearchResult, error) {
+	snap, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+
+	hits := snap.index.Search(query, func(slug string) bool {
+		return s.visible(snap.postsMap[slug])
+	})
+
+	results := make([]SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = SearchResult{Post: snap.postsMap[hit.ID], Score: hit.Score}
+	}
+	return results, nil
+}
+
+// RelatedPosts returns up to limit visible posts related to the post with
+// the given slug: first those pinned in its related front matter, in order,
+// then the most similar by tags and text
+func (s *ContentStore) RelatedPosts(slug string, limit int) ([]*Post, error) {
+	snap, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	post, ok := snap.postsMap[slug]
+	if !ok || !s.visible(post) {
+		return nil, ErrPostNotFound
+	}
+	if limit <= 0 {
+		return []*Post{}, nil
+	}
+
+	related := make([]*Post, 0, limit)
+	seen := map[string]bool{slug: true}
+	add := func(slug string) {
+		p, ok := snap.postsMap[slug]
+		if !ok || seen[slug] || !s.visible(p) || len(related) == limit {
+			return
+		}
+		seen[slug] = true
+		related = append(related, p)
+	}
+
+	for _, pinned := range post.Related {
+		add(pinned)
+	}
+	for _, hit := range snap.related.Ranked(slug) {
+		add(hit.ID)
+	}
+	return related, nil
+}
+
+// Reload reloads all posts from disk (useful for hot-reload in development).
+// Readers keep seeing the previous snapshot until the new one is complete.
+func (s *ContentStore) Reload() error {
+	return s.LoadPosts()
 }
@@ -4,30 +4,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
-func TestInit(t *testing.T) {
+func TestNewContentStore(t *testing.T) {
 	dir := "test/content"
 	devMode := true
 
-	Init(dir, devMode)
+	store := NewContentStore(dir, devMode)
 
-	if contentDir != dir {
-		t.Errorf("Init failed: expected contentDir %s, got %s", dir, contentDir)
+	if store.Dir() != dir {
+		t.Errorf("NewContentStore failed: expected dir %s, got %s", dir, store.Dir())
 	}
-	if isDev != devMode {
-		t.Errorf("Init failed: expected isDev %v, got %v", devMode, isDev)
+	if store.IsDev() != devMode {
+		t.Errorf("NewContentStore failed: expected isDev %v, got %v", devMode, store.IsDev())
+	}
+
+	// An empty directory falls back to the default content path
+	if got := NewContentStore("", false).Dir(); got != "content/posts" {
+		t.Errorf("NewContentStore failed: expected default dir content/posts, got %s", got)
 	}
 }
 
 func TestLoadPosts(t *testing.T) {
 	t.Run("Non-existent content directory", func(t *testing.T) {
 		// Initialize with a non-existent directory
-		Init("nonexistent/dir", false)
+		store := NewContentStore("nonexistent/dir", false)
 
 		// Call LoadPosts should return an error
-		err := LoadPosts()
+		err := store.LoadPosts()
 		if err == nil {
 			t.Error("LoadPosts() expected error for non-existent directory, got nil")
 		}
@@ -38,16 +46,16 @@ func TestLoadPosts(t *testing.T) {
 		tempDir := t.TempDir()
 
 		// Initialize the content loader with the empty temp directory
-		Init(tempDir, false)
+		store := NewContentStore(tempDir, false)
 
 		// Call LoadPosts - should succeed but load no posts
-		err := LoadPosts()
+		err := store.LoadPosts()
 		if err != nil {
 			t.Errorf("LoadPosts() unexpected error: %v", err)
 		}
 
 		// Verify no posts were loaded
-		loadedPosts, err := GetAllPosts()
+		loadedPosts, err := store.GetAllPosts()
 		if err != nil {
 			t.Errorf("GetAllPosts() unexpected error: %v", err)
 		}
@@ -92,16 +100,16 @@ This is the content of test post 2.`
 		}
 
 		// Initialize the content loader
-		Init(tempDir, false)
+		store := NewContentStore(tempDir, false)
 
 		// Call LoadPosts
-		err := LoadPosts()
+		err := store.LoadPosts()
 		if err != nil {
 			t.Errorf("LoadPosts() unexpected error: %v", err)
 		}
 
 		// Verify posts were loaded
-		loadedPosts, err := GetAllPosts()
+		loadedPosts, err := store.GetAllPosts()
 		if err != nil {
 			t.Errorf("GetAllPosts() unexpected error: %v", err)
 		}
@@ -120,7 +128,7 @@ This is the content of test post 2.`
 		}
 
 		// Verify we can retrieve a post by slug
-		post, err := GetPostBySlug("test-post-1")
+		post, err := store.GetPostBySlug("test-post-1")
 		if err != nil {
 			t.Errorf("GetPostBySlug() unexpected error: %v", err)
 		}
@@ -163,14 +171,14 @@ Unpublished content.`
 		}
 
 		// Initialize and load posts
-		Init(tempDir, false)
-		err := LoadPosts()
+		store := NewContentStore(tempDir, false)
+		err := store.LoadPosts()
 		if err != nil {
 			t.Errorf("LoadPosts() unexpected error: %v", err)
 		}
 
 		// Verify only published post is loaded
-		loadedPosts, err := GetAllPosts()
+		loadedPosts, err := store.GetAllPosts()
 		if err != nil {
 			t.Errorf("GetAllPosts() unexpected error: %v", err)
 		}
@@ -182,6 +190,37 @@ Unpublished content.`
 		}
 	})
 
+	t.Run("Drafts are visible in dev mode", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		draftPost := `---
+title: "Draft Post"
+slug: "draft-post"
+date: 2024-01-20T10:00:00Z
+description: "This is a draft"
+published: false
+---
+
+Draft content.`
+
+		if err := os.WriteFile(filepath.Join(tempDir, "draft.md"), []byte(draftPost), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		store := NewContentStore(tempDir, true)
+		if err := store.LoadPosts(); err != nil {
+			t.Errorf("LoadPosts() unexpected error: %v", err)
+		}
+
+		post, err := store.GetPostBySlug("draft-post")
+		if err != nil {
+			t.Fatalf("GetPostBySlug() unexpected error: %v", err)
+		}
+		if post.IsVisibleAt(time.Now()) {
+			t.Error("Expected draft post not to be publicly visible")
+		}
+	})
+
 	t.Run("Invalid front matter returns error", func(t *testing.T) {
 		// Create a temporary directory
 		tempDir := t.TempDir()
@@ -199,8 +238,8 @@ Content here.`
 		}
 
 		// Initialize and try to load posts
-		Init(tempDir, false)
-		err := LoadPosts()
+		store := NewContentStore(tempDir, false)
+		err := store.LoadPosts()
 		if err == nil {
 			t.Error("LoadPosts() expected error for invalid front matter, got nil")
 		}
@@ -213,7 +252,7 @@ func TestParseFrontMatter(t *testing.T) {
 		date: 2024-01-15T10:00:00Z
 		`
 
-		_, _, err := parseFrontMatter(invalidFM)
+		_, _, err := parseFrontMatter("test.md", invalidFM, time.UTC)
 		if err == nil {
 			t.Error("parseFrontMatter() expected error for invalid format, got nil")
 		}
@@ -225,7 +264,7 @@ func TestParseFrontMatter(t *testing.T) {
 		date: invalid-date-format
 		---`
 
-		_, _, err := parseFrontMatter(invalidYAML)
+		_, _, err := parseFrontMatter("test.md", invalidYAML, time.UTC)
 		if err == nil {
 			t.Error("parseFrontMatter() expected error for invalid YAML, got nil")
 		}
@@ -239,11 +278,13 @@ date: 2024-01-15T10:00:00Z
 tags: ["test"]
 description: "This is a valid post"
 published: true
+series: "Testing"
+series_order: 3
 ---
 
 Unpublished content.`
 
-		fm, content, err := parseFrontMatter(validFM)
+		fm, content, err := parseFrontMatter("test.md", validFM, time.UTC)
 		if err != nil {
 			t.Errorf("parseFrontMatter() unexpected error: %v", err)
 		}
@@ -257,6 +298,9 @@ Unpublished content.`
 		if !fm.Published {
 			t.Error("Expected published to be true")
 		}
+		if fm.Series != "Testing" || fm.SeriesOrder != 3 {
+			t.Errorf("Expected series 'Testing' part 3, got '%s' part %d", fm.Series, fm.SeriesOrder)
+		}
 		expectedContent := "Unpublished content."
 		if content != expectedContent {
 			t.Errorf("Expected content '%s', got '%s'", expectedContent, content)
@@ -327,13 +371,13 @@ Content of post 3.`,
 	}
 
 	// Initialize and load posts
-	Init(tempDir, false)
-	if err := LoadPosts(); err != nil {
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
 		t.Fatalf("LoadPosts() unexpected error: %v", err)
 	}
 
 	// Test GetRecentPosts
-	recentPosts, err := GetRecentPosts(2)
+	recentPosts, err := store.GetRecentPosts(2)
 	if err != nil {
 		t.Errorf("GetRecentPosts() unexpected error: %v", err)
 	}
@@ -366,13 +410,13 @@ Content of initial post.`
 	}
 
 	// Initialize and load posts
-	Init(tempDir, false)
-	if err := LoadPosts(); err != nil {
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
 		t.Fatalf("LoadPosts() unexpected error: %v", err)
 	}
 
 	// Verify initial post is loaded
-	posts, err := GetAllPosts()
+	posts, err := store.GetAllPosts()
 	if err != nil {
 		t.Fatalf("GetAllPosts() unexpected error: %v", err)
 	}
@@ -396,12 +440,12 @@ Content of new post.`
 	}
 
 	// Call Reload to refresh posts
-	if err := Reload(); err != nil {
+	if err := store.Reload(); err != nil {
 		t.Fatalf("Reload() unexpected error: %v", err)
 	}
 
 	// Verify both posts are now loaded
-	posts, err = GetAllPosts()
+	posts, err = store.GetAllPosts()
 	if err != nil {
 		t.Fatalf("GetAllPosts() unexpected error: %v", err)
 	}
@@ -420,3 +464,431 @@ Content of new post.`
 		t.Fatalf("Newly added post not found after reload")
 	}
 }
+
+func TestReloadFailureKeepsSnapshot(t *testing.T) {
+	tempDir := t.TempDir()
+
+	validPost := `---
+title: "Valid Post"
+slug: "valid-post"
+date: 2024-01-10T10:00:00Z
+description: "Valid post"
+published: true
+---
+
+Valid content.`
+
+	if err := os.WriteFile(filepath.Join(tempDir, "valid.md"), []byte(validPost), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	// Add a broken post so the next reload fails
+	brokenPost := `---
+title: "Broken Post"
+date: not-a-date
+---
+
+Broken content.`
+
+	if err := os.WriteFile(filepath.Join(tempDir, "broken.md"), []byte(brokenPost), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := store.Reload(); err == nil {
+		t.Fatal("Reload() expected error for broken post, got nil")
+	}
+
+	// The previous snapshot must still be served
+	post, err := store.GetPostBySlug("valid-post")
+	if err != nil {
+		t.Fatalf("GetPostBySlug() unexpected error after failed reload: %v", err)
+	}
+	if post.Title != "Valid Post" {
+		t.Errorf("Expected 'Valid Post', got '%s'", post.Title)
+	}
+}
+
+func TestConcurrentReloadAndRead(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for i := 0; i < 5; i++ {
+		post := fmt.Sprintf(`---
+title: "Post %d"
+slug: "post-%d"
+date: 2024-01-%02dT10:00:00Z
+description: "Post %d"
+published: true
+---
+
+Content of post %d.`, i, i, i+10, i, i)
+
+		if err := os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("post%d.md", i)), []byte(post), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := store.Reload(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				posts, err := store.GetAllPosts()
+				if err != nil {
+					errs <- err
+					return
+				}
+				// Readers must always see a complete snapshot
+				if len(posts) != 5 {
+					errs <- fmt.Errorf("expected 5 posts, got %d", len(posts))
+					return
+				}
+				if _, err := store.GetPostBySlug("post-3"); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestScheduledPublishing(t *testing.T) {
+	tempDir := t.TempDir()
+
+	scheduledPost := `---
+title: "Scheduled Post"
+slug: "scheduled-post"
+publish_at: 2024-03-01T09:00:00Z
+description: "This is scheduled"
+published: true
+---
+
+Scheduled content.`
+
+	livePost := `---
+title: "Live Post"
+slug: "live-post"
+date: 2024-01-15T10:00:00Z
+description: "This is live"
+published: true
+---
+
+Live content.`
+
+	if err := os.WriteFile(filepath.Join(tempDir, "scheduled.md"), []byte(scheduledPost), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "live.md"), []byte(livePost), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Drive the store with a fake clock
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	store := NewContentStore(tempDir, false)
+	store.now = func() time.Time { return now }
+
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	t.Run("Hidden before publish_at", func(t *testing.T) {
+		posts, err := store.GetAllPosts()
+		if err != nil {
+			t.Fatalf("GetAllPosts() unexpected error: %v", err)
+		}
+		if len(posts) != 1 || posts[0].Slug != "live-post" {
+			t.Errorf("Expected only 'live-post', got %d posts", len(posts))
+		}
+		if _, err := store.GetPostBySlug("scheduled-post"); err == nil {
+			t.Error("GetPostBySlug() expected error for scheduled post, got nil")
+		}
+	})
+
+	t.Run("Visible after publish_at without reload", func(t *testing.T) {
+		now = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+		posts, err := store.GetAllPosts()
+		if err != nil {
+			t.Fatalf("GetAllPosts() unexpected error: %v", err)
+		}
+		if len(posts) != 2 {
+			t.Fatalf("Expected 2 posts, got %d", len(posts))
+		}
+		// Scheduled post is dated by publish_at and sorts first
+		if posts[0].Slug != "scheduled-post" {
+			t.Errorf("Expected 'scheduled-post' first, got '%s'", posts[0].Slug)
+		}
+		if !posts[0].Date.Equal(posts[0].PublishAt) {
+			t.Errorf("Expected date to default to publish_at, got %v", posts[0].Date)
+		}
+
+		recent, err := store.GetRecentPosts(1)
+		if err != nil {
+			t.Fatalf("GetRecentPosts() unexpected error: %v", err)
+		}
+		if len(recent) != 1 || recent[0].Slug != "scheduled-post" {
+			t.Errorf("GetRecentPosts() returned incorrect posts")
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	tempDir := t.TempDir()
+
+	livePost := `---
+title: "Tracing Services"
+slug: "tracing-services"
+date: 2024-01-15T10:00:00Z
+tags: ["observability"]
+description: "This is live"
+published: true
+---
+
+Following requests through services.`
+
+	scheduledPost := `---
+title: "Scheduled Tracing"
+slug: "scheduled-tracing"
+publish_at: 2024-03-01T09:00:00Z
+description: "This is scheduled"
+published: true
+---
+
+More traces.`
+
+	if err := os.WriteFile(filepath.Join(tempDir, "live.md"), []byte(livePost), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "scheduled.md"), []byte(scheduledPost), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	store := NewContentStore(tempDir, false)
+	store.now = func() time.Time { return now }
+
+	t.Run("Scheduled posts are not returned", func(t *testing.T) {
+		results, err := store.Search("traced")
+		if err != nil {
+			t.Fatalf("Search() unexpected error: %v", err)
+		}
+		if len(results) != 1 || results[0].Post.Slug != "tracing-services" {
+			t.Errorf("Expected only 'tracing-services', got %d results", len(results))
+		}
+		if results[0].Score <= 0 {
+			t.Errorf("Expected a positive score, got %v", results[0].Score)
+		}
+	})
+
+	t.Run("Index is rebuilt on reload", func(t *testing.T) {
+		newPost := `---
+title: "Kubernetes"
+slug: "kubernetes"
+date: 2024-01-20T10:00:00Z
+description: "Clusters"
+published: true
+---
+
+Running a cluster.`
+		if err := os.WriteFile(filepath.Join(tempDir, "kubernetes.md"), []byte(newPost), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		results, _ := store.Search("cluster")
+		if len(results) != 0 {
+			t.Fatalf("Expected no results before reload, got %d", len(results))
+		}
+
+		if err := store.Reload(); err != nil {
+			t.Fatalf("Reload() unexpected error: %v", err)
+		}
+		results, err := store.Search("clusters")
+		if err != nil {
+			t.Fatalf("Search() unexpected error: %v", err)
+		}
+		if len(results) != 1 || results[0].Post.Slug != "kubernetes" {
+			t.Errorf("Expected 'kubernetes' after reload, got %d results", len(results))
+		}
+	})
+}
+
+func TestLoadPostsWithSnippets(t *testing.T) {
+	tempDir := t.TempDir()
+	snippetsDir := filepath.Join(tempDir, SnippetsDir)
+	if err := os.Mkdir(snippetsDir, 0755); err != nil {
+		t.Fatalf("Failed to create snippets directory: %v", err)
+	}
+
+	post := "---\ntitle: \"Snippets\"\nslug: \"snippets\"\ndate: 2024-01-15T10:00:00Z\ndescription: \"Includes code\"\npublished: true\n---\n\n" +
+		"```go {include=\"main.go\" region=\"hello\" title=\"main.go\"}\n```\n"
+	files := map[string]string{
+		filepath.Join(tempDir, "snippets.md"): post,
+		filepath.Join(snippetsDir, "main.go"): "package main\n\nfunc main() {\n\t// region: hello\n\tprintln(\"hello from the snippet\")\n\t// endregion\n}\n",
+		// Markdown in the snippets directory is not a post
+		filepath.Join(snippetsDir, "notes.md"): "# Not a post",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	posts, _ := store.GetAllPosts()
+	if len(posts) != 1 {
+		t.Fatalf("Expected 1 post, got %d", len(posts))
+	}
+	if !strings.Contains(posts[0].HTMLContent, "hello from the snippet") || strings.Contains(posts[0].HTMLContent, "region") {
+		t.Errorf("Expected the snippet region in the post, got %s", posts[0].HTMLContent)
+	}
+
+	// A missing include fails the load like any other broken post
+	if err := os.Remove(filepath.Join(snippetsDir, "main.go")); err != nil {
+		t.Fatal(err)
+	}
+	err := store.Reload()
+	if err == nil || !strings.Contains(err.Error(), "snippets.md") || !strings.Contains(err.Error(), "main.go") {
+		t.Errorf("Reload() expected an include error naming the post and snippet, got %v", err)
+	}
+}
+
+func TestLoadPostsOutline(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"with-toc.md": "---\ntitle: \"With TOC\"\ndate: 2024-01-15T10:00:00Z\npublished: true\n---\n\n" +
+			"# With TOC\n\n## First section\n\nOne two three.\n\n### Nested\n\nFour five.\n",
+		"no-toc.md": "---\ntitle: \"No TOC\"\ndate: 2024-01-16T10:00:00Z\npublished: true\ntoc: false\n---\n\n## Only section\n\nText.\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	post, err := store.GetPostBySlug("with-toc")
+	if err != nil {
+		t.Fatalf("GetPostBySlug() unexpected error: %v", err)
+	}
+	if post.NoTOC || len(post.Headings) != 3 || post.Headings[1].ID != "first-section" || post.Headings[2].Level != 3 {
+		t.Errorf("Unexpected headings %+v (NoTOC %v)", post.Headings, post.NoTOC)
+	}
+	if post.WordCount != 10 {
+		t.Errorf("Expected 10 words, got %d", post.WordCount)
+	}
+	if post.Summary != "<p>One two three.</p>\n<p>Four five.</p>\n" {
+		t.Errorf("Unexpected summary %q", post.Summary)
+	}
+
+	post, err = store.GetPostBySlug("no-toc")
+	if err != nil {
+		t.Fatalf("GetPostBySlug() unexpected error: %v", err)
+	}
+	if !post.NoTOC {
+		t.Error("Expected toc: false to set NoTOC")
+	}
+}
+
+func TestLoadPostsSummaryMarker(t *testing.T) {
+	tempDir := t.TempDir()
+	data := "---\ntitle: \"Excerpt\"\ndate: 2024-01-15T10:00:00Z\npublished: true\n---\n\n" +
+		"Shown in *listings*.\n\n<!--more-->\n\nOnly on the post page.\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "excerpt.md"), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+	post, err := store.GetPostBySlug("excerpt")
+	if err != nil {
+		t.Fatalf("GetPostBySlug() unexpected error: %v", err)
+	}
+	if post.Summary != "<p>Shown in <em>listings</em>.</p>\n" {
+		t.Errorf("Unexpected summary %q", post.Summary)
+	}
+	if !strings.Contains(post.HTMLContent, "Only on the post page.") {
+		t.Errorf("Expected the full content in HTMLContent, got %q", post.HTMLContent)
+	}
+}
+
+func TestRelatedPosts(t *testing.T) {
+	tempDir := t.TempDir()
+	post := func(slug, tags, extra, body string) string {
+		return "---\ntitle: \"" + slug + "\"\nslug: \"" + slug + "\"\ndate: 2024-01-15T10:00:00Z\ntags: [" + tags + "]\npublished: true\n" + extra + "---\n\n" + body + "\n"
+	}
+	files := map[string]string{
+		"a.md":      post("a", "Go, Testing", "related: [\"pinned\", \"missing\", \"a\"]\n", "Table driven tests in Go."),
+		"b.md":      post("b", "go, testing", "", "Fuzz tests in Go."),
+		"c.md":      post("c", "Go", "", "Generics in Go."),
+		"pinned.md": post("pinned", "Cooking", "", "Nothing in common."),
+		"draft.md":  strings.Replace(post("draft", "Go, Testing", "", "Table driven tests in Go."), "published: true", "published: false", 1),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	store := NewContentStore(tempDir, false)
+	related, err := store.RelatedPosts("a", 3)
+	if err != nil {
+		t.Fatalf("RelatedPosts() unexpected error: %v", err)
+	}
+	var slugs []string
+	for _, p := range related {
+		slugs = append(slugs, p.Slug)
+	}
+	// Pins come first; missing slugs, the post itself and drafts are skipped
+	if strings.Join(slugs, ",") != "pinned,b,c" {
+		t.Errorf("RelatedPosts(a) = %v, want [pinned b c]", slugs)
+	}
+
+	if related, _ := store.RelatedPosts("a", 1); len(related) != 1 || related[0].Slug != "pinned" {
+		t.Errorf("RelatedPosts(a, 1) = %v, want only the pin", related)
+	}
+	if _, err := store.RelatedPosts("draft", 3); err != ErrPostNotFound {
+		t.Errorf("RelatedPosts(draft) expected ErrPostNotFound, got %v", err)
+	}
+}
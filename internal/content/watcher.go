@@ -0,0 +1,177 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is how long the watcher waits after the last change before reloading
+const DefaultDebounce = 250 * time.Millisecond
+
+// configMapDataLink is the symlink Kubernetes atomically swaps when a mounted
+// ConfigMap or Secret volume is updated
+const configMapDataLink = "..data"
+
+// Watcher watches a ContentStore's directory and reloads the store whenever a
+// markdown file or an included snippet is added, changed or removed. Bursts of events (editor saves,
+// ConfigMap updates) are debounced into a single reload.
+type Watcher struct {
+	store    *ContentStore
+	debounce time.Duration
+	fsw      *fsnotify.Watcher
+	watched  map[string]bool
+
+	// onReload is called after every reload attempt; used by tests
+	onReload func(error)
+}
+
+// NewWatcher creates a Watcher for the given store. A non-positive debounce
+// uses DefaultDebounce.
+func NewWatcher(store *ContentStore, debounce time.Duration) (*Watcher, error) {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	w := &Watcher{
+		store:    store,
+		debounce: debounce,
+		fsw:      fsw,
+		watched:  make(map[string]bool),
+	}
+
+	if err := w.addTree(store.Dir()); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Run processes file system events until ctx is cancelled. The underlying
+// watcher is closed when Run returns.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer w.fsw.Close()
+
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			if w.handleEvent(event) {
+				timer.Reset(w.debounce)
+			}
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Content watcher error: %v", err)
+
+		case <-timer.C:
+			err := w.store.Reload()
+			if err != nil {
+				log.Printf("Failed to reload posts from %s: %v", w.store.Dir(), err)
+			} else {
+				log.Printf("Reloaded posts from %s", w.store.Dir())
+			}
+			if w.onReload != nil {
+				w.onReload(err)
+			}
+		}
+	}
+}
+
+// handleEvent updates the watch list for the event and reports whether it
+// should trigger a reload
+func (w *Watcher) handleEvent(event fsnotify.Event) bool {
+	name := filepath.Base(event.Name)
+
+	// Kubernetes swaps the ..data symlink to publish a new ConfigMap revision
+	if name == configMapDataLink {
+		return event.Has(fsnotify.Create)
+	}
+
+	// Newly created directories need their own watch (fsnotify is not recursive)
+	if event.Has(fsnotify.Create) && !isHidden(name) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := w.addTree(event.Name); err != nil {
+				log.Printf("Content watcher error: %v", err)
+			}
+			return true
+		}
+	}
+
+	// Removed or renamed directories drop out of the watch list
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		if w.watched[event.Name] {
+			delete(w.watched, event.Name)
+			return true
+		}
+	}
+
+	if isHidden(name) || !(strings.HasSuffix(name, ".md") || inSnippets(event.Name)) {
+		return false
+	}
+
+	return event.Has(fsnotify.Create) || event.Has(fsnotify.Write) ||
+		event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)
+}
+
+// addTree watches dir and all non-hidden directories beneath it
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && isHidden(d.Name()) {
+			return filepath.SkipDir
+		}
+		if w.watched[path] {
+			return nil
+		}
+		if err := w.fsw.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		w.watched[path] = true
+		return nil
+	})
+}
+
+// inSnippets reports whether path is inside a snippets directory
+func inSnippets(path string) bool {
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if dir == SnippetsDir {
+			return true
+		}
+	}
+	return false
+}
+
+// isHidden reports whether a file or directory name is hidden. This also
+// covers the ..data and ..<timestamp> entries of ConfigMap volumes.
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
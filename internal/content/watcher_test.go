@@ -0,0 +1,283 @@
+package content
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func watcherTestPost(title, slug string) string {
+	return fmt.Sprintf(`---
+title: "%s"
+slug: "%s"
+date: 2024-01-15T10:00:00Z
+description: "Watcher test post"
+published: true
+---
+
+Content of %s.`, title, slug, title)
+}
+
+// startWatcher runs a watcher for the store and returns a channel that
+// receives the result of every reload
+func startWatcher(t *testing.T, store *ContentStore) <-chan error {
+	t.Helper()
+
+	w, err := NewWatcher(store, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() unexpected error: %v", err)
+	}
+
+	reloads := make(chan error, 10)
+	w.onReload = func(err error) { reloads <- err }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return reloads
+}
+
+// waitForPosts waits until the store holds the expected number of posts
+func waitForPosts(t *testing.T, store *ContentStore, reloads <-chan error, want int) []*Post {
+	t.Helper()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-reloads:
+			if err != nil {
+				t.Fatalf("Reload() unexpected error: %v", err)
+			}
+			posts, err := store.GetAllPosts()
+			if err != nil {
+				t.Fatalf("GetAllPosts() unexpected error: %v", err)
+			}
+			if len(posts) == want {
+				return posts
+			}
+		case <-deadline:
+			posts, _ := store.GetAllPosts()
+			t.Fatalf("Timed out waiting for %d posts, have %d", want, len(posts))
+		}
+	}
+}
+
+func TestWatcherReloadsOnChange(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "first.md"), []byte(watcherTestPost("First", "first")), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	store := NewContentStore(tempDir, true)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+	reloads := startWatcher(t, store)
+
+	t.Run("Added post is loaded", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(tempDir, "second.md"), []byte(watcherTestPost("Second", "second")), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		waitForPosts(t, store, reloads, 2)
+	})
+
+	t.Run("Post in new subdirectory is loaded", func(t *testing.T) {
+		subDir := filepath.Join(tempDir, "2024")
+		if err := os.Mkdir(subDir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		// Give the watcher a chance to pick up the new directory
+		waitForPosts(t, store, reloads, 2)
+
+		if err := os.WriteFile(filepath.Join(subDir, "third.md"), []byte(watcherTestPost("Third", "third")), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		waitForPosts(t, store, reloads, 3)
+	})
+
+	t.Run("Removed post is dropped", func(t *testing.T) {
+		if err := os.Remove(filepath.Join(tempDir, "second.md")); err != nil {
+			t.Fatalf("Failed to remove test file: %v", err)
+		}
+		waitForPosts(t, store, reloads, 2)
+
+		if _, err := store.GetPostBySlug("second"); err == nil {
+			t.Error("GetPostBySlug() expected error for removed post, got nil")
+		}
+	})
+}
+
+func TestWatcherDebouncesBursts(t *testing.T) {
+	tempDir := t.TempDir()
+
+	store := NewContentStore(tempDir, true)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	w, err := NewWatcher(store, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() unexpected error: %v", err)
+	}
+	reloads := make(chan error, 10)
+	w.onReload = func(err error) { reloads <- err }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// Several rapid writes should collapse into a single reload
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(tempDir, fmt.Sprintf("post%d.md", i))
+		if err := os.WriteFile(name, []byte(watcherTestPost(fmt.Sprintf("Post %d", i), fmt.Sprintf("post-%d", i))), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	select {
+	case err := <-reloads:
+		if err != nil {
+			t.Fatalf("Reload() unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for reload")
+	}
+
+	select {
+	case <-reloads:
+		t.Error("Expected a single debounced reload, got more")
+	case <-time.After(400 * time.Millisecond):
+	}
+
+	posts, err := store.GetAllPosts()
+	if err != nil {
+		t.Fatalf("GetAllPosts() unexpected error: %v", err)
+	}
+	if len(posts) != 5 {
+		t.Errorf("Expected 5 posts, got %d", len(posts))
+	}
+}
+
+func TestWatcherConfigMapSymlinkSwap(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Lay out the directory the way the kubelet mounts a ConfigMap volume:
+	//   ..<revision>/post.md, ..data -> ..<revision>, post.md -> ..data/post.md
+	writeRevision := func(revision, title string) {
+		revDir := filepath.Join(tempDir, revision)
+		if err := os.Mkdir(revDir, 0755); err != nil {
+			t.Fatalf("Failed to create revision directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(revDir, "post.md"), []byte(watcherTestPost(title, "post")), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	writeRevision("..2024_01_01_00_00_00.1", "Original")
+	if err := os.Symlink("..2024_01_01_00_00_00.1", filepath.Join(tempDir, "..data")); err != nil {
+		t.Fatalf("Failed to create ..data symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "post.md"), filepath.Join(tempDir, "post.md")); err != nil {
+		t.Fatalf("Failed to create post symlink: %v", err)
+	}
+
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	// The revision directory must not be loaded a second time
+	posts, err := store.GetAllPosts()
+	if err != nil {
+		t.Fatalf("GetAllPosts() unexpected error: %v", err)
+	}
+	if len(posts) != 1 || posts[0].Title != "Original" {
+		t.Fatalf("Expected only the 'Original' post, got %d posts", len(posts))
+	}
+
+	reloads := startWatcher(t, store)
+
+	// Publish a new revision by atomically swapping ..data
+	writeRevision("..2024_01_02_00_00_00.2", "Updated")
+	if err := os.Symlink("..2024_01_02_00_00_00.2", filepath.Join(tempDir, "..data_tmp")); err != nil {
+		t.Fatalf("Failed to create ..data_tmp symlink: %v", err)
+	}
+	if err := os.Rename(filepath.Join(tempDir, "..data_tmp"), filepath.Join(tempDir, "..data")); err != nil {
+		t.Fatalf("Failed to swap ..data symlink: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(tempDir, "..2024_01_01_00_00_00.1")); err != nil {
+		t.Fatalf("Failed to remove old revision: %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-reloads:
+			if err != nil {
+				continue
+			}
+			post, err := store.GetPostBySlug("post")
+			if err == nil && post.Title == "Updated" {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Timed out waiting for ConfigMap update to be reloaded")
+		}
+	}
+}
+
+func TestWatcherReloadsOnSnippetChange(t *testing.T) {
+	tempDir := t.TempDir()
+	snippetsDir := filepath.Join(tempDir, SnippetsDir)
+	if err := os.Mkdir(snippetsDir, 0755); err != nil {
+		t.Fatalf("Failed to create snippets directory: %v", err)
+	}
+
+	post := watcherTestPost("Snippet", "snippet") + "\n\n```go {include=\"main.go\"}\n```\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "snippet.md"), []byte(post), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(snippetsDir, "main.go"), []byte("first()\n"), 0644); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+
+	store := NewContentStore(tempDir, true)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+	reloads := startWatcher(t, store)
+
+	if err := os.WriteFile(filepath.Join(snippetsDir, "main.go"), []byte("second()\n"), 0644); err != nil {
+		t.Fatalf("Failed to update snippet: %v", err)
+	}
+
+	select {
+	case err := <-reloads:
+		if err != nil {
+			t.Fatalf("Reload() unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a reload after the snippet changed")
+	}
+
+	got, err := store.GetPostBySlug("snippet")
+	if err != nil {
+		t.Fatalf("GetPostBySlug() unexpected error: %v", err)
+	}
+	if !strings.Contains(got.HTMLContent, "second") {
+		t.Errorf("Expected the updated snippet, got %s", got.HTMLContent)
+	}
+}
@@ -0,0 +1,426 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// postFrontMatter is the front matter written for a post. Optional fields
+// are omitted when unset so written files match hand-written ones.
+type postFrontMatter struct {
+	Title       string     `yaml:"title" toml:"title" json:"title"`
+	Slug        string     `yaml:"slug" toml:"slug" json:"slug"`
+	Date        time.Time  `yaml:"date" toml:"date" json:"date"`
+	Tags        []string   `yaml:"tags,flow" toml:"tags" json:"tags"`
+	Description string     `yaml:"description" toml:"description" json:"description"`
+	Published   bool       `yaml:"published" toml:"published" json:"published"`
+	PublishAt   *time.Time `yaml:"publish_at,omitempty" toml:"publish_at,omitempty" json:"publish_at,omitempty"`
+	Updated     *time.Time `yaml:"updated,omitempty" toml:"updated,omitempty" json:"updated,omitempty"`
+	Series      string     `yaml:"series,omitempty" toml:"series,omitempty" json:"series,omitempty"`
+	SeriesOrder int        `yaml:"series_order,omitempty" toml:"series_order,omitempty" json:"series_order,omitempty"`
+	Related     []string   `yaml:"related,omitempty,flow" toml:"related,omitempty" json:"related,omitempty"`
+	TOC         *bool      `yaml:"toc,omitempty" toml:"toc,omitempty" json:"toc,omitempty"`
+}
+
+// postFrontMatterKeys are the keys of postFrontMatter. Rewriting a file
+// replaces or removes these and keeps any others.
+var postFrontMatterKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(postFrontMatter{})
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		keys[key] = true
+	}
+	return keys
+}()
+
+// newPostFrontMatter returns the front matter for a post. Times are
+// written to the second, as they would be by hand.
+func newPostFrontMatter(post *Post) postFrontMatter {
+	fm := postFrontMatter{
+		Title:       post.Title,
+		Slug:        post.Slug,
+		Date:        post.Date.Truncate(time.Second),
+		Tags:        post.Tags,
+		Description: post.Description,
+		Published:   post.Published,
+		Series:      post.Series,
+		SeriesOrder: post.SeriesOrder,
+		Related:     post.Related,
+	}
+	if fm.Tags == nil {
+		fm.Tags = []string{}
+	}
+	if !post.PublishAt.IsZero() {
+		publishAt := post.PublishAt.Truncate(time.Second)
+		fm.PublishAt = &publishAt
+	}
+	if !post.Updated.IsZero() {
+		updated := post.Updated.Truncate(time.Second)
+		fm.Updated = &updated
+	}
+	if post.NoTOC {
+		toc := false
+		fm.TOC = &toc
+	}
+	return fm
+}
+
+// MarshalPost renders a post as a markdown file with YAML front matter
+func MarshalPost(post *Post) ([]byte, error) {
+	fm := newPostFrontMatter(post)
+	block, err := encodeYAML(fm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode front matter: %w", err)
+	}
+	return joinPost(formatYAML, block, post.Content), nil
+}
+
+// rewritePost renders a post as a replacement for original, the file it
+// was loaded from. The front matter keeps the original's format and any
+// keys the post does not cover, such as ones used by other tools. YAML
+// keeps its key order and comments; TOML and JSON keys are sorted.
+func rewritePost(original []byte, post *Post) ([]byte, error) {
+	format, block, startLine, _, err := extractFrontMatter(string(original))
+	if err != nil {
+		return nil, err
+	}
+	fm := newPostFrontMatter(post)
+
+	var out []byte
+	switch format {
+	case formatYAML:
+		out, err = rewriteYAML(block, fm)
+	case formatTOML:
+		out, err = rewriteTOML(block, fm)
+	case formatJSON:
+		out, err = rewriteJSON(block, fm)
+	}
+	if err != nil {
+		var fmErr *FrontMatterError
+		if errors.As(err, &fmErr) {
+			return nil, err
+		}
+		return nil, &FrontMatterError{Line: startLine, Msg: fmt.Sprintf("failed to rewrite %s front matter: %v", format, err), Err: err}
+	}
+	return joinPost(format, out, post.Content), nil
+}
+
+// rewriteYAML sets the post's keys in a YAML front matter block. Other
+// keys keep their place, and replaced keys their comments.
+func rewriteYAML(block string, fm postFrontMatter) ([]byte, error) {
+	var post yaml.Node
+	if err := post.Encode(fm); err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(block), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return encodeYAML(&post)
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, errors.New("front matter is not a mapping")
+	}
+
+	values := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(post.Content); i += 2 {
+		values[post.Content[i].Value] = post.Content[i+1]
+	}
+
+	content := make([]*yaml.Node, 0, len(mapping.Content)+len(post.Content))
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if postFrontMatterKeys[key.Value] {
+			replacement, ok := values[key.Value]
+			if !ok {
+				continue // An optional key the post no longer sets
+			}
+			replacement.LineComment = value.LineComment
+			value = replacement
+			delete(values, key.Value)
+		}
+		content = append(content, key, value)
+	}
+	// Keys the file did not have go last, in the usual order
+	for i := 0; i+1 < len(post.Content); i += 2 {
+		if value, ok := values[post.Content[i].Value]; ok {
+			content = append(content, post.Content[i], value)
+		}
+	}
+	mapping.Content = content
+
+	return encodeYAML(mapping)
+}
+
+// rewriteTOML sets the post's keys in a TOML front matter block
+func rewriteTOML(block string, fm postFrontMatter) ([]byte, error) {
+	data, err := toml.Marshal(fm)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := toml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]any)
+	if err := toml.Unmarshal([]byte(block), &merged); err != nil {
+		return nil, err
+	}
+	for key := range postFrontMatterKeys {
+		if value, ok := values[key]; ok {
+			merged[key] = value
+		} else {
+			delete(merged, key)
+		}
+	}
+	return toml.Marshal(merged)
+}
+
+// rewriteJSON sets the post's keys in a JSON front matter object. Other
+// values are kept exactly as written.
+func rewriteJSON(block string, fm postFrontMatter) ([]byte, error) {
+	data, err := json.Marshal(fm)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(block), &merged); err != nil {
+		return nil, err
+	}
+	for key := range postFrontMatterKeys {
+		if value, ok := values[key]; ok {
+			merged[key] = value
+		} else {
+			delete(merged, key)
+		}
+	}
+	out, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// encodeYAML encodes v as YAML indented by two spaces
+func encodeYAML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// joinPost puts an encoded front matter block, which ends in a newline,
+// and the markdown content together as a post file
+func joinPost(format frontMatterFormat, block []byte, content string) []byte {
+	var buf bytes.Buffer
+	switch format {
+	case formatYAML:
+		buf.WriteString("---\n")
+		buf.Write(block)
+		buf.WriteString("---\n")
+	case formatTOML:
+		buf.WriteString("+++\n")
+		buf.Write(block)
+		buf.WriteString("+++\n")
+	case formatJSON:
+		buf.Write(block)
+	}
+	buf.WriteString("\n")
+	buf.WriteString(strings.TrimSpace(content))
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// CreatePost writes a new post to the content directory as
+// YYYY-MM-DD-<slug>.md and reloads. It returns ErrPostExists if a post
+// already has the slug. If the reload fails the file is removed again.
+func (s *ContentStore) CreatePost(post *Post) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	if _, err := s.lookupLocked(post.Slug); err == nil {
+		return fmt.Errorf("%w: %s", ErrPostExists, post.Slug)
+	} else if !errors.Is(err, ErrPostNotFound) {
+		return err
+	}
+
+	path := filepath.Join(s.dir, post.Date.Format("2006-01-02")+"-"+post.Slug+".md")
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%w: %s", ErrPostExists, path)
+	}
+
+	data, err := MarshalPost(post)
+	if err != nil {
+		return err
+	}
+	if err := writePostFile(path, data, 0644); err != nil {
+		return err
+	}
+	if err := s.reloadLocked(); err != nil {
+		return errors.Join(err, removePostFile(path))
+	}
+	return nil
+}
+
+// UpdatePost rewrites the file of the post with the given slug and reloads.
+// The post keeps its file, and the file its front matter format, even if
+// the slug changes. If the reload fails the old file is put back.
+func (s *ContentStore) UpdatePost(slug string, post *Post) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	existing, err := s.lookupLocked(slug)
+	if err != nil {
+		return err
+	}
+	if post.Slug != slug {
+		if _, err := s.lookupLocked(post.Slug); err == nil {
+			return fmt.Errorf("%w: %s", ErrPostExists, post.Slug)
+		}
+	}
+
+	original, perm, err := readPostFile(existing.Path)
+	if err != nil {
+		return err
+	}
+	data, err := rewritePost(original, post)
+	if err != nil {
+		return withFileName(err, existing.Path)
+	}
+	if err := writePostFile(existing.Path, data, perm); err != nil {
+		return err
+	}
+	if err := s.reloadLocked(); err != nil {
+		return errors.Join(err, writePostFile(existing.Path, original, perm))
+	}
+	return nil
+}
+
+// DeletePost removes the file of the post with the given slug and reloads.
+// If the reload fails the file is put back.
+func (s *ContentStore) DeletePost(slug string) error {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	existing, err := s.lookupLocked(slug)
+	if err != nil {
+		return err
+	}
+
+	original, perm, err := readPostFile(existing.Path)
+	if err != nil {
+		return err
+	}
+	if err := removePostFile(existing.Path); err != nil {
+		return err
+	}
+	if err := s.reloadLocked(); err != nil {
+		return errors.Join(err, writePostFile(existing.Path, original, perm))
+	}
+	return nil
+}
+
+// lookupLocked finds a post by slug in the current snapshot, loading it if
+// needed. loadMu must be held.
+func (s *ContentStore) lookupLocked(slug string) (*Post, error) {
+	snap := s.snapshot.Load()
+	if snap == nil {
+		var err error
+		if snap, err = s.buildSnapshot(); err != nil {
+			return nil, err
+		}
+		s.snapshot.Store(snap)
+	}
+
+	post, ok := snap.postsMap[slug]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, slug)
+	}
+	return post, nil
+}
+
+// reloadLocked rebuilds and publishes the snapshot. loadMu must be held.
+func (s *ContentStore) reloadLocked() error {
+	snap, err := s.buildSnapshot()
+	if err != nil {
+		return err
+	}
+	s.snapshot.Store(snap)
+	return nil
+}
+
+// readPostFile returns the contents and permissions of a post file, so it
+// can be restored
+func readPostFile(path string) ([]byte, os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read post: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read post: %w", err)
+	}
+	return data, info.Mode().Perm(), nil
+}
+
+// removePostFile deletes a post file
+func removePostFile(path string) error {
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete post: %w", err)
+	}
+	return nil
+}
+
+// writePostFile writes data to path atomically. The data goes to a hidden
+// temporary file in the same directory, which the loader and watcher ignore,
+// and is then renamed over path.
+func writePostFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".post-*.md")
+	if err != nil {
+		return fmt.Errorf("failed to write post: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write post: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write post: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write post: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write post: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package content
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalPost(t *testing.T) {
+	post := &Post{
+		Title:       `Quotes: "and" colons`,
+		Slug:        "quotes",
+		Date:        time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		Tags:        []string{"Go", "Testing"},
+		Description: "A description",
+		Published:   true,
+		Content:     "# Heading\n\nBody text.\n\n",
+	}
+
+	data, err := MarshalPost(post)
+	if err != nil {
+		t.Fatalf("MarshalPost() unexpected error: %v", err)
+	}
+
+	want := `---
+title: 'Quotes: "and" colons'
+slug: quotes
+date: 2024-01-15T10:00:00Z
+tags: [Go, Testing]
+description: A description
+published: true
+---
+
+# Heading
+
+Body text.
+`
+	if string(data) != want {
+		t.Errorf("MarshalPost() =\n%s\nwant\n%s", data, want)
+	}
+
+	// The written file must load back to the same post
+	fm, body, err := parseFrontMatter("quotes.md", string(data), time.UTC)
+	if err != nil {
+		t.Fatalf("parseFrontMatter() unexpected error: %v", err)
+	}
+	if fm.Title != post.Title || !fm.Date.Time.Equal(post.Date) || len(fm.Tags) != 2 || body != "# Heading\n\nBody text." {
+		t.Errorf("Round trip mismatch: %+v, body %q", fm, body)
+	}
+	if strings.Contains(string(data), "publish_at") || strings.Contains(string(data), "updated") || strings.Contains(string(data), "toc") {
+		t.Error("Expected unset optional fields to be omitted")
+	}
+
+	// Series, related pins and a hidden table of contents survive a rewrite
+	post.NoTOC = true
+	post.Series = "Go Testing"
+	post.SeriesOrder = 2
+	post.Related = []string{"other-post"}
+	data, err = MarshalPost(post)
+	if err != nil {
+		t.Fatalf("MarshalPost() unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), "\nseries: Go Testing\nseries_order: 2\nrelated: [other-post]\ntoc: false\n") {
+		t.Errorf("Expected series and toc: false in\n%s", data)
+	}
+	fm, _, err = parseFrontMatter("quotes.md", string(data), time.UTC)
+	if err != nil || fm.TOC == nil || *fm.TOC || fm.Series != post.Series || fm.SeriesOrder != 2 || len(fm.Related) != 1 {
+		t.Errorf("Round trip lost series, related or toc: false: %+v, %v", fm, err)
+	}
+}
+
+func TestContentStoreWrites(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	draft := &Post{
+		Title:       "New Draft",
+		Slug:        "new-draft",
+		Date:        time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		Description: "Draft description",
+		Content:     "Draft body.",
+	}
+
+	t.Run("Create writes a dated file and reloads", func(t *testing.T) {
+		if err := store.CreatePost(draft); err != nil {
+			t.Fatalf("CreatePost() unexpected error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "2024-03-01-new-draft.md")); err != nil {
+			t.Errorf("Expected post file to exist: %v", err)
+		}
+
+		// Drafts are loaded for editing but not served
+		if _, err := store.GetPostBySlug("new-draft"); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("GetPostBySlug() expected ErrPostNotFound for draft, got %v", err)
+		}
+		post, err := store.GetPostBySlugIncludingDrafts("new-draft")
+		if err != nil {
+			t.Fatalf("GetPostBySlugIncludingDrafts() unexpected error: %v", err)
+		}
+		if post.HTMLContent != "<p>Draft body.</p>\n" {
+			t.Errorf("Unexpected HTML content %q", post.HTMLContent)
+		}
+
+		if err := store.CreatePost(draft); !errors.Is(err, ErrPostExists) {
+			t.Errorf("CreatePost() expected ErrPostExists for duplicate slug, got %v", err)
+		}
+	})
+
+	t.Run("Update rewrites the file in place", func(t *testing.T) {
+		updated := *draft
+		updated.Published = true
+		updated.Content = "Updated body."
+		if err := store.UpdatePost("new-draft", &updated); err != nil {
+			t.Fatalf("UpdatePost() unexpected error: %v", err)
+		}
+
+		post, err := store.GetPostBySlug("new-draft")
+		if err != nil {
+			t.Fatalf("GetPostBySlug() unexpected error: %v", err)
+		}
+		if post.Content != "Updated body." {
+			t.Errorf("Expected updated content, got %q", post.Content)
+		}
+
+		entries, _ := os.ReadDir(tempDir)
+		if len(entries) != 1 {
+			t.Errorf("Expected a single file with no leftover temporary files, got %d entries", len(entries))
+		}
+
+		if err := store.UpdatePost("missing", &updated); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("UpdatePost() expected ErrPostNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Delete removes the file", func(t *testing.T) {
+		if err := store.DeletePost("new-draft"); err != nil {
+			t.Fatalf("DeletePost() unexpected error: %v", err)
+		}
+		posts, err := store.GetAllPostsIncludingDrafts()
+		if err != nil {
+			t.Fatalf("GetAllPostsIncludingDrafts() unexpected error: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("Expected no posts after delete, got %d", len(posts))
+		}
+		if err := store.DeletePost("new-draft"); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("DeletePost() expected ErrPostNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRewritePost(t *testing.T) {
+	post := &Post{
+		Title:   "New Title",
+		Slug:    "rewritten",
+		Date:    time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		Tags:    []string{"go"},
+		Content: "New body.",
+	}
+
+	tests := []struct {
+		name     string
+		original string
+		prefix   string
+		keep     []string
+	}{
+		{
+			name:     "YAML keeps unknown keys and comments",
+			original: "---\ntitle: Old # shown in listings\nweight: 3\ntoc: false\n---\nOld body.\n",
+			prefix:   "---\ntitle: New Title # shown in listings\nweight: 3\nslug: rewritten\n",
+			keep:     []string{"weight: 3"},
+		},
+		{
+			name:     "TOML stays TOML",
+			original: "+++\ntitle = \"Old\"\nweight = 3\n\n[params]\nhero = \"a.png\"\n+++\nOld body.\n",
+			prefix:   "+++\n",
+			keep:     []string{"weight = 3", "[params]\nhero = 'a.png'"},
+		},
+		{
+			name:     "JSON stays JSON",
+			original: "{\n  \"title\": \"Old\",\n  \"weight\": 3,\n  \"params\": {\"hero\": \"a.png\"}\n}\nOld body.\n",
+			prefix:   "{\n",
+			keep:     []string{`"weight": 3`, `"hero": "a.png"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := rewritePost([]byte(tt.original), post)
+			if err != nil {
+				t.Fatalf("rewritePost() unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(string(data), tt.prefix) {
+				t.Errorf("rewritePost() =\n%s\nwant prefix\n%s", data, tt.prefix)
+			}
+			for _, keep := range tt.keep {
+				if !strings.Contains(string(data), keep) {
+					t.Errorf("rewritePost() dropped %q:\n%s", keep, data)
+				}
+			}
+			if strings.Contains(string(data), "toc") {
+				t.Errorf("Expected toc to be removed once unset:\n%s", data)
+			}
+
+			fm, body, err := parseFrontMatter("post.md", string(data), time.UTC)
+			if err != nil {
+				t.Fatalf("parseFrontMatter() unexpected error: %v", err)
+			}
+			if fm.Title != post.Title || fm.Slug != post.Slug || !fm.Date.Time.Equal(post.Date) || body != post.Content {
+				t.Errorf("Round trip mismatch: %+v, body %q", fm, body)
+			}
+		})
+	}
+}
+
+func TestContentStoreWritesRestoreOnFailedReload(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "2024-01-15-kept.md")
+	original := "+++\ntitle = \"Kept\"\nslug = \"kept\"\ndate = 2024-01-15\npublished = true\n+++\nKept body.\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() unexpected error: %v", err)
+	}
+
+	// A broken file appearing on disk makes every reload fail
+	broken := filepath.Join(tempDir, "broken.md")
+	if err := os.WriteFile(broken, []byte("no front matter"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	post := &Post{Title: "Changed", Slug: "kept", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Content: "Changed body."}
+	if err := store.UpdatePost("kept", post); err == nil {
+		t.Error("UpdatePost() expected an error from the reload")
+	}
+	if err := store.DeletePost("kept"); err == nil {
+		t.Error("DeletePost() expected an error from the reload")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != original {
+		t.Errorf("Expected the original file to be restored, got %q, %v", data, err)
+	}
+
+	created := &Post{Title: "Created", Slug: "created", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Content: "Body."}
+	if err := store.CreatePost(created); err == nil {
+		t.Error("CreatePost() expected an error from the reload")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "2024-02-01-created.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected the new file to be removed, got %v", err)
+	}
+
+	// The published snapshot is untouched
+	if got, err := store.GetPostBySlug("kept"); err != nil || got.Title != "Kept" {
+		t.Errorf("GetPostBySlug() = %v, %v; want the original post", got, err)
+	}
+}
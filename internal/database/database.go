@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" driver
+	"github.com/seanankenbruck/blog/internal/config"
+	_ "modernc.org/sqlite" // Registers the "sqlite" driver
+)
+
+// Dialect identifies the SQL database in use
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// Rebind rewrites the ? placeholders in query to the dialect's syntax, so
+// queries can be written once for every database
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Open connects to the database selected by cfg.DBDriver
+func Open(ctx context.Context, cfg *config.Config) (*sql.DB, Dialect, error) {
+	var db *sql.DB
+	var dialect Dialect
+	var err error
+
+	switch Dialect(cfg.DBDriver) {
+	case Postgres:
+		dialect = Postgres
+		db, err = sql.Open("pgx", postgresDSN(cfg))
+	case SQLite:
+		dialect = SQLite
+		db, err = sql.Open("sqlite", sqliteDSN(cfg.DBPath))
+		if err == nil {
+			// SQLite allows a single writer; serialising connections avoids
+			// "database is locked" errors
+			db.SetMaxOpenConns(1)
+		}
+	default:
+		return nil, "", fmt.Errorf("unsupported database driver %q", cfg.DBDriver)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open %s database: %w", dialect, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, "", fmt.Errorf("failed to connect to %s database: %w", dialect, err)
+	}
+
+	return db, dialect, nil
+}
+
+func postgresDSN(cfg *config.Config) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.DBUser, cfg.DBPassword),
+		Host:     cfg.DBHost + ":" + strconv.Itoa(cfg.DBPort),
+		Path:     "/" + cfg.DBName,
+		RawQuery: url.Values{"sslmode": {cfg.DBSSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+func sqliteDSN(path string) string {
+	// Foreign keys are off by default in SQLite; post_tags relies on them
+	return "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanankenbruck/blog/internal/config"
+)
+
+func TestRebind(t *testing.T) {
+	query := "SELECT * FROM posts WHERE slug = ? AND published = ?"
+	if got := SQLite.Rebind(query); got != query {
+		t.Errorf("SQLite.Rebind() = %q, want %q", got, query)
+	}
+	if got, want := Postgres.Rebind(query), "SELECT * FROM posts WHERE slug = $1 AND published = $2"; got != want {
+		t.Errorf("Postgres.Rebind() = %q, want %q", got, want)
+	}
+}
+
+func TestPostgresDSN(t *testing.T) {
+	cfg := &config.Config{DBHost: "db", DBPort: 5432, DBUser: "blog", DBPassword: "p@ss/word", DBName: "blog", DBSSLMode: "require"}
+	if got, want := postgresDSN(cfg), "postgres://blog:p%40ss%2Fword@db:5432/blog?sslmode=require"; got != want {
+		t.Errorf("postgresDSN() = %q, want %q", got, want)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for _, dialect := range []Dialect{Postgres, SQLite} {
+		migrations, err := Migrations(dialect)
+		if err != nil {
+			t.Fatalf("Migrations(%s) error = %v", dialect, err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "create_posts" {
+			t.Errorf("Migrations(%s) = %+v, want 0001_create_posts first", dialect, migrations)
+		}
+	}
+
+	// Both dialects must define the same schema versions
+	pg, _ := Migrations(Postgres)
+	lite, _ := Migrations(SQLite)
+	if len(pg) != len(lite) {
+		t.Fatalf("Expected the same number of migrations, got %d postgres and %d sqlite", len(pg), len(lite))
+	}
+	for i := range pg {
+		if pg[i].Version != lite[i].Version || pg[i].Name != lite[i].Name {
+			t.Errorf("Migration %d differs: %04d_%s vs %04d_%s", i, pg[i].Version, pg[i].Name, lite[i].Version, lite[i].Name)
+		}
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, dialect, err := Open(ctx, &config.Config{DBDriver: "sqlite", DBPath: filepath.Join(t.TempDir(), "blog.db")})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	// Migrating twice must be a no-op the second time
+	for i := 0; i < 2; i++ {
+		if err := Migrate(ctx, db, dialect); err != nil {
+			t.Fatalf("Migrate() run %d error = %v", i+1, err)
+		}
+	}
+
+	migrations, _ := Migrations(dialect)
+	var applied int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatalf("Failed to count migrations: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Expected %d applied migrations, got %d", len(migrations), applied)
+	}
+
+	if _, err := db.ExecContext(ctx, "SELECT id, slug, content_html FROM posts"); err != nil {
+		t.Errorf("Expected posts table to exist: %v", err)
+	}
+}
+
+func TestOpenUnsupportedDriver(t *testing.T) {
+	if _, _, err := Open(context.Background(), &config.Config{DBDriver: "mysql"}); err == nil {
+		t.Error("Open() expected error for unsupported driver, got nil")
+	}
+}
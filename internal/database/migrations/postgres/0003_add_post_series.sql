@@ -0,0 +1,2 @@
+ALTER TABLE posts ADD COLUMN series TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN series_order INTEGER NOT NULL DEFAULT 0;
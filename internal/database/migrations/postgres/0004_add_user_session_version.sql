@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN session_version INTEGER NOT NULL DEFAULT 0;
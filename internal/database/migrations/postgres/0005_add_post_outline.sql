@@ -0,0 +1,7 @@
+-- Derived from content when a post is written, so listings need not render
+-- markdown. toc is the table of contents as JSON, NULL until it is computed
+-- for posts stored before this migration.
+ALTER TABLE posts ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN toc TEXT;
+ALTER TABLE posts ADD COLUMN word_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN reading_time INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Posts pinned as related to a post, in order, by slug. Pins may name posts
+-- that don't exist yet; they are skipped when read.
+CREATE TABLE post_related (
+    post_id  BIGINT NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    slug     TEXT NOT NULL,
+    PRIMARY KEY (post_id, position)
+);
@@ -0,0 +1,21 @@
+CREATE TABLE posts (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug         TEXT NOT NULL UNIQUE,
+    title        TEXT NOT NULL,
+    description  TEXT NOT NULL DEFAULT '',
+    content      TEXT NOT NULL DEFAULT '', -- Markdown source
+    content_html TEXT NOT NULL DEFAULT '', -- Rendered HTML served to readers
+    published    BOOLEAN NOT NULL DEFAULT FALSE,
+    publish_at   TIMESTAMP,
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL
+);
+
+CREATE INDEX posts_created_at_idx ON posts (created_at);
+
+CREATE TABLE post_tags (
+    post_id  INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name     TEXT NOT NULL,
+    PRIMARY KEY (post_id, position)
+);
@@ -5,6 +5,19 @@ import "errors"
 var (
 	// ErrPostNotFound is returned when a post cannot be found
 	ErrPostNotFound = errors.New("post not found")
+	// ErrTagNotFound is returned when no post carries the requested tag
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrSeriesNotFound is returned when no post belongs to the requested series
+	ErrSeriesNotFound = errors.New("series not found")
+	// ErrSlugExists is returned when creating or renaming a post to a slug
+	// another post already has
+	ErrSlugExists = errors.New("slug already exists")
+	// ErrInvalidPost is returned when a post fails validation
+	ErrInvalidPost = errors.New("invalid post")
+	// ErrUserNotFound is returned when a user cannot be found
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidUser is returned when a username or password is unacceptable
+	ErrInvalidUser = errors.New("invalid user")
 	// ErrUserExists is returned when trying to create a user that already exists
 	ErrUserExists = errors.New("user already exists")
 	// ErrInvalidCredentials is returned when authentication fails
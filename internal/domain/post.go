@@ -11,23 +11,94 @@ import (
 // Content in this file is reserved for future database integration.
 // Post represents a blog post
 type Post struct {
-	ID          uint      `json:"id"`
-	Title       string    `json:"title"`
-	Content     string    `json:"content"`
-	Description string    `json:"description"`
-	Slug        string    `json:"slug"`
-	Published   bool      `json:"published"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Markdown    string     `json:"-"` // Markdown source of Content, for editing
+	Description string     `json:"description"`
+	Summary     string     `json:"summary"` // HTML excerpt for post listings
+	Slug        string     `json:"slug"`
+	Tags        []string   `json:"tags"`
+	Series      string     `json:"series,omitempty"`       // Name of the series the post belongs to, if any
+	SeriesOrder int        `json:"series_order,omitempty"` // Position in the series; unordered parts follow by date
+	Related     []string   `json:"related,omitempty"`      // Slugs of posts pinned as related, in order
+	Published   bool       `json:"published"`
+	Draft       bool       `json:"draft"`               // Not yet publicly visible (shown in development only)
+	PublishAt   time.Time  `json:"publish_at,omitzero"` // Optional time a published post becomes visible
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	TOC         []TOCEntry `json:"toc,omitempty"` // Table of contents, empty when disabled
+	WordCount   int        `json:"word_count"`
+	ReadingTime int        `json:"reading_time"` // Estimated minutes to read
+}
+
+// TOCEntry is a heading in a post's table of contents
+type TOCEntry struct {
+	ID       string     `json:"id"`
+	Text     string     `json:"text"`
+	Level    int        `json:"level"`
+	Children []TOCEntry `json:"children,omitempty"`
+}
+
+// Heading levels included in a table of contents. Posts open with their
+// title as the only <h1>, so the contents start at <h2>.
+const (
+	TOCMinLevel = 2
+	TOCMaxLevel = 4
+)
+
+// NewTOC nests headings given in document order into a table of contents.
+// Each heading becomes a child of the closest preceding heading with a
+// lower level; headings outside TOCMinLevel to TOCMaxLevel are skipped.
+func NewTOC(headings []TOCEntry) []TOCEntry {
+	var toc []TOCEntry
+	for _, h := range headings {
+		if h.Level < TOCMinLevel || h.Level > TOCMaxLevel {
+			continue
+		}
+		toc = appendTOC(toc, h)
+	}
+	return toc
+}
+
+// appendTOC adds h under the last entry of toc if that entry is a higher
+// level heading, or at the end of toc otherwise
+func appendTOC(toc []TOCEntry, h TOCEntry) []TOCEntry {
+	if last := len(toc) - 1; last >= 0 && toc[last].Level < h.Level {
+		toc[last].Children = appendTOC(toc[last].Children, h)
+		return toc
+	}
+	return append(toc, h)
+}
+
+// WordsPerMinute is the reading speed ReadingTime assumes
+const WordsPerMinute = 200
+
+// ReadingTime estimates the minutes needed to read a number of words,
+// rounded up, and at least one minute for any text
+func ReadingTime(words int) int {
+	if words <= 0 {
+		return 0
+	}
+	return (words + WordsPerMinute - 1) / WordsPerMinute
 }
 
 // GenerateSlug creates a URL-friendly slug from the post title
 func (p *Post) GenerateSlug() string {
-	slug := strings.ToLower(p.Title)
-	slug = strings.ReplaceAll(slug, " ", "-")
-	slug = strings.ReplaceAll(slug, "'", "")
-	slug = strings.ReplaceAll(slug, "\"", "")
-	return slug
+	return slugify(p.Title)
+}
+
+// slugify lowercases s and drops quotes; anything else outside [a-z0-9]
+// separates words, so the result is safe in a URL path and a file name
+func slugify(s string) string {
+	s = strings.ToLower(s)
+	s = strings.ReplaceAll(s, "'", "")
+	s = strings.ReplaceAll(s, "\"", "")
+
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	return strings.Join(words, "-")
 }
 
 // GenerateUniqueSlug creates a unique slug by appending a number if needed
@@ -55,6 +126,9 @@ func (p *Post) Validate() error {
 	if p.Description == "" {
 		return errors.New("description is required")
 	}
+	if p.SeriesOrder < 0 {
+		return errors.New("series order must not be negative")
+	}
 	return nil
 }
 
@@ -68,16 +142,86 @@ func (p *Post) Update(newPost *Post) {
 	p.UpdatedAt = time.Now()
 }
 
-// PostRepository defines the interface for post data access
+// WithoutDrafts returns the posts that aren't drafts. Feeds and the sitemap
+// use it, as dev mode lists drafts alongside published posts.
+func WithoutDrafts(posts []*Post) []*Post {
+	published := make([]*Post, 0, len(posts))
+	for _, p := range posts {
+		if !p.Draft {
+			published = append(published, p)
+		}
+	}
+	return published
+}
+
+// HasTag reports whether the post carries a tag with the given slug
+func (p *Post) HasTag(slug string) bool {
+	for _, tag := range p.Tags {
+		if TagSlug(tag) == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchResult is a post matching a search query
+type SearchResult struct {
+	Post    *Post   `json:"post"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"` // HTML excerpt with matching words in <mark>
+}
+
+// PostRepository defines the interface for post data access. Reads return
+// rendered HTML in Content; Create and Update take markdown in Content.
 type PostRepository interface {
 	GetByID(ctx context.Context, id uint) (*Post, error)
 	GetBySlug(ctx context.Context, slug string) (*Post, error)
 	GetAll(ctx context.Context) ([]*Post, error)
+	// GetBySlugIncludingDrafts and GetAllIncludingDrafts also return drafts
+	// and scheduled posts, for editing
+	GetBySlugIncludingDrafts(ctx context.Context, slug string) (*Post, error)
+	GetAllIncludingDrafts(ctx context.Context) ([]*Post, error)
+	Create(ctx context.Context, post *Post) error
+	Update(ctx context.Context, slug string, post *Post) error
+	Delete(ctx context.Context, slug string) error
+	// Publish makes a draft or scheduled post visible immediately
+	Publish(ctx context.Context, slug string) error
+}
+
+// PostSearcher is implemented by repositories that maintain their own
+// full-text index. Results are ordered best match first.
+type PostSearcher interface {
+	Search(ctx context.Context, query string) ([]*SearchResult, error)
+}
+
+// RelatedPostFinder is implemented by repositories that rank related posts
+// ahead of time. Pinned relations come first, then the most similar posts.
+type RelatedPostFinder interface {
+	Related(ctx context.Context, slug string, limit int) ([]*Post, error)
 }
 
+// DefaultRelatedPosts is the number of related posts shown under a post
+const DefaultRelatedPosts = 3
+
 // PostService defines the interface for post business logic
 type PostService interface {
 	GetPost(ctx context.Context, id uint) (*Post, error)
 	GetPostBySlug(ctx context.Context, slug string) (*Post, error)
 	GetAllPosts(ctx context.Context) ([]*Post, error)
+	ListPosts(ctx context.Context, q PostQuery) (*PostPage, error)
+	GetTags(ctx context.Context) ([]*Tag, error)
+	GetPostsByTag(ctx context.Context, tagSlug string) (*Tag, []*Post, error)
+	GetSeries(ctx context.Context, seriesSlug string) (*Series, error)
+	// GetPostNavigation returns the series and prev/next links for a post
+	GetPostNavigation(ctx context.Context, post *Post) (*PostNavigation, error)
+	// GetRelatedPosts returns up to limit posts related to the post with slug
+	GetRelatedPosts(ctx context.Context, slug string, limit int) ([]*Post, error)
+	Search(ctx context.Context, query string) ([]*SearchResult, error)
+	GetPostBySlugIncludingDrafts(ctx context.Context, slug string) (*Post, error)
+	// CreatePost and UpdatePost take the post's markdown in Content and
+	// return the stored post
+	CreatePost(ctx context.Context, post *Post) (*Post, error)
+	UpdatePost(ctx context.Context, slug string, post *Post) (*Post, error)
+	DeletePost(ctx context.Context, slug string) error
+	PublishPost(ctx context.Context, slug string) (*Post, error)
 }
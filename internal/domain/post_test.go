@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
@@ -46,6 +47,16 @@ func TestGenerateSlug(t *testing.T) {
 			title:    "Hello",
 			expected: "hello",
 		},
+		{
+			name:     "Title with URL characters",
+			title:    "What is Go? CI/CD tips #1 at 100%",
+			expected: "what-is-go-ci-cd-tips-1-at-100",
+		},
+		{
+			name:     "Title with punctuation and accents",
+			title:    "  Go 1.22: Café -- Notes!  ",
+			expected: "go-1-22-caf-notes",
+		},
 		{
 			name:     "Empty title",
 			title:    "",
@@ -322,3 +333,91 @@ func TestUpdate_PreservesImmutableFields(t *testing.T) {
 		t.Errorf("Update() should preserve CreatedAt, got %v, want %v", originalPost.CreatedAt, createdAt)
 	}
 }
+
+func TestTagSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"go", "go"},
+		{"Go", "go"},
+		{"software development", "software-development"},
+		{"Software  Development ", "software-development"},
+		{"software-development", "software-development"},
+		{"CI/CD", "ci-cd"},
+		{"node.js #100%?", "node-js-100"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TagSlug(tt.name); got != tt.expected {
+				t.Errorf("TagSlug(%q) = %v, want %v", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	post := &Post{Tags: []string{"Go", "Software Development"}}
+
+	if !post.HasTag("software-development") {
+		t.Error("HasTag() expected true for 'software-development'")
+	}
+	if post.HasTag("rust") {
+		t.Error("HasTag() expected false for 'rust'")
+	}
+}
+
+
+func TestNewTOC(t *testing.T) {
+	headings := []TOCEntry{
+		{ID: "title", Text: "Title", Level: 1},
+		{ID: "setup", Text: "Setup", Level: 2},
+		{ID: "install", Text: "Install", Level: 3},
+		{ID: "flags", Text: "Flags", Level: 4},
+		{ID: "too-deep", Text: "Too deep", Level: 5},
+		{ID: "configure", Text: "Configure", Level: 3},
+		{ID: "usage", Text: "Usage", Level: 2},
+		// A skipped level still nests under the previous heading
+		{ID: "notes", Text: "Notes", Level: 4},
+	}
+
+	want := []TOCEntry{
+		{ID: "setup", Text: "Setup", Level: 2, Children: []TOCEntry{
+			{ID: "install", Text: "Install", Level: 3, Children: []TOCEntry{
+				{ID: "flags", Text: "Flags", Level: 4},
+			}},
+			{ID: "configure", Text: "Configure", Level: 3},
+		}},
+		{ID: "usage", Text: "Usage", Level: 2, Children: []TOCEntry{
+			{ID: "notes", Text: "Notes", Level: 4},
+		}},
+	}
+	if got := NewTOC(headings); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewTOC() = %+v, want %+v", got, want)
+	}
+
+	if got := NewTOC([]TOCEntry{{ID: "title", Text: "Title", Level: 1}}); got != nil {
+		t.Errorf("NewTOC() with only a title = %+v, want nil", got)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		words int
+		want  int
+	}{
+		{0, 0},
+		{1, 1},
+		{WordsPerMinute, 1},
+		{WordsPerMinute + 1, 2},
+		{10 * WordsPerMinute, 10},
+	}
+
+	for _, tt := range tests {
+		if got := ReadingTime(tt.words); got != tt.want {
+			t.Errorf("ReadingTime(%d) = %d, want %d", tt.words, got, tt.want)
+		}
+	}
+}
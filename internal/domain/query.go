@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidQuery is returned when a post query has invalid parameters
+var ErrInvalidQuery = errors.New("invalid query")
+
+const (
+	// DefaultPerPage is the page size used when a query does not set one
+	DefaultPerPage = 10
+	// MaxPerPage is the largest page size a query may request
+	MaxPerPage = 100
+)
+
+// PostSort is the order posts are listed in
+type PostSort string
+
+const (
+	SortNewest PostSort = "newest" // By creation date, newest first (default)
+	SortOldest PostSort = "oldest" // By creation date, oldest first
+	SortTitle  PostSort = "title"  // Alphabetically by title
+)
+
+// Valid reports whether s is a known sort order. The empty sort is valid and
+// means SortNewest.
+func (s PostSort) Valid() bool {
+	switch s {
+	case "", SortNewest, SortOldest, SortTitle:
+		return true
+	}
+	return false
+}
+
+// PostQuery selects a filtered, sorted page of posts
+type PostQuery struct {
+	Tag     string    // Only posts carrying this tag slug
+	From    time.Time // Only posts created at or after From, if set
+	To      time.Time // Only posts created at or before To, if set
+	Sort    PostSort
+	Page    int // 1-based page number, ignored when Cursor is set
+	PerPage int
+	// Cursor continues from the last post of a previous page, as returned in
+	// PostPage.NextCursor. Unlike Page it is stable when posts are added.
+	Cursor string
+	// IncludeDrafts also lists drafts and scheduled posts, for editing
+	IncludeDrafts bool
+}
+
+// PostPage is one page of posts matching a PostQuery
+type PostPage struct {
+	Posts      []*Post `json:"posts"`
+	Total      int     `json:"total"` // Posts matching the query across all pages
+	Page       int     `json:"page,omitempty"`
+	PerPage    int     `json:"per_page"`
+	TotalPages int     `json:"total_pages"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// HasPrev reports whether there is a page before this one
+func (p *PostPage) HasPrev() bool {
+	return p.Page > 1
+}
+
+// HasNext reports whether there is a page after this one
+func (p *PostPage) HasNext() bool {
+	return p.NextCursor != ""
+}
+
+// PrevPage returns the number of the previous page
+func (p *PostPage) PrevPage() int {
+	return p.Page - 1
+}
+
+// NextPage returns the number of the next page
+func (p *PostPage) NextPage() int {
+	return p.Page + 1
+}
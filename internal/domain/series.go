@@ -0,0 +1,23 @@
+package domain
+
+// Series is a named sequence of posts in reading order
+type Series struct {
+	Name  string  `json:"name"`
+	Slug  string  `json:"slug"`
+	Posts []*Post `json:"posts"`
+}
+
+// SeriesSlug creates the URL-friendly form of a series name. Like tags,
+// names differing only in case or spacing are the same series.
+func SeriesSlug(name string) string {
+	return TagSlug(name)
+}
+
+// PostNavigation links a post to the posts around it: its series siblings,
+// or for posts outside a series its chronological neighbours
+type PostNavigation struct {
+	Series *Series `json:"series,omitempty"`
+	Part   int     `json:"part,omitempty"` // 1-based position of the post in Series
+	Prev   *Post   `json:"prev,omitempty"` // Previous part, or the next older post
+	Next   *Post   `json:"next,omitempty"` // Next part, or the next newer post
+}
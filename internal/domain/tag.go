@@ -0,0 +1,15 @@
+package domain
+
+// Tag is a post tag along with the number of posts carrying it
+type Tag struct {
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Count int    `json:"count"`
+}
+
+// TagSlug creates the URL-friendly form of a tag name, so that tags differing
+// only in case, spacing or punctuation ("Software Development",
+// "software-development") are treated as the same tag
+func TagSlug(name string) string {
+	return slugify(name)
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// User is an account that can sign in to the admin pages
+type User struct {
+	ID             uint      `json:"id"`
+	Username       string    `json:"username"`
+	PasswordHash   string    `json:"-"`
+	SessionVersion int       `json:"-"` // Bumped to revoke the user's sessions
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// UserRepository defines the interface for user data access
+type UserRepository interface {
+	// GetByUsername returns ErrUserNotFound if there is no such user
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	GetAll(ctx context.Context) ([]*User, error)
+	// Create returns ErrUserExists if the username is taken
+	Create(ctx context.Context, user *User) error
+	// Update saves the password hash and session version of the user with
+	// user.Username, returning ErrUserNotFound if there is none
+	Update(ctx context.Context, user *User) error
+}
+
+// UserService defines the interface for user accounts and sign-in
+type UserService interface {
+	// Register creates a user with a hashed password
+	Register(ctx context.Context, username, password string) (*User, error)
+	// Authenticate returns ErrInvalidCredentials unless the username and
+	// password match
+	Authenticate(ctx context.Context, username, password string) (*User, error)
+	GetUser(ctx context.Context, username string) (*User, error)
+	GetUsers(ctx context.Context) ([]*User, error)
+	// RevokeSessions ends every session of the user, on all devices
+	RevokeSessions(ctx context.Context, username string) error
+}
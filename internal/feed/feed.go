@@ -0,0 +1,261 @@
+package feed
+
+import (
+	"encoding/xml"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// DefaultLimit is the number of posts included in a feed
+const DefaultLimit = 20
+
+// Site describes the blog a feed is generated for
+type Site struct {
+	Title       string
+	Description string
+	BaseURL     string // Absolute URL of the site root, e.g. https://example.com
+	Author      string
+	Language    string
+}
+
+// URL returns the absolute URL for a site path
+func (s Site) URL(path string) string {
+	return strings.TrimRight(s.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// PostURL returns the absolute permalink of a post
+func (s Site) PostURL(p *domain.Post) string {
+	return s.URL("/posts/" + p.Slug)
+}
+
+// Feed is a single feed to render: a title, the page it describes and the
+// posts it contains, newest first
+type Feed struct {
+	Title       string
+	Description string
+	Path        string // Site path of the HTML page the feed mirrors
+	SelfPath    string // Site path the feed itself is served from
+	Posts       []*domain.Post
+}
+
+// rss is the root element of an RSS 2.0 document
+type rss struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+	Content     cdata    `xml:"content:encoded"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// atomFeed is the root element of an Atom document
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomText       `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// RSS renders f as an RSS 2.0 document. Drafts are left out.
+func RSS(site Site, f Feed) ([]byte, error) {
+	posts := domain.WithoutDrafts(f.Posts)
+	doc := rss{
+		Version:   "2.0",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        site.URL(f.Path),
+			Description: f.Description,
+			Language:    site.Language,
+			AtomLink: atomLink{
+				Href: site.URL(f.SelfPath),
+				Rel:  "self",
+				Type: "application/rss+xml",
+			},
+		},
+	}
+
+	if updated := lastUpdated(posts); !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, p := range limit(posts) {
+		link := site.PostURL(p)
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       p.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			PubDate:     p.CreatedAt.UTC().Format(time.RFC1123Z),
+			Description: p.Description,
+			Categories:  p.Tags,
+			Content:     cdata{Value: absoluteURLs(p.Content, link)},
+		})
+	}
+
+	return marshal(doc)
+}
+
+// Atom renders f as an Atom 1.0 document. Drafts are left out.
+func Atom(site Site, f Feed) ([]byte, error) {
+	posts := domain.WithoutDrafts(f.Posts)
+	// Atom requires a feed update time, even with no posts
+	updated := lastUpdated(posts)
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	doc := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       site.URL(f.SelfPath),
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: site.URL(f.Path), Rel: "alternate", Type: "text/html"},
+			{Href: site.URL(f.SelfPath), Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	if site.Author != "" {
+		doc.Author = &atomPerson{Name: site.Author}
+	}
+
+	for _, p := range limit(posts) {
+		link := site.PostURL(p)
+		entry := atomEntry{
+			Title:     p.Title,
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: p.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   updatedAt(p).UTC().Format(time.RFC3339),
+			Summary:   p.Description,
+			Content:   atomText{Type: "html", Value: absoluteURLs(p.Content, link)},
+		}
+		for _, tag := range p.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return marshal(doc)
+}
+
+func marshal(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// limit returns at most DefaultLimit posts
+func limit(posts []*domain.Post) []*domain.Post {
+	if len(posts) > DefaultLimit {
+		return posts[:DefaultLimit]
+	}
+	return posts
+}
+
+// updatedAt returns when a post was last changed, falling back to its creation date
+func updatedAt(p *domain.Post) time.Time {
+	if p.UpdatedAt.After(p.CreatedAt) {
+		return p.UpdatedAt
+	}
+	return p.CreatedAt
+}
+
+// lastUpdated returns the most recent update time across posts
+func lastUpdated(posts []*domain.Post) time.Time {
+	var latest time.Time
+	for _, p := range posts {
+		if t := updatedAt(p); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// urlAttrPattern matches src and href attributes in rendered post HTML
+var urlAttrPattern = regexp.MustCompile(`\b(src|href)="([^"]*)"`)
+
+// absoluteURLs resolves relative src and href attributes against the post's
+// permalink, since feed readers display content outside the site
+func absoluteURLs(html, base string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return html
+	}
+
+	return urlAttrPattern.ReplaceAllStringFunc(html, func(attr string) string {
+		m := urlAttrPattern.FindStringSubmatch(attr)
+		ref, err := url.Parse(m[2])
+		if err != nil || ref.IsAbs() {
+			return attr
+		}
+		return m[1] + `="` + baseURL.ResolveReference(ref).String() + `"`
+	})
+}
@@ -0,0 +1,221 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+var testSite = Site{
+	Title:       "Test Blog",
+	Description: "A blog for tests",
+	BaseURL:     "https://example.com/",
+	Author:      "Test Author",
+	Language:    "en-us",
+}
+
+func testPosts() []*domain.Post {
+	created := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	return []*domain.Post{
+		{
+			Title:       "Newer Post",
+			Slug:        "newer-post",
+			Description: "Summary of the newer post",
+			Content:     `<p>See <a href="/posts/older-post">the older post</a> and <img src="../../static/images/a.png" alt="a"> or <a href="https://go.dev">Go</a>.</p>`,
+			Tags:        []string{"go", "testing"},
+			CreatedAt:   created.AddDate(0, 0, 5),
+			UpdatedAt:   created.AddDate(0, 0, 9),
+		},
+		{
+			Title:       "Older Post",
+			Slug:        "older-post",
+			Description: "Summary of the older post",
+			Content:     "<p>Contains ]]> which must survive CDATA.</p>",
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+	}
+}
+
+func TestRSS(t *testing.T) {
+	out, err := RSS(testSite, Feed{
+		Title:       testSite.Title,
+		Description: testSite.Description,
+		Path:        "/posts",
+		SelfPath:    "/feed.xml",
+		Posts:       testPosts(),
+	})
+	if err != nil {
+		t.Fatalf("RSS() error = %v", err)
+	}
+
+	var doc struct {
+		Channel struct {
+			Title         string `xml:"title"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string   `xml:"title"`
+				Link        string   `xml:"link"`
+				GUID        string   `xml:"guid"`
+				PubDate     string   `xml:"pubDate"`
+				Description string   `xml:"description"`
+				Categories  []string `xml:"category"`
+				Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("RSS() produced invalid XML: %v\n%s", err, out)
+	}
+
+	if !strings.Contains(string(out), "<link>https://example.com/posts</link>") {
+		t.Errorf("Channel link missing: %s", out)
+	}
+	if !strings.Contains(string(out), `<atom:link href="https://example.com/feed.xml" rel="self" type="application/rss+xml"></atom:link>`) {
+		t.Errorf("Self link missing: %s", out)
+	}
+	if doc.Channel.LastBuildDate != "Wed, 24 Jan 2024 10:00:00 +0000" {
+		t.Errorf("LastBuildDate = %v, want latest update", doc.Channel.LastBuildDate)
+	}
+	if len(doc.Channel.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(doc.Channel.Items))
+	}
+
+	item := doc.Channel.Items[0]
+	if item.Link != "https://example.com/posts/newer-post" || item.GUID != item.Link {
+		t.Errorf("Item link = %v, guid = %v", item.Link, item.GUID)
+	}
+	if item.PubDate != "Sat, 20 Jan 2024 10:00:00 +0000" {
+		t.Errorf("Item pubDate = %v", item.PubDate)
+	}
+	if item.Description != "Summary of the newer post" {
+		t.Errorf("Item description = %v", item.Description)
+	}
+	if len(item.Categories) != 2 {
+		t.Errorf("Expected 2 categories, got %v", item.Categories)
+	}
+	for _, want := range []string{
+		`href="https://example.com/posts/older-post"`,
+		`src="https://example.com/static/images/a.png"`,
+		`href="https://go.dev"`,
+	} {
+		if !strings.Contains(item.Content, want) {
+			t.Errorf("Item content missing %s: %s", want, item.Content)
+		}
+	}
+
+	if !strings.Contains(doc.Channel.Items[1].Content, "Contains ]]> which") {
+		t.Errorf("CDATA terminator not preserved: %s", doc.Channel.Items[1].Content)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	out, err := Atom(testSite, Feed{
+		Title:    testSite.Title,
+		Path:     "/posts",
+		SelfPath: "/atom.xml",
+		Posts:    testPosts(),
+	})
+	if err != nil {
+		t.Fatalf("Atom() error = %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Author  struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Published string `xml:"published"`
+			Updated   string `xml:"updated"`
+			Summary   string `xml:"summary"`
+			Content   struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Atom() produced invalid XML: %v\n%s", err, out)
+	}
+
+	if doc.ID != "https://example.com/atom.xml" {
+		t.Errorf("Feed id = %v", doc.ID)
+	}
+	if doc.Updated != "2024-01-24T10:00:00Z" {
+		t.Errorf("Feed updated = %v, want latest update", doc.Updated)
+	}
+	if doc.Author.Name != "Test Author" {
+		t.Errorf("Feed author = %v", doc.Author.Name)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(doc.Entries))
+	}
+
+	entry := doc.Entries[0]
+	if entry.Published != "2024-01-20T10:00:00Z" || entry.Updated != "2024-01-24T10:00:00Z" {
+		t.Errorf("Entry published = %v, updated = %v", entry.Published, entry.Updated)
+	}
+	if entry.Summary != "Summary of the newer post" {
+		t.Errorf("Entry summary = %v", entry.Summary)
+	}
+	if entry.Content.Type != "html" || !strings.Contains(entry.Content.Value, `<img src="https://example.com/static/images/a.png"`) {
+		t.Errorf("Entry content = %v", entry.Content.Value)
+	}
+}
+
+func TestFeedLimit(t *testing.T) {
+	posts := make([]*domain.Post, DefaultLimit+5)
+	for i := range posts {
+		posts[i] = &domain.Post{Slug: "post", CreatedAt: time.Now()}
+	}
+
+	out, err := RSS(testSite, Feed{Posts: posts})
+	if err != nil {
+		t.Fatalf("RSS() error = %v", err)
+	}
+	if got := strings.Count(string(out), "<item>"); got != DefaultLimit {
+		t.Errorf("Expected %d items, got %d", DefaultLimit, got)
+	}
+}
+
+func TestFeedsSkipDrafts(t *testing.T) {
+	posts := testPosts()
+	posts[0].Draft = true
+
+	for name, render := range map[string]func(Site, Feed) ([]byte, error){"RSS": RSS, "Atom": Atom} {
+		out, err := render(testSite, Feed{Posts: posts})
+		if err != nil {
+			t.Fatalf("%s() error = %v", name, err)
+		}
+		if strings.Contains(string(out), "newer-post") {
+			t.Errorf("%s() included a draft:\n%s", name, out)
+		}
+		if !strings.Contains(string(out), "older-post") {
+			t.Errorf("%s() left out a published post:\n%s", name, out)
+		}
+	}
+}
+
+func TestEmptyAtomFeed(t *testing.T) {
+	out, err := Atom(testSite, Feed{SelfPath: "/tags/none/atom.xml"})
+	if err != nil {
+		t.Fatalf("Atom() error = %v", err)
+	}
+
+	var doc struct {
+		Updated time.Time `xml:"updated"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Atom() produced invalid XML: %v\n%s", err, out)
+	}
+	if time.Since(doc.Updated) > time.Minute {
+		t.Errorf("Empty feed updated = %v, want now", doc.Updated)
+	}
+}
@@ -0,0 +1,400 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/markdown"
+)
+
+// AdminHandler serves the admin pages and the JSON API for managing posts
+// under /admin/api
+type AdminHandler struct {
+	postService domain.PostService
+	renderer    *markdown.Renderer
+}
+
+// NewAdminHandler creates a new AdminHandler. renderer should be the one
+// posts are loaded with, so the editor's preview matches published posts.
+func NewAdminHandler(postService domain.PostService, renderer *markdown.Renderer) *AdminHandler {
+	return &AdminHandler{postService: postService, renderer: renderer}
+}
+
+// postRequest is the body of create and update requests. Content is markdown.
+type postRequest struct {
+	Title       string   `json:"title"`
+	Slug        string   `json:"slug"` // Optional on create, generated from the title if empty; ignored on update
+	Description string   `json:"description"`
+	Content     string   `json:"content"`
+	Tags        []string `json:"tags"`
+	Published   bool     `json:"published"`
+	Series      string   `json:"series"`
+	SeriesOrder int      `json:"series_order"`
+	Related     []string `json:"related"` // Slugs of posts pinned as related
+	// Optional; CreatedAt defaults to now on create and is kept on update
+	CreatedAt time.Time `json:"created_at"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
+func (r *postRequest) post() *domain.Post {
+	tags := r.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return &domain.Post{
+		Title:       strings.TrimSpace(r.Title),
+		Slug:        strings.TrimSpace(r.Slug),
+		Description: strings.TrimSpace(r.Description),
+		Content:     r.Content,
+		Tags:        tags,
+		Published:   r.Published,
+		Series:      strings.TrimSpace(r.Series),
+		SeriesOrder: r.SeriesOrder,
+		Related:     r.Related,
+		CreatedAt:   r.CreatedAt,
+		PublishAt:   r.PublishAt,
+	}
+}
+
+// adminPost is a post as returned by the admin API, with its markdown source
+type adminPost struct {
+	*domain.Post
+	Markdown string `json:"markdown"`
+}
+
+func newAdminPost(p *domain.Post) adminPost {
+	return adminPost{Post: p, Markdown: p.Markdown}
+}
+
+// Dashboard renders the admin home page listing every post, drafts first
+func (h *AdminHandler) Dashboard(c *gin.Context) {
+	posts, err := h.allPosts(c.Request.Context())
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "500.html", nil)
+		return
+	}
+
+	drafts := make([]*domain.Post, 0)
+	published := make([]*domain.Post, 0)
+	for _, p := range posts {
+		if p.Draft {
+			drafts = append(drafts, p)
+		} else {
+			published = append(published, p)
+		}
+	}
+
+	c.HTML(http.StatusOK, "admin.html", gin.H{
+		"Title":     "Admin",
+		"Year":      time.Now().Year(),
+		"User":      CurrentUser(c),
+		"CSRFToken": CSRFToken(c),
+		"Drafts":    drafts,
+		"Published": published,
+		"Total":     len(posts),
+	})
+}
+
+// allPosts lists every post and draft, following the cursor through as
+// many pages as it takes
+func (h *AdminHandler) allPosts(ctx context.Context) ([]*domain.Post, error) {
+	q := domain.PostQuery{IncludeDrafts: true, PerPage: domain.MaxPerPage}
+	var posts []*domain.Post
+	for {
+		page, err := h.postService.ListPosts(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, page.Posts...)
+		if !page.HasNext() {
+			return posts, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// ListPosts lists all posts including drafts, with the same query parameters
+// as GET /posts
+func (h *AdminHandler) ListPosts(c *gin.Context) {
+	q, err := parsePostQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.IncludeDrafts = true
+
+	page, err := h.postService.ListPosts(c.Request.Context(), q)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GetPost returns a post or draft by slug
+func (h *AdminHandler) GetPost(c *gin.Context) {
+	post, err := h.postService.GetPostBySlugIncludingDrafts(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAdminPost(post))
+}
+
+// CreatePost creates a post from a JSON body
+func (h *AdminHandler) CreatePost(c *gin.Context) {
+	var req postRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	post, err := h.postService.CreatePost(c.Request.Context(), req.post())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.Header("Location", "/admin/api/posts/"+post.Slug)
+	c.JSON(http.StatusCreated, newAdminPost(post))
+}
+
+// UpdatePost replaces a post's fields from a JSON body. The slug is kept.
+func (h *AdminHandler) UpdatePost(c *gin.Context) {
+	var req postRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	post, err := h.postService.UpdatePost(c.Request.Context(), c.Param("slug"), req.post())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAdminPost(post))
+}
+
+// DeletePost deletes a post
+func (h *AdminHandler) DeletePost(c *gin.Context) {
+	if err := h.postService.DeletePost(c.Request.Context(), c.Param("slug")); err != nil {
+		adminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PublishPost makes a draft or scheduled post visible now
+func (h *AdminHandler) PublishPost(c *gin.Context) {
+	post, err := h.postService.PublishPost(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAdminPost(post))
+}
+
+// editorTimeLayout is the format of datetime-local inputs. Editor times
+// are in UTC.
+const editorTimeLayout = "2006-01-02T15:04"
+
+// editorForm holds the post editor's fields, as submitted or as shown
+type editorForm struct {
+	Title       string `form:"title"`
+	Slug        string `form:"slug"`
+	Description string `form:"description"`
+	Tags        string `form:"tags"` // Comma-separated
+	Published   bool   `form:"published"`
+	Series      string `form:"series"`
+	SeriesOrder int    `form:"series_order"`
+	Related     string `form:"related"` // Comma-separated slugs
+	Date        string `form:"date"`
+	PublishAt   string `form:"publish_at"`
+	Content     string `form:"content"` // Markdown
+}
+
+// newEditorForm fills the editor from a stored post
+func newEditorForm(p *domain.Post) editorForm {
+	f := editorForm{
+		Title:       p.Title,
+		Slug:        p.Slug,
+		Description: p.Description,
+		Tags:        strings.Join(p.Tags, ", "),
+		Published:   p.Published,
+		Series:      p.Series,
+		SeriesOrder: p.SeriesOrder,
+		Related:     strings.Join(p.Related, ", "),
+		Date:        p.CreatedAt.UTC().Format(editorTimeLayout),
+		Content:     p.Markdown,
+	}
+	if !p.PublishAt.IsZero() {
+		f.PublishAt = p.PublishAt.UTC().Format(editorTimeLayout)
+	}
+	return f
+}
+
+// post converts the submitted form to a post for the service
+func (f *editorForm) post() (*domain.Post, error) {
+	post := &domain.Post{
+		Title:       strings.TrimSpace(f.Title),
+		Slug:        strings.TrimSpace(f.Slug),
+		Description: strings.TrimSpace(f.Description),
+		Content:     strings.ReplaceAll(f.Content, "\r\n", "\n"),
+		Tags:        splitList(f.Tags),
+		Published:   f.Published,
+		Series:      strings.TrimSpace(f.Series),
+		SeriesOrder: f.SeriesOrder,
+		Related:     splitList(f.Related),
+	}
+
+	var err error
+	if post.CreatedAt, err = parseEditorTime(f.Date); err != nil {
+		return nil, fmt.Errorf("%w: date: %v", domain.ErrInvalidPost, err)
+	}
+	if post.PublishAt, err = parseEditorTime(f.PublishAt); err != nil {
+		return nil, fmt.Errorf("%w: publish at: %v", domain.ErrInvalidPost, err)
+	}
+	return post, nil
+}
+
+// splitList splits a comma-separated field, dropping empty items
+func splitList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseEditorTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(editorTimeLayout, value)
+}
+
+// NewPostPage renders the editor for a new post
+func (h *AdminHandler) NewPostPage(c *gin.Context) {
+	h.renderEditor(c, http.StatusOK, editorForm{Date: time.Now().UTC().Format(editorTimeLayout)}, true, "")
+}
+
+// EditPostPage renders the editor for an existing post or draft
+func (h *AdminHandler) EditPostPage(c *gin.Context) {
+	post, err := h.postService.GetPostBySlugIncludingDrafts(c.Request.Context(), c.Param("slug"))
+	if errors.Is(err, domain.ErrPostNotFound) {
+		c.HTML(http.StatusNotFound, "404.html", gin.H{"Title": "404 - Page Not Found", "Year": time.Now().Year()})
+		return
+	}
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "500.html", nil)
+		return
+	}
+	h.renderEditor(c, http.StatusOK, newEditorForm(post), false, "")
+}
+
+// SaveNewPost creates a post from the editor form
+func (h *AdminHandler) SaveNewPost(c *gin.Context) {
+	var form editorForm
+	if err := c.ShouldBind(&form); err != nil {
+		h.renderEditor(c, http.StatusBadRequest, form, true, err.Error())
+		return
+	}
+
+	post, err := form.post()
+	if err == nil {
+		post, err = h.postService.CreatePost(c.Request.Context(), post)
+	}
+	if err != nil {
+		h.renderEditor(c, adminStatus(err), form, true, err.Error())
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/admin/posts/"+post.Slug+"/edit?saved=1")
+}
+
+// SavePost updates a post from the editor form. The slug is kept.
+func (h *AdminHandler) SavePost(c *gin.Context) {
+	slug := c.Param("slug")
+	var form editorForm
+	err := c.ShouldBind(&form)
+	form.Slug = slug
+	if err != nil {
+		h.renderEditor(c, http.StatusBadRequest, form, false, err.Error())
+		return
+	}
+
+	post, err := form.post()
+	if err == nil {
+		post, err = h.postService.UpdatePost(c.Request.Context(), slug, post)
+	}
+	if err != nil {
+		h.renderEditor(c, adminStatus(err), form, false, err.Error())
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/admin/posts/"+post.Slug+"/edit?saved=1")
+}
+
+// Preview renders the markdown request body for the post editor with the
+// same renderer and sanitizing policy as published posts
+func (h *AdminHandler) Preview(c *gin.Context) {
+	previewMarkdown(h.renderer)(c)
+}
+
+// DeletePostForm deletes a post from the editor and returns to the dashboard
+func (h *AdminHandler) DeletePostForm(c *gin.Context) {
+	err := h.postService.DeletePost(c.Request.Context(), c.Param("slug"))
+	if errors.Is(err, domain.ErrPostNotFound) {
+		c.HTML(http.StatusNotFound, "404.html", gin.H{"Title": "404 - Page Not Found", "Year": time.Now().Year()})
+		return
+	}
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "500.html", nil)
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/admin")
+}
+
+func (h *AdminHandler) renderEditor(c *gin.Context, status int, form editorForm, isNew bool, errMessage string) {
+	title := "New post"
+	action := "/admin/posts"
+	if !isNew {
+		title = "Edit: " + form.Title
+		action = "/admin/posts/" + form.Slug
+	}
+
+	c.HTML(status, "editor.html", gin.H{
+		"Title":     title,
+		"Year":      time.Now().Year(),
+		"User":      CurrentUser(c),
+		"CSRFToken": CSRFToken(c),
+		"Form":      form,
+		"IsNew":     isNew,
+		"Action":    action,
+		"Error":     errMessage,
+		"Saved":     c.Query("saved") != "",
+	})
+}
+
+// adminStatus returns the HTTP status for a service error
+func adminStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidPost), errors.Is(err, domain.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrPostNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrSlugExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// adminError writes the JSON error response for a service error
+func adminError(c *gin.Context, err error) {
+	c.JSON(adminStatus(err), gin.H{"error": err.Error()})
+}
@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/auth"
+	"github.com/seanankenbruck/blog/internal/content"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/markdown"
+	"github.com/seanankenbruck/blog/internal/repository"
+	"github.com/seanankenbruck/blog/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAdminRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	store := content.NewContentStore(t.TempDir(), false)
+	svc := service.NewPostService(repository.NewFilePostRepository(store))
+	h := NewAdminHandler(svc, markdown.New(markdown.DefaultExtensions))
+
+	users := service.NewUserService(repository.NewFileUserRepository(filepath.Join(t.TempDir(), "users.json")))
+	authHandler := NewAuthHandler(users, auth.NewSessions([]byte(strings.Repeat("k", 32)), time.Hour), true, "secret")
+
+	router := gin.New()
+	admin := router.Group("/admin/api", authHandler.RequireAuth)
+	admin.GET("/posts", h.ListPosts)
+	admin.POST("/posts", h.CreatePost)
+	admin.GET("/posts/:slug", h.GetPost)
+	admin.PUT("/posts/:slug", h.UpdatePost)
+	admin.DELETE("/posts/:slug", h.DeletePost)
+	admin.POST("/posts/:slug/publish", h.PublishPost)
+	return router
+}
+
+func adminRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAPI(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	t.Run("Requires the admin token", func(t *testing.T) {
+		for _, header := range []string{"", "Bearer wrong", "secret"} {
+			req := httptest.NewRequest(http.MethodGet, "/admin/api/posts", nil)
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, "Authorization %q", header)
+		}
+	})
+
+	t.Run("Create validates and generates a slug", func(t *testing.T) {
+		w := adminRequest(router, http.MethodPost, "/admin/api/posts", `{"title":"New Post","content":"# Hi"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = adminRequest(router, http.MethodPost, "/admin/api/posts", `{"title":"New Post","description":"Desc","content":"# Hi","tags":["Go"]}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/admin/api/posts/new-post", w.Header().Get("Location"))
+
+		var post struct {
+			Slug     string `json:"slug"`
+			Draft    bool   `json:"draft"`
+			Markdown string `json:"markdown"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+		assert.Equal(t, "new-post", post.Slug)
+		assert.True(t, post.Draft)
+		assert.Equal(t, "# Hi", post.Markdown)
+
+		w = adminRequest(router, http.MethodPost, "/admin/api/posts", `{"title":"New Post","description":"Desc","content":"Again"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"slug":"new-post-1"`)
+
+		w = adminRequest(router, http.MethodPost, "/admin/api/posts", `{"title":"Other","slug":"new-post","description":"Desc","content":"Body"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("List includes drafts", func(t *testing.T) {
+		w := adminRequest(router, http.MethodGet, "/admin/api/posts", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"total":2`)
+	})
+
+	t.Run("Update, publish and delete", func(t *testing.T) {
+		w := adminRequest(router, http.MethodPut, "/admin/api/posts/new-post", `{"title":"Renamed","description":"Desc","content":"Updated"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"slug":"new-post"`)
+		assert.Contains(t, w.Body.String(), `"title":"Renamed"`)
+
+		w = adminRequest(router, http.MethodPost, "/admin/api/posts/new-post/publish", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"draft":false`)
+
+		w = adminRequest(router, http.MethodDelete, "/admin/api/posts/new-post", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = adminRequest(router, http.MethodGet, "/admin/api/posts/new-post", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = adminRequest(router, http.MethodPut, "/admin/api/posts/missing", `{"title":"T","description":"D","content":"C"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func setupEditorRouter(t *testing.T) (*gin.Engine, *content.ContentStore) {
+	gin.SetMode(gin.TestMode)
+
+	store := content.NewContentStore(t.TempDir(), false)
+	h := NewAdminHandler(service.NewPostService(repository.NewFilePostRepository(store)), markdown.New(markdown.DefaultExtensions))
+
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("editor.html").Parse(
+		`{{define "editor.html"}}{{.Action}}|{{.Form.Slug}}|{{.Form.Date}}|{{.Error}}|{{.Saved}}|{{.Form.Content}}{{end}}` +
+			`{{define "404.html"}}not found{{end}}`)))
+	router.GET("/admin/posts/new", h.NewPostPage)
+	router.POST("/admin/posts", h.SaveNewPost)
+	router.GET("/admin/posts/:slug/edit", h.EditPostPage)
+	router.POST("/admin/posts/:slug", h.SavePost)
+	router.POST("/admin/posts/:slug/delete", h.DeletePostForm)
+	return router, store
+}
+
+func formRequest(router *gin.Engine, method, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPostEditor(t *testing.T) {
+	router, store := setupEditorRouter(t)
+
+	t.Run("New post page", func(t *testing.T) {
+		w := formRequest(router, http.MethodGet, "/admin/posts/new", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.HasPrefix(w.Body.String(), "/admin/posts||"))
+	})
+
+	t.Run("Create writes the file and redirects to the editor", func(t *testing.T) {
+		form := url.Values{
+			"title":       {"Editor Post"},
+			"description": {"Written in the browser"},
+			"tags":        {"Go, , Web "},
+			"related":     {"intro, setup"},
+			"date":        {"2024-05-01T08:30"},
+			"content":     {"# Hello\r\n\r\nFrom the editor."},
+		}
+		w := formRequest(router, http.MethodPost, "/admin/posts", form)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/admin/posts/editor-post/edit?saved=1", w.Header().Get("Location"))
+
+		post, err := store.GetPostBySlugIncludingDrafts("editor-post")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Go", "Web"}, post.Tags)
+		assert.Equal(t, []string{"intro", "setup"}, post.Related)
+		assert.Equal(t, time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC), post.Date)
+		assert.Equal(t, "# Hello\n\nFrom the editor.", post.Content)
+		assert.False(t, post.Published)
+		assert.Equal(t, "2024-05-01-editor-post.md", filepath.Base(post.Path))
+	})
+
+	t.Run("Create re-renders the form on errors", func(t *testing.T) {
+		w := formRequest(router, http.MethodPost, "/admin/posts", url.Values{"title": {"No description"}, "content": {"Body"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Body")
+
+		w = formRequest(router, http.MethodPost, "/admin/posts", url.Values{
+			"title": {"Bad date"}, "description": {"D"}, "content": {"Body"}, "date": {"yesterday"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "date")
+	})
+
+	t.Run("Edit page shows the markdown source", func(t *testing.T) {
+		w := formRequest(router, http.MethodGet, "/admin/posts/editor-post/edit?saved=1", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "/admin/posts/editor-post|editor-post|2024-05-01T08:30||true|# Hello\n\nFrom the editor.", w.Body.String())
+
+		w = formRequest(router, http.MethodGet, "/admin/posts/missing/edit", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Save updates and publishes in place", func(t *testing.T) {
+		form := url.Values{
+			"title":       {"Editor Post"},
+			"slug":        {"ignored"},
+			"description": {"Edited"},
+			"date":        {"2024-05-01T08:30"},
+			"published":   {"true"},
+			"content":     {"Edited body."},
+		}
+		w := formRequest(router, http.MethodPost, "/admin/posts/editor-post", form)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/admin/posts/editor-post/edit?saved=1", w.Header().Get("Location"))
+
+		post, err := store.GetPostBySlug("editor-post")
+		assert.NoError(t, err)
+		assert.Equal(t, "Edited", post.Description)
+		assert.Equal(t, "<p>Edited body.</p>\n", post.HTMLContent)
+		assert.Equal(t, "2024-05-01-editor-post.md", filepath.Base(post.Path))
+	})
+
+	t.Run("Delete removes the post", func(t *testing.T) {
+		w := formRequest(router, http.MethodPost, "/admin/posts/editor-post/delete", nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/admin", w.Header().Get("Location"))
+
+		_, err := store.GetPostBySlugIncludingDrafts("editor-post")
+		assert.ErrorIs(t, err, content.ErrPostNotFound)
+
+		w = formRequest(router, http.MethodPost, "/admin/posts/editor-post/delete", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// TestEditorPreview checks the editor's preview needs a session and keeps
+// the markup the public preview strips
+func TestEditorPreview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewAdminHandler(nil, markdown.New(markdown.DefaultExtensions))
+	users := service.NewUserService(repository.NewFileUserRepository(filepath.Join(t.TempDir(), "users.json")))
+	authHandler := NewAuthHandler(users, auth.NewSessions([]byte(strings.Repeat("k", 32)), time.Hour), true, "secret")
+
+	router := gin.New()
+	router.POST("/admin/preview", authHandler.RequireAuth, h.Preview)
+
+	body := "# Hi\n\n![Diagram](/static/diagram.png)\n"
+	req := httptest.NewRequest(http.MethodPost, "/admin/preview", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = adminRequest(router, http.MethodPost, "/admin/preview", body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<img src="/static/diagram.png" alt="Diagram"`)
+}
+
+func TestDashboardListsEveryPost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// More posts than fit on one page of the query
+	dir := t.TempDir()
+	for i := 0; i < domain.MaxPerPage+5; i++ {
+		post := fmt.Sprintf("---\ntitle: Post %d\ndate: 2024-01-15\ndescription: D\npublished: %t\n---\n\nBody\n", i, i%10 != 0)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("post-%d.md", i)), []byte(post), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := content.NewContentStore(dir, false)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() failed: %v", err)
+	}
+	h := NewAdminHandler(service.NewPostService(repository.NewFilePostRepository(store)), markdown.New(markdown.DefaultExtensions))
+
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("admin.html").Parse(
+		`{{define "admin.html"}}{{.Total}}|{{len .Drafts}}|{{len .Published}}{{end}}`)))
+	router.GET("/admin", h.Dashboard)
+
+	w := formRequest(router, http.MethodGet, "/admin", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "105|11|94", w.Body.String())
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stylesheet returns a handler function serving generated CSS, such as the
+// code highlighting styles from markdown.HighlightCSS
+func Stylesheet(css []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, "text/css; charset=utf-8", css)
+	}
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/auth"
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// SessionCookie is the name of the signed admin session cookie
+const SessionCookie = "blog_session"
+
+// userKey is the gin context key holding the signed-in *domain.User
+const userKey = "user"
+
+// csrfKey is the gin context key holding the session's CSRF token
+const csrfKey = "csrfToken"
+
+// CSRFField and CSRFHeader carry the CSRF token on requests made with a
+// session: forms send the field, scripts the header
+const (
+	CSRFField  = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// AuthHandler handles admin sign-in, sign-out and user accounts, and
+// provides the middleware guarding /admin
+type AuthHandler struct {
+	userService   domain.UserService
+	sessions      *auth.Sessions
+	secureCookies bool
+	apiToken      string
+}
+
+// NewAuthHandler creates a new AuthHandler. Session cookies are marked
+// Secure when secureCookies is set. A non-empty apiToken is also accepted as
+// an "Authorization: Bearer" header on /admin/api, for scripts.
+func NewAuthHandler(userService domain.UserService, sessions *auth.Sessions, secureCookies bool, apiToken string) *AuthHandler {
+	return &AuthHandler{
+		userService:   userService,
+		sessions:      sessions,
+		secureCookies: secureCookies,
+		apiToken:      apiToken,
+	}
+}
+
+// CurrentUser returns the signed-in user set by RequireAuth, or nil
+func CurrentUser(c *gin.Context) *domain.User {
+	if user, ok := c.Get(userKey); ok {
+		return user.(*domain.User)
+	}
+	return nil
+}
+
+// CSRFToken returns the CSRF token of the session set by RequireAuth, for
+// forms and scripts to send back, or ""
+func CSRFToken(c *gin.Context) string {
+	return c.GetString(csrfKey)
+}
+
+// RequireAuth lets requests with a valid session through. Other page
+// requests are redirected to the login page; API requests get 401.
+// Requests other than GET and HEAD made with a session must also carry its
+// CSRF token, or they get 403.
+func (h *AuthHandler) RequireAuth(c *gin.Context) {
+	if h.apiToken != "" {
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(h.apiToken)) == 1 {
+				c.Next()
+				return
+			}
+		}
+	}
+
+	if value, err := c.Cookie(SessionCookie); err == nil {
+		if user := h.sessionUser(c, value); user != nil {
+			if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead && !h.sessions.CheckCSRF(value, requestCSRFToken(c)) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid CSRF token"})
+				return
+			}
+			c.Set(userKey, user)
+			c.Set(csrfKey, h.sessions.CSRFToken(value))
+			c.Next()
+			return
+		}
+	}
+
+	if c.Request.Method == http.MethodGet && !strings.HasPrefix(c.Request.URL.Path, "/admin/api/") {
+		c.Redirect(http.StatusSeeOther, "/admin/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+		c.Abort()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+}
+
+// LoginPage renders the sign-in form
+func (h *AuthHandler) LoginPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"Title": "Sign in",
+		"Year":  time.Now().Year(),
+		"Next":  safeNext(c.Query("next")),
+	})
+}
+
+// loginRequest is the body of a JSON sign-in request
+type loginRequest struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+	Next     string `json:"-" form:"next"`
+}
+
+// Login checks the submitted credentials and starts a session. Form posts
+// are redirected to the page that required sign-in; JSON posts get the user.
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	wantsJSON := strings.HasPrefix(c.ContentType(), "application/json")
+
+	user, err := h.userService.Authenticate(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		if wantsJSON {
+			authError(c, err)
+			return
+		}
+		status := http.StatusInternalServerError
+		message := "Sign-in failed, please try again."
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			status = http.StatusUnauthorized
+			message = "Incorrect username or password."
+		}
+		c.HTML(status, "login.html", gin.H{
+			"Title":    "Sign in",
+			"Year":     time.Now().Year(),
+			"Next":     safeNext(req.Next),
+			"Username": req.Username,
+			"Error":    message,
+		})
+		return
+	}
+
+	value, err := h.sessions.New(user.Username, auth.Stamp(user.PasswordHash, user.SessionVersion))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.setSessionCookie(c, value, int(h.sessions.TTL().Seconds()))
+
+	if wantsJSON {
+		// Scripts signing in send the token back on later requests
+		c.Header(CSRFHeader, h.sessions.CSRFToken(value))
+		c.JSON(http.StatusOK, user)
+		return
+	}
+	c.Redirect(http.StatusSeeOther, safeNext(req.Next))
+}
+
+// Logout ends the session and returns to the login page. A valid session
+// is revoked on the server too, along with the user's other sessions, so
+// copies of the cookie stop working.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if value, err := c.Cookie(SessionCookie); err == nil {
+		if user := h.sessionUser(c, value); user != nil {
+			if !h.sessions.CheckCSRF(value, requestCSRFToken(c)) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid CSRF token"})
+				return
+			}
+			if err := h.userService.RevokeSessions(c.Request.Context(), user.Username); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	h.setSessionCookie(c, "", -1)
+	c.Redirect(http.StatusSeeOther, "/admin/login")
+}
+
+// ListUsers lists the admin users
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	users, err := h.userService.GetUsers(c.Request.Context())
+	if err != nil {
+		authError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// CreateUser adds an admin user from a JSON username and password
+func (h *AuthHandler) CreateUser(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.Register(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		authError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// sessionUser returns the user of a session cookie value, or nil if the
+// value is invalid, expired or revoked
+func (h *AuthHandler) sessionUser(c *gin.Context, value string) *domain.User {
+	session, err := h.sessions.Verify(value)
+	if err != nil {
+		return nil
+	}
+	// Deleted users lose access even with an unexpired session
+	user, err := h.userService.GetUser(c.Request.Context(), session.Username)
+	if err != nil {
+		return nil
+	}
+	// A new password or a revocation changes the stamp
+	if subtle.ConstantTimeCompare([]byte(session.Stamp), []byte(auth.Stamp(user.PasswordHash, user.SessionVersion))) != 1 {
+		return nil
+	}
+	return user
+}
+
+// requestCSRFToken returns the CSRF token sent with a request
+func requestCSRFToken(c *gin.Context) string {
+	if token := c.GetHeader(CSRFHeader); token != "" {
+		return token
+	}
+	return c.PostForm(CSRFField)
+}
+
+func (h *AuthHandler) setSessionCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    value,
+		Path:     "/admin",
+		MaxAge:   maxAge,
+		Secure:   h.secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// authError writes the JSON error response for a user service error
+func authError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		status = http.StatusUnauthorized
+	case errors.Is(err, domain.ErrInvalidUser):
+		status = http.StatusBadRequest
+	case errors.Is(err, domain.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, domain.ErrUserExists):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// safeNext returns next if it is a local admin path, so the login form
+// can't be used to redirect to other sites
+func safeNext(next string) string {
+	if next == "/admin" || strings.HasPrefix(next, "/admin/") || strings.HasPrefix(next, "/admin?") {
+		return next
+	}
+	return "/admin"
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/auth"
+	"github.com/seanankenbruck/blog/internal/repository"
+	"github.com/seanankenbruck/blog/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuthRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	users := service.NewUserService(repository.NewFileUserRepository(filepath.Join(t.TempDir(), "users.json")))
+	if _, err := users.Register(context.Background(), "admin", "correct horse battery"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	h := NewAuthHandler(users, auth.NewSessions([]byte(strings.Repeat("k", 32)), time.Hour), true, "")
+
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("login.html").Parse(`login {{.Next}} {{.Error}}`)))
+	router.GET("/admin/login", h.LoginPage)
+	router.POST("/admin/login", h.Login)
+	router.POST("/admin/logout", h.Logout)
+	admin := router.Group("/admin", h.RequireAuth)
+	admin.GET("", func(c *gin.Context) { c.String(http.StatusOK, "hello "+CurrentUser(c).Username) })
+	admin.GET("/csrf", func(c *gin.Context) { c.String(http.StatusOK, CSRFToken(c)) })
+	admin.POST("/posts", func(c *gin.Context) { c.String(http.StatusOK, "saved") })
+	admin.GET("/api/users", h.ListUsers)
+	admin.POST("/api/users", h.CreateUser)
+	return router
+}
+
+func TestAuth(t *testing.T) {
+	router := setupAuthRouter(t)
+
+	login := func(username, password, next string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}, "next": {next}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unauthenticated requests are turned away", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin?tab=drafts", nil))
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/admin/login?next=%2Fadmin%3Ftab%3Ddrafts", w.Header().Get("Location"))
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/api/users", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Wrong password", func(t *testing.T) {
+		w := login("admin", "wrong password!", "/admin")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Incorrect username or password")
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("Sign in, use the session and sign out", func(t *testing.T) {
+		w := login("admin", "correct horse battery", "https://evil.example/admin")
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/admin", w.Header().Get("Location"), "off-site next must be ignored")
+
+		cookies := w.Result().Cookies()
+		if !assert.Len(t, cookies, 1) {
+			return
+		}
+		cookie := cookies[0]
+		assert.Equal(t, SessionCookie, cookie.Name)
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		assert.Equal(t, "/admin", cookie.Path)
+
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "hello admin", w.Body.String())
+
+		// A tampered cookie is rejected
+		req = httptest.NewRequest(http.MethodGet, "/admin/api/users", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: cookie.Value + "x"})
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// Forms posted with the session need its CSRF token
+		req = httptest.NewRequest(http.MethodGet, "/admin/csrf", nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		token := w.Body.String()
+		assert.NotEmpty(t, token)
+
+		post := func(path, token string) *httptest.ResponseRecorder {
+			form := url.Values{}
+			if token != "" {
+				form.Set(CSRFField, token)
+			}
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		assert.Equal(t, http.StatusForbidden, post("/admin/posts", "").Code)
+		assert.Equal(t, http.StatusForbidden, post("/admin/posts", token+"x").Code)
+		assert.Equal(t, http.StatusOK, post("/admin/posts", token).Code)
+		assert.Equal(t, http.StatusForbidden, post("/admin/logout", "").Code)
+
+		w = post("/admin/logout", token)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		if cleared := w.Result().Cookies(); assert.Len(t, cleared, 1) {
+			assert.Equal(t, "", cleared[0].Value)
+			assert.Less(t, cleared[0].MaxAge, 0)
+		}
+
+		// Signing out revokes the session, so a copy of the cookie is useless
+		req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+
+		// Without a session there is nothing to revoke
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/logout", nil))
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+	})
+
+	t.Run("JSON sign-in and user management", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(`{"username":"admin","password":"nope"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid credentials")
+
+		req = httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(`{"username":"admin","password":"correct horse battery"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "$2a$", "password hashes must not be exposed")
+		cookie := w.Result().Cookies()[0]
+		token := w.Header().Get(CSRFHeader)
+		assert.NotEmpty(t, token)
+
+		createUser := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/admin/api/users", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(CSRFHeader, token)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		assert.Equal(t, http.StatusCreated, createUser(`{"username":"editor","password":"another long password"}`).Code)
+		assert.Equal(t, http.StatusConflict, createUser(`{"username":"editor","password":"another long password"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, createUser(`{"username":"weak","password":"short"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, createUser(`{"username":"long","password":"`+strings.Repeat("a", 73)+`"}`).Code)
+	})
+}
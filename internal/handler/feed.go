@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/feed"
+)
+
+const (
+	rssContentType  = "application/rss+xml; charset=utf-8"
+	atomContentType = "application/atom+xml; charset=utf-8"
+)
+
+// FeedHandler serves RSS and Atom feeds of published posts
+type FeedHandler struct {
+	postService domain.PostService
+	site        feed.Site
+}
+
+// NewFeedHandler creates a new FeedHandler for the given site
+func NewFeedHandler(postService domain.PostService, site feed.Site) *FeedHandler {
+	return &FeedHandler{postService: postService, site: site}
+}
+
+// RSS serves the RSS 2.0 feed of all posts
+func (h *FeedHandler) RSS(c *gin.Context) {
+	posts, err := h.postService.GetAllPosts(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load posts")
+		return
+	}
+
+	h.render(c, feed.RSS, rssContentType, feed.Feed{
+		Title:       h.site.Title,
+		Description: h.site.Description,
+		Path:        "/posts",
+		SelfPath:    "/feed.xml",
+		Posts:       posts,
+	})
+}
+
+// Atom serves the Atom feed of all posts
+func (h *FeedHandler) Atom(c *gin.Context) {
+	posts, err := h.postService.GetAllPosts(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load posts")
+		return
+	}
+
+	h.render(c, feed.Atom, atomContentType, feed.Feed{
+		Title:       h.site.Title,
+		Description: h.site.Description,
+		Path:        "/posts",
+		SelfPath:    "/atom.xml",
+		Posts:       posts,
+	})
+}
+
+// TagRSS serves the RSS 2.0 feed of posts carrying a single tag
+func (h *FeedHandler) TagRSS(c *gin.Context) {
+	tag, posts, err := h.postService.GetPostsByTag(c.Request.Context(), c.Param("tag"))
+	if err != nil {
+		if err == domain.ErrTagNotFound {
+			c.String(http.StatusNotFound, "Tag not found")
+		} else {
+			c.String(http.StatusInternalServerError, "Failed to load posts")
+		}
+		return
+	}
+
+	h.render(c, feed.RSS, rssContentType, feed.Feed{
+		Title:       h.site.Title + " - " + tag.Name,
+		Description: "Posts tagged " + tag.Name,
+		Path:        "/tags/" + tag.Slug,
+		SelfPath:    "/tags/" + tag.Slug + "/feed.xml",
+		Posts:       posts,
+	})
+}
+
+// render encodes f with the given renderer and writes it to the response
+func (h *FeedHandler) render(c *gin.Context, render func(feed.Site, feed.Feed) ([]byte, error), contentType string, f feed.Feed) {
+	body, err := render(h.site, f)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to render feed")
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
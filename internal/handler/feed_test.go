@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/feed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedHandler(t *testing.T) {
+	_, svc := setupTestEnvironment(t)
+
+	h := NewFeedHandler(svc, feed.Site{
+		Title:       "Test Blog",
+		Description: "A blog for tests",
+		BaseURL:     "https://example.com",
+	})
+
+	router := gin.New()
+	router.GET("/feed.xml", h.RSS)
+	router.GET("/atom.xml", h.Atom)
+	router.GET("/tags/:tag/feed.xml", h.TagRSS)
+
+	t.Run("RSS feed", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/feed.xml", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/rss+xml")
+		assert.Contains(t, w.Body.String(), `<rss version="2.0"`)
+		assert.Contains(t, w.Body.String(), "<link>https://example.com/posts/test-post</link>")
+		assert.Contains(t, w.Body.String(), "<description>Test description</description>")
+	})
+
+	t.Run("Atom feed", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/atom.xml", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/atom+xml")
+		assert.Contains(t, w.Body.String(), `<feed xmlns="http://www.w3.org/2005/Atom">`)
+		assert.Contains(t, w.Body.String(), "<id>https://example.com/posts/test-post</id>")
+	})
+
+	t.Run("Tag feed", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags/software-development/feed.xml", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<title>Test Blog - Software Development</title>")
+		assert.Contains(t, w.Body.String(), `href="https://example.com/tags/software-development/feed.xml"`)
+	})
+
+	t.Run("Unknown tag feed returns 404", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags/rust/feed.xml", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
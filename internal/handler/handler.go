@@ -2,46 +2,60 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"html/template"
 	"io"
-	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/html"
-	"github.com/gomarkdown/markdown/parser"
 	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/markdown"
 )
 
-// SetupTemplates configures the template engine with custom functions
-func SetupTemplates(r *gin.Engine) error {
+// SetupTemplates loads the *.html templates in dir with custom functions.
+// Their data also holds CSPNonce, the nonce SecurityHeaders set.
+func SetupTemplates(r *gin.Engine, dir string) error {
 	// Set up template engine with custom functions
-	r.SetFuncMap(template.FuncMap{
+	funcs := template.FuncMap{
 		"safeHTML": func(text string) template.HTML {
 			return template.HTML(text)
 		},
-	})
-
-	// Try container path first, then fall back to local development path
-	templatesPath := "/templates/*.html"
-	if _, err := os.Stat("/templates"); os.IsNotExist(err) {
-		// We're in local development, use relative path
-		templatesPath = "templates/*.html"
+		"tagSlug": domain.TagSlug,
 	}
+	r.SetFuncMap(funcs)
 
 	// Load templates
-	r.LoadHTMLGlob(templatesPath)
+	templates, err := newNonceTemplates(filepath.Join(dir, "*.html"), funcs, gin.IsDebugging())
+	if err != nil {
+		return err
+	}
+	r.HTMLRender = templates
 	return nil
 }
 
+// GetPosts lists posts a page at a time. ?page= and ?per_page= select the
+// page; the JSON API also accepts ?tag=, ?from=, ?to=, ?sort= and ?cursor=.
 func GetPosts(svc domain.PostService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		posts, err := svc.GetAllPosts(c)
+		q, err := parsePostQuery(c)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := svc.ListPosts(c, q)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidQuery) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
 			return
 		}
 
@@ -50,19 +64,100 @@ func GetPosts(svc domain.PostService) gin.HandlerFunc {
 		// Check the Accept header to determine response format
 		accept := c.GetHeader("Accept")
 		if accept == "application/json" {
-			c.JSON(http.StatusOK, posts)
+			c.JSON(http.StatusOK, page)
+			return
+		}
+
+		// Pages past the end don't exist, but an empty blog still has page 1
+		if page.Page > 1 && page.Page > page.TotalPages {
+			c.HTML(http.StatusNotFound, "404.html", gin.H{"Title": "404 - Page Not Found", "Year": time.Now().Year()})
 			return
 		}
 
 		// Default to HTML response
 		c.HTML(http.StatusOK, "index.html", gin.H{
-			"Title": "All Posts",
-			"Year":  time.Now().Year(),
-			"Posts": posts,
+			"Title":      "All Posts",
+			"Year":       time.Now().Year(),
+			"Posts":      page.Posts,
+			"Pagination": page,
+			"PrevURL":    pageURL(c, page.PrevPage()),
+			"NextURL":    pageURL(c, page.NextPage()),
 		})
 	}
 }
 
+// parsePostQuery reads a domain.PostQuery from the request's query string
+func parsePostQuery(c *gin.Context) (domain.PostQuery, error) {
+	q := domain.PostQuery{
+		Tag:    c.Query("tag"),
+		Sort:   domain.PostSort(c.Query("sort")),
+		Cursor: c.Query("cursor"),
+	}
+
+	var err error
+	if q.Page, err = queryInt(c, "page"); err != nil {
+		return q, err
+	}
+	if q.PerPage, err = queryInt(c, "per_page"); err != nil {
+		return q, err
+	}
+	if q.From, err = queryDate(c, "from", false); err != nil {
+		return q, err
+	}
+	if q.To, err = queryDate(c, "to", true); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// queryInt parses a positive integer query parameter, returning 0 if unset
+func queryInt(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%w: %s must be a positive integer", domain.ErrInvalidQuery, name)
+	}
+	return n, nil
+}
+
+// queryDate parses an RFC 3339 timestamp or YYYY-MM-DD date query parameter.
+// A date used as an upper bound covers the whole day.
+func queryDate(c *gin.Context, name string, endOfDay bool) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be a date (YYYY-MM-DD) or RFC 3339 timestamp", domain.ErrInvalidQuery, name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// pageURL returns the current URL with its page parameter replaced
+func pageURL(c *gin.Context, page int) string {
+	values := c.Request.URL.Query()
+	values.Del("cursor")
+	if page <= 1 {
+		values.Del("page")
+	} else {
+		values.Set("page", strconv.Itoa(page))
+	}
+	if len(values) == 0 {
+		return c.Request.URL.Path
+	}
+	return c.Request.URL.Path + "?" + values.Encode()
+}
+
 func GetPost(svc domain.PostService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		slug := c.Param("slug")
@@ -88,9 +183,147 @@ func GetPost(svc domain.PostService) gin.HandlerFunc {
 			return
 		}
 
+		nav, err := svc.GetPostNavigation(ctx, post)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "500.html", nil)
+			return
+		}
+		related, err := svc.GetRelatedPosts(ctx, post.Slug, domain.DefaultRelatedPosts)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "500.html", nil)
+			return
+		}
+
 		// Default to HTML response
 		c.HTML(http.StatusOK, "post.html", gin.H{
-			"Post": post,
+			"Post":    post,
+			"Nav":     nav,
+			"Related": related,
+		})
+	}
+}
+
+func GetTags(svc domain.PostService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tags, err := svc.GetTags(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Check the Accept header to determine response format
+		accept := c.GetHeader("Accept")
+		if accept == "application/json" {
+			c.JSON(http.StatusOK, tags)
+			return
+		}
+
+		// Default to HTML response
+		c.HTML(http.StatusOK, "tags.html", gin.H{
+			"Title": "Tags",
+			"Year":  time.Now().Year(),
+			"Tags":  tags,
+		})
+	}
+}
+
+func GetTagPosts(svc domain.PostService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag, posts, err := svc.GetPostsByTag(c.Request.Context(), c.Param("tag"))
+		accept := c.GetHeader("Accept")
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == domain.ErrTagNotFound {
+				status = http.StatusNotFound
+			}
+			if accept == "application/json" {
+				c.JSON(status, gin.H{"error": err.Error()})
+			} else if status == http.StatusNotFound {
+				c.HTML(status, "404.html", nil)
+			} else {
+				c.HTML(status, "500.html", nil)
+			}
+			return
+		}
+
+		if accept == "application/json" {
+			c.JSON(http.StatusOK, gin.H{
+				"tag":   tag,
+				"posts": posts,
+			})
+			return
+		}
+
+		// Tag listings reuse the post index template
+		c.HTML(http.StatusOK, "index.html", gin.H{
+			"Title":   "Posts tagged " + tag.Name,
+			"Heading": "Posts tagged " + tag.Name,
+			"Year":    time.Now().Year(),
+			"Tag":     tag,
+			"Posts":   posts,
+		})
+	}
+}
+
+// GetSeries lists the posts of a series in reading order
+func GetSeries(svc domain.PostService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		series, err := svc.GetSeries(c.Request.Context(), c.Param("name"))
+		accept := c.GetHeader("Accept")
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == domain.ErrSeriesNotFound {
+				status = http.StatusNotFound
+			}
+			if accept == "application/json" {
+				c.JSON(status, gin.H{"error": err.Error()})
+			} else if status == http.StatusNotFound {
+				c.HTML(status, "404.html", nil)
+			} else {
+				c.HTML(status, "500.html", nil)
+			}
+			return
+		}
+
+		if accept == "application/json" {
+			c.JSON(http.StatusOK, series)
+			return
+		}
+
+		// Series listings reuse the post index template
+		c.HTML(http.StatusOK, "index.html", gin.H{
+			"Title":   "Series: " + series.Name,
+			"Heading": "Series: " + series.Name,
+			"Year":    time.Now().Year(),
+			"Series":  series,
+			"Posts":   series.Posts,
+		})
+	}
+}
+
+// Search handles full-text search of posts via ?q=
+func Search(svc domain.PostService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
+		results, err := svc.Search(c.Request.Context(), query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Check the Accept header to determine response format
+		accept := c.GetHeader("Accept")
+		if accept == "application/json" {
+			c.JSON(http.StatusOK, gin.H{"query": query, "results": results})
+			return
+		}
+
+		// Default to HTML response
+		c.HTML(http.StatusOK, "search.html", gin.H{
+			"Title":   "Search",
+			"Year":    time.Now().Year(),
+			"Query":   query,
+			"Results": results,
 		})
 	}
 }
@@ -126,45 +359,51 @@ func PortfolioPage() gin.HandlerFunc {
 	}
 }
 
-// PreviewMarkdown returns a handler function that renders markdown to HTML
+// PreviewMarkdown returns the public markdown preview handler. Anyone can
+// post to it, so it sanitizes with markdown.StrictPolicy; the editor's
+// preview, AdminHandler.Preview, renders exactly as published posts are.
 func (h *PostHandler) PreviewMarkdown() gin.HandlerFunc {
+	return previewMarkdown(h.preview)
+}
+
+// previewMarkdown returns a handler function that renders the markdown
+// request body to HTML with renderer
+func previewMarkdown(renderer *markdown.Renderer) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Read the markdown from the request body
+		// Read the markdown from the request body, capped by BodyLimit
 		body, err := io.ReadAll(c.Request.Body)
+		if tooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
 			return
 		}
 
-		// Create a markdown parser with extensions
-		extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.SuperSubscript
-		p := parser.NewWithExtensions(extensions)
-
-		// Parse the markdown
-		doc := p.Parse(body)
-
-		// Create a renderer with HTML options
-		htmlFlags := html.CommonFlags | html.HrefTargetBlank
-		opts := html.RendererOptions{Flags: htmlFlags}
-		renderer := html.NewRenderer(opts)
-
-		// Render to HTML
-		html := markdown.Render(doc, renderer)
+		html, err := renderer.Render(string(body))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render markdown"})
+			return
+		}
 
-		// Set the content type and return the HTML
-		c.Header("Content-Type", "text/html")
-		c.String(http.StatusOK, string(html))
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
 	}
 }
 
 // PostHandler handles post-related HTTP requests
 type PostHandler struct {
 	postService domain.PostService
+	preview     *markdown.Renderer
 }
 
-// NewPostHandler creates a new PostHandler
-func NewPostHandler(postService domain.PostService) *PostHandler {
-	return &PostHandler{postService: postService}
+// NewPostHandler creates a new PostHandler. renderer should be the one posts
+// are loaded with; the public preview uses it with markdown.StrictPolicy.
+func NewPostHandler(postService domain.PostService, renderer *markdown.Renderer) *PostHandler {
+	return &PostHandler{
+		postService: postService,
+		preview:     renderer.WithPolicy(markdown.StrictPolicy()),
+	}
 }
 
 func (h *PostHandler) HomePage(c *gin.Context) {
@@ -178,3 +417,19 @@ func (h *PostHandler) GetPosts(c *gin.Context) {
 func (h *PostHandler) GetPost(c *gin.Context) {
 	GetPost(h.postService)(c)
 }
+
+func (h *PostHandler) GetTags(c *gin.Context) {
+	GetTags(h.postService)(c)
+}
+
+func (h *PostHandler) GetTagPosts(c *gin.Context) {
+	GetTagPosts(h.postService)(c)
+}
+
+func (h *PostHandler) GetSeries(c *gin.Context) {
+	GetSeries(h.postService)(c)
+}
+
+func (h *PostHandler) Search(c *gin.Context) {
+	Search(h.postService)(c)
+}
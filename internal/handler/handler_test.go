@@ -11,12 +11,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/seanankenbruck/blog/internal/content"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/markdown"
 	"github.com/seanankenbruck/blog/internal/repository"
 	"github.com/seanankenbruck/blog/internal/service"
 	"github.com/stretchr/testify/assert"
 )
 
-func setupTestEnvironment(t *testing.T) (*gin.Engine, string) {
+func setupTestEnvironment(t *testing.T) (*gin.Engine, domain.PostService) {
 	// Set Gin to test mode
 	gin.SetMode(gin.TestMode)
 
@@ -26,8 +28,11 @@ func setupTestEnvironment(t *testing.T) (*gin.Engine, string) {
 title: "Test Post"
 slug: "test-post"
 date: 2024-01-15T10:00:00Z
+tags: ["Go", "Software Development"]
 description: "Test description"
 published: true
+series: "Testing Handlers"
+series_order: 1
 ---
 
 Test content.`
@@ -37,13 +42,13 @@ Test content.`
 	}
 
 	// Initialize content loader with test directory
-	content.Init(tempDir, false)
-	if err := content.LoadPosts(); err != nil {
+	store := content.NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
 		t.Fatalf("LoadPosts() failed: %v", err)
 	}
 
 	// Create repository and service
-	repo := repository.NewFilePostRepository()
+	repo := repository.NewFilePostRepository(store)
 	svc := service.NewPostService(repo)
 
 	// Setup Gin router
@@ -114,6 +119,7 @@ Test content.`
     <article class="post">
         <h1>{{.Post.Title}}</h1>
         <h3>{{.Post.Description}}</h3>
+        {{with .Nav}}{{if .Series}}<p>Part {{.Part}} of {{len .Series.Posts}} in {{.Series.Name}}</p>{{end}}{{end}}
         <div>{{ .Post.HTMLContent | safeHTML }}</div>
     </article>
 </body>
@@ -129,6 +135,30 @@ Test content.`
     <h1>404</h1>
     <p>Post not found</p>
 </body>
+</html>`
+
+	tagsTemplate := `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Tags</title>
+</head>
+<body>
+    <h1>Tags</h1>
+    {{range .Tags}}<a href="/tags/{{.Slug}}">{{.Name}} ({{.Count}})</a>{{end}}
+</body>
+</html>`
+
+	searchTemplate := `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Search</title>
+</head>
+<body>
+    <h1>Search: {{.Query}}</h1>
+    {{range .Results}}<a href="/posts/{{.Post.Slug}}">{{.Post.Title}}</a><p>{{safeHTML .Snippet}}</p>{{end}}
+</body>
 </html>`
 
 	// Write template files
@@ -138,6 +168,8 @@ Test content.`
 		"portfolio.html": portfolioTemplate,
 		"post.html":      postTemplate,
 		"404.html":       errorTemplate,
+		"tags.html":      tagsTemplate,
+		"search.html":    searchTemplate,
 	}
 
 	for name, tmpl := range templates {
@@ -153,8 +185,12 @@ Test content.`
 	router.GET("/", HomePage(svc))
 	router.GET("/portfolio", PortfolioPage())
 	router.GET("/posts/:slug", GetPost(svc))
+	router.GET("/tags", GetTags(svc))
+	router.GET("/tags/:tag", GetTagPosts(svc))
+	router.GET("/series/:name", GetSeries(svc))
+	router.GET("/search", Search(svc))
 
-	return router, templateDir
+	return router, svc
 }
 
 func TestHomePageIntegration(t *testing.T) {
@@ -206,6 +242,7 @@ func TestGetPostHTMLIntegration(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Test Post")
 		assert.Contains(t, w.Body.String(), "Test description")
+		assert.Contains(t, w.Body.String(), "Part 1 of 1 in Testing Handlers")
 		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
 	})
 
@@ -229,7 +266,7 @@ func TestSetupTemplatesIntegration(t *testing.T) {
 }
 
 func TestGetPosts(t *testing.T) {
-	router, _ := setupTestEnvironment(t)
+	router, svc := setupTestEnvironment(t)
 
 	req, err := http.NewRequest(http.MethodGet, "/posts", nil)
 	if err != nil {
@@ -238,7 +275,7 @@ func TestGetPosts(t *testing.T) {
 	req.Header.Set("Accept", "application/json")
 
 	w := httptest.NewRecorder()
-	router.GET("/posts", GetPosts(service.NewPostService(repository.NewFilePostRepository())))
+	router.GET("/posts", GetPosts(svc))
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -260,6 +297,9 @@ func TestGetPost(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Test Post")
+		assert.Contains(t, w.Body.String(), `"word_count":`)
+		assert.Contains(t, w.Body.String(), `"reading_time":1`)
+		assert.Contains(t, w.Body.String(), `"summary":"\u003cp\u003e`)
 	})
 }
 
@@ -282,14 +322,14 @@ Test content.`
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	content.Init(tempDir, false)
-	if err := content.LoadPosts(); err != nil {
+	store := content.NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
 		t.Fatalf("LoadPosts() failed: %v", err)
 	}
 
-	repo := repository.NewFilePostRepository()
+	repo := repository.NewFilePostRepository(store)
 	svc := service.NewPostService(repo)
-	handler := NewPostHandler(svc)
+	handler := NewPostHandler(svc, markdown.New(markdown.DefaultExtensions))
 
 	assert.NotNil(t, handler)
 
@@ -349,9 +389,88 @@ Test content.`
 	})
 }
 
+// TestPreviewSanitizes posts script through the public preview, which
+// sanitizes with the strict policy
+func TestPreviewSanitizes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewPostHandler(nil, markdown.New(markdown.DefaultExtensions))
+
+	router := gin.New()
+	router.POST("/preview", handler.PreviewMarkdown())
+
+	body := "# Hi\n\n<script>alert(document.cookie)</script>\n\n<img src=x onerror=alert(1)>\n\n[link](javascript:alert(1))\n"
+	req, _ := http.NewRequest(http.MethodPost, "/preview", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h1 id="hi">Hi</h1>`)
+	assert.NotContains(t, w.Body.String(), "<script")
+	assert.NotContains(t, w.Body.String(), "<img")
+	assert.NotContains(t, w.Body.String(), "javascript:")
+}
+
+// TestPreviewMatchesPublished renders the markdown golden files both as
+// published posts and through the editor's preview, wired as in main; the
+// HTML must be identical
+func TestPreviewMatchesPublished(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inputs, err := filepath.Glob(filepath.Join("..", "markdown", "testdata", "*.md"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("No golden inputs found: %v", err)
+	}
+
+	renderer := markdown.New(markdown.DefaultExtensions)
+	tempDir := t.TempDir()
+	sources := make(map[string]string)
+	for _, input := range inputs {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slug := strings.TrimSuffix(filepath.Base(input), ".md")
+		sources[slug] = string(src)
+
+		post := "---\ntitle: " + slug + "\ndate: 2024-01-15\ndescription: Golden\npublished: true\n---\n\n" + string(src)
+		if err := os.WriteFile(filepath.Join(tempDir, slug+".md"), []byte(post), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := content.NewContentStore(tempDir, false)
+	store.SetRenderer(renderer)
+	if err := store.LoadPosts(); err != nil {
+		t.Fatalf("LoadPosts() failed: %v", err)
+	}
+	handler := NewAdminHandler(service.NewPostService(repository.NewFilePostRepository(store)), renderer)
+
+	router := gin.New()
+	router.POST("/admin/preview", handler.Preview)
+
+	for slug, src := range sources {
+		t.Run(slug, func(t *testing.T) {
+			post, err := store.GetPostBySlug(slug)
+			if err != nil {
+				t.Fatalf("GetPostBySlug() failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, "/admin/preview", strings.NewReader(src))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, post.HTMLContent, w.Body.String())
+
+			golden, err := os.ReadFile(filepath.Join("..", "markdown", "testdata", slug+".html"))
+			assert.NoError(t, err)
+			assert.Equal(t, string(golden), w.Body.String())
+		})
+	}
+}
+
 func TestGetPostsHTMLResponse(t *testing.T) {
-	router, _ := setupTestEnvironment(t)
-	router.GET("/posts", GetPosts(service.NewPostService(repository.NewFilePostRepository())))
+	router, svc := setupTestEnvironment(t)
+	router.GET("/posts", GetPosts(svc))
 
 	// Test HTML response (no Accept header)
 	req, _ := http.NewRequest(http.MethodGet, "/posts", nil)
@@ -361,3 +480,202 @@ func TestGetPostsHTMLResponse(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
 }
+
+func TestGetTags(t *testing.T) {
+	router, _ := setupTestEnvironment(t)
+
+	t.Run("HTML tag index", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), `href="/tags/software-development"`)
+		assert.Contains(t, w.Body.String(), "Software Development (1)")
+	})
+
+	t.Run("JSON tag index", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"slug":"go"`)
+		assert.Contains(t, w.Body.String(), `"count":1`)
+	})
+}
+
+func TestGetTagPosts(t *testing.T) {
+	router, _ := setupTestEnvironment(t)
+
+	t.Run("HTML tag listing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags/software-development", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), "Test Post")
+	})
+
+	t.Run("JSON tag listing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags/go", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"tag":{"name":"Go","slug":"go","count":1}`)
+		assert.Contains(t, w.Body.String(), `"tags":["Go","Software Development"]`)
+	})
+
+	t.Run("Unknown tag returns 404", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags/rust", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "404")
+	})
+
+	t.Run("Unknown tag returns JSON 404", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/tags/rust", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "tag not found")
+	})
+}
+
+func TestGetSeries(t *testing.T) {
+	router, _ := setupTestEnvironment(t)
+
+	t.Run("HTML series listing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/series/testing-handlers", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Test Post")
+	})
+
+	t.Run("JSON series listing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/series/testing-handlers", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"Testing Handlers","slug":"testing-handlers"`)
+		assert.Contains(t, w.Body.String(), `"series_order":1`)
+	})
+
+	t.Run("Unknown series returns 404", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/series/missing", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "series not found")
+	})
+}
+
+func TestSearch(t *testing.T) {
+	router, _ := setupTestEnvironment(t)
+
+	t.Run("HTML results with highlighted snippet", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/search?q=contents", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), `<a href="/posts/test-post">Test Post</a>`)
+		assert.Contains(t, w.Body.String(), "Test <mark>content</mark>.")
+	})
+
+	t.Run("JSON results", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/search?q=software", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"query":"software"`)
+		assert.Contains(t, w.Body.String(), `"slug":"test-post"`)
+		assert.Contains(t, w.Body.String(), `"score":`)
+	})
+
+	t.Run("No matches", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/search?q=kubernetes", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"results":[]`)
+	})
+}
+
+func TestGetPostsPagination(t *testing.T) {
+	router, svc := setupTestEnvironment(t)
+	router.GET("/posts", GetPosts(svc))
+
+	t.Run("JSON envelope", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/posts?per_page=1&tag=go&from=2024-01-01&to=2024-01-15&sort=oldest", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"slug":"test-post"`)
+		assert.Contains(t, w.Body.String(), `"total":1`)
+		assert.Contains(t, w.Body.String(), `"per_page":1`)
+		assert.Contains(t, w.Body.String(), `"total_pages":1`)
+	})
+
+	t.Run("Date range excludes posts", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/posts?to=2024-01-14", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"posts":[]`)
+		assert.Contains(t, w.Body.String(), `"total":0`)
+	})
+
+	t.Run("Invalid parameters return 400", func(t *testing.T) {
+		for _, query := range []string{"page=0", "per_page=abc", "from=yesterday", "sort=popular", "cursor=%21"} {
+			req, _ := http.NewRequest(http.MethodGet, "/posts?"+query, nil)
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			assert.Contains(t, w.Body.String(), "invalid query", query)
+		}
+	})
+
+	t.Run("HTML page past the end returns 404", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/posts?page=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestPageURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/posts?page=2&per_page=5&cursor=abc", nil)
+
+	assert.Equal(t, "/posts?page=3&per_page=5", pageURL(c, 3))
+	assert.Equal(t, "/posts?per_page=5", pageURL(c, 1))
+}
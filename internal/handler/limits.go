@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idleSweepInterval is how often a RateLimiter forgets clients whose bucket
+// has refilled, so memory follows the number of recent clients
+const idleSweepInterval = time.Minute
+
+// RateLimiter is a per-client token bucket. Each client IP may make burst
+// requests at once, refilled at rate requests per second. Client IPs come
+// from gin's Context.ClientIP, which only trusts X-Forwarded-For from the
+// engine's trusted proxies.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket holds a client's tokens as of updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing perMinute requests a minute
+// per client, with bursts of up to burst requests. A perMinute of zero or
+// less disables limiting.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Limit lets requests through while the client has tokens left. Other
+// requests get 429 with a Retry-After header of the seconds until the next
+// token.
+func (l *RateLimiter) Limit(c *gin.Context) {
+	if l.rate <= 0 {
+		c.Next()
+		return
+	}
+	if wait, ok := l.allow(c.ClientIP()); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+		return
+	}
+	c.Next()
+}
+
+// allow takes a token from client's bucket. Without a token it returns how
+// long until one is available.
+func (l *RateLimiter) allow(client string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= idleSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// sweep drops the buckets that have refilled since they were last used,
+// as a new bucket would be identical
+func (l *RateLimiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// BodyLimit caps request bodies at maxBytes. Requests declaring a larger
+// Content-Length get 413 straight away; handlers reading a longer body get
+// an *http.MaxBytesError, which tooLarge recognises. A maxBytes of zero or
+// less disables the limit.
+func BodyLimit(maxBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > int64(maxBytes) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBytes))
+		c.Next()
+	}
+}
+
+// tooLarge reports whether err came from reading past a BodyLimit
+func tooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
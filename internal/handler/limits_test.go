@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/markdown"
+	"github.com/stretchr/testify/assert"
+)
+
+// limitedRouter serves POST /limited behind limiter, trusting proxies
+func limitedRouter(t *testing.T, limiter *RateLimiter, proxies []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		t.Fatal(err)
+	}
+	router.POST("/limited", limiter.Limit, func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return router
+}
+
+func postFrom(router *gin.Engine, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/limited", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(6, 2)
+	limiter.now = func() time.Time { return now }
+	router := limitedRouter(t, limiter, nil)
+
+	// The burst is allowed, then the client has to wait for a token
+	assert.Equal(t, http.StatusOK, postFrom(router, "203.0.113.1:1234", "").Code)
+	assert.Equal(t, http.StatusOK, postFrom(router, "203.0.113.1:1234", "").Code)
+	w := postFrom(router, "203.0.113.1:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	// Other clients have their own bucket
+	assert.Equal(t, http.StatusOK, postFrom(router, "203.0.113.2:1234", "").Code)
+
+	// At 6 a minute a token comes back every 10 seconds
+	now = now.Add(4 * time.Second)
+	w = postFrom(router, "203.0.113.1:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "6", w.Header().Get("Retry-After"))
+	now = now.Add(6 * time.Second)
+	assert.Equal(t, http.StatusOK, postFrom(router, "203.0.113.1:1234", "").Code)
+
+	// Refilled buckets are forgotten
+	now = now.Add(time.Hour)
+	postFrom(router, "203.0.113.3:1234", "")
+	if len(limiter.buckets) != 1 {
+		t.Errorf("Expected idle buckets to be swept, %d left", len(limiter.buckets))
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	router := limitedRouter(t, NewRateLimiter(0, 1), nil)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, postFrom(router, "203.0.113.1:1234", "").Code)
+	}
+}
+
+func TestRateLimiterForwardedFor(t *testing.T) {
+	// Without trusted proxies X-Forwarded-For can't be used to dodge the limit
+	router := limitedRouter(t, NewRateLimiter(1, 1), nil)
+	assert.Equal(t, http.StatusOK, postFrom(router, "203.0.113.1:1234", "198.51.100.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, postFrom(router, "203.0.113.1:1234", "198.51.100.2").Code)
+
+	// Behind a trusted proxy each forwarded client is limited on its own
+	router = limitedRouter(t, NewRateLimiter(1, 1), []string{"10.0.0.0/8"})
+	assert.Equal(t, http.StatusOK, postFrom(router, "10.0.0.5:1234", "198.51.100.1").Code)
+	assert.Equal(t, http.StatusOK, postFrom(router, "10.0.0.5:1234", "198.51.100.2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, postFrom(router, "10.0.0.5:1234", "198.51.100.1").Code)
+}
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewPostHandler(nil, markdown.New(markdown.DefaultExtensions))
+	router := gin.New()
+	router.POST("/preview", BodyLimit(16), handler.PreviewMarkdown())
+
+	req, _ := http.NewRequest(http.MethodPost, "/preview", strings.NewReader("# Short"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A declared length over the limit is refused before reading
+	req, _ = http.NewRequest(http.MethodPost, "/preview", strings.NewReader(strings.Repeat("a", 17)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// So is a body longer than declared, such as a chunked upload
+	req, _ = http.NewRequest(http.MethodPost, "/preview", strings.NewReader(strings.Repeat("a", 17)))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
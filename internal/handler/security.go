@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"html/template"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// NoncePlaceholder in a CSP directive is replaced by the request's nonce
+const NoncePlaceholder = "{nonce}"
+
+// nonceKey is the gin context key holding the request's CSP nonce
+const nonceKey = "cspNonce"
+
+// SecurityPolicy configures the headers set by SecurityHeaders. Empty
+// fields leave their header out.
+type SecurityPolicy struct {
+	// CSP lists the Content-Security-Policy directives, e.g.
+	// "script-src 'self' 'nonce-{nonce}'"
+	CSP []string
+	// FrameAncestors is the CSP frame-ancestors source list. "'none'" and
+	// "'self'" are also sent as X-Frame-Options for older browsers.
+	FrameAncestors string
+	// HSTSMaxAge is sent as Strict-Transport-Security on HTTPS requests
+	HSTSMaxAge time.Duration
+	// ReferrerPolicy is the Referrer-Policy header
+	ReferrerPolicy string
+	// PermissionsPolicy is the Permissions-Policy header
+	PermissionsPolicy string
+}
+
+// DefaultSecurityPolicy allows scripts from the site and nonce'd inline
+// blocks, styles and fonts from the site and Google Fonts, and images from
+// anywhere over HTTPS, as posts may link them
+func DefaultSecurityPolicy() SecurityPolicy {
+	return SecurityPolicy{
+		CSP: []string{
+			"default-src 'self'",
+			"script-src 'self' 'nonce-" + NoncePlaceholder + "'",
+			// Templates and table alignment use style attributes
+			"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com",
+			"font-src 'self' https://fonts.gstatic.com",
+			"img-src 'self' https: data:",
+			"connect-src 'self'",
+			"object-src 'none'",
+			"base-uri 'self'",
+			"form-action 'self'",
+		},
+		FrameAncestors:    "'none'",
+		HSTSMaxAge:        365 * 24 * time.Hour,
+		ReferrerPolicy:    "strict-origin-when-cross-origin",
+		PermissionsPolicy: "camera=(), microphone=(), geolocation=(), payment=(), usb=(), interest-cohort=()",
+	}
+}
+
+// AdminSecurityPolicy tightens DefaultSecurityPolicy for /admin: images
+// only from the site and no referrer sent anywhere
+func AdminSecurityPolicy() SecurityPolicy {
+	p := DefaultSecurityPolicy()
+	for i, directive := range p.CSP {
+		if strings.HasPrefix(directive, "img-src ") {
+			p.CSP[i] = "img-src 'self' data:"
+		}
+	}
+	p.ReferrerPolicy = "no-referrer"
+	return p
+}
+
+// SecurityHeaders sets the headers of policy on every response, along with
+// X-Content-Type-Options. The request's CSP nonce is created by the first
+// SecurityHeaders to run and reused by later ones, so a route group can
+// replace the engine's policy with its own.
+func SecurityHeaders(policy SecurityPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nonce := CSPNonce(c)
+		if nonce == "" {
+			var err error
+			if nonce, err = newNonce(); err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to create nonce"})
+				return
+			}
+			c.Set(nonceKey, nonce)
+			c.Writer = &nonceWriter{ResponseWriter: c.Writer, nonce: nonce}
+		}
+
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		setOrDelete(h, "Content-Security-Policy", policy.contentSecurityPolicy(nonce))
+		setOrDelete(h, "Referrer-Policy", policy.ReferrerPolicy)
+		setOrDelete(h, "Permissions-Policy", policy.PermissionsPolicy)
+
+		switch policy.FrameAncestors {
+		case "'none'":
+			h.Set("X-Frame-Options", "DENY")
+		case "'self'":
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		default:
+			h.Del("X-Frame-Options")
+		}
+
+		// Browsers ignore HSTS over plain HTTP, so trusting a proxy's
+		// X-Forwarded-Proto here can't do harm
+		h.Del("Strict-Transport-Security")
+		if policy.HSTSMaxAge > 0 && (c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https") {
+			h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(policy.HSTSMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		c.Next()
+	}
+}
+
+// CSPNonce returns the request's CSP nonce, or "" outside SecurityHeaders
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(nonceKey)
+}
+
+// contentSecurityPolicy returns the CSP header value for a request
+func (p SecurityPolicy) contentSecurityPolicy(nonce string) string {
+	directives := make([]string, 0, len(p.CSP)+1)
+	for _, directive := range p.CSP {
+		directives = append(directives, strings.ReplaceAll(directive, NoncePlaceholder, nonce))
+	}
+	if p.FrameAncestors != "" {
+		directives = append(directives, "frame-ancestors "+p.FrameAncestors)
+	}
+	return strings.Join(directives, "; ")
+}
+
+func setOrDelete(h http.Header, key, value string) {
+	if value == "" {
+		h.Del(key)
+		return
+	}
+	h.Set(key, value)
+}
+
+// newNonce returns 128 random bits, base64url encoded so templates can use
+// the nonce without escaping
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// nonceWriter carries the request's nonce to nonceHTML, which only sees
+// the response writer
+type nonceWriter struct {
+	gin.ResponseWriter
+	nonce string
+}
+
+// nonceData is the template data key holding the request's CSP nonce
+const nonceData = "CSPNonce"
+
+// nonceTemplates renders HTML templates with the request's nonce added to
+// their data as CSPNonce, for scripts to use as {{$.CSPNonce}}
+type nonceTemplates struct {
+	glob  string
+	funcs template.FuncMap
+	debug bool
+
+	mu        sync.Mutex
+	templates *template.Template
+}
+
+// newNonceTemplates parses the templates matching glob. With debug set
+// they are parsed again for every response, so edits show up on reload.
+func newNonceTemplates(glob string, funcs template.FuncMap, debug bool) (*nonceTemplates, error) {
+	t := &nonceTemplates{glob: glob, funcs: funcs, debug: debug}
+	var err error
+	t.templates, err = t.parse()
+	return t, err
+}
+
+func (t *nonceTemplates) parse() (*template.Template, error) {
+	return template.New(filepath.Base(t.glob)).Funcs(t.funcs).ParseGlob(t.glob)
+}
+
+// Instance implements render.HTMLRender
+func (t *nonceTemplates) Instance(name string, data any) render.Render {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.debug {
+		if templates, err := t.parse(); err == nil {
+			t.templates = templates
+		}
+	}
+	return &nonceHTML{templates: t.templates, name: name, data: data}
+}
+
+// nonceHTML is a render.Render executing one template with the nonce
+type nonceHTML struct {
+	templates *template.Template
+	name      string
+	data      any
+}
+
+// Render implements render.Render
+func (h *nonceHTML) Render(w http.ResponseWriter) error {
+	h.WriteContentType(w)
+
+	var nonce string
+	if nw, ok := w.(*nonceWriter); ok {
+		nonce = nw.nonce
+	}
+	return h.templates.ExecuteTemplate(w, h.name, withNonce(h.data, nonce))
+}
+
+// WriteContentType implements render.Render
+func (h *nonceHTML) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+}
+
+// withNonce returns a copy of page data with the nonce added. Pages are
+// rendered with gin.H or nil; other data is passed through unchanged.
+func withNonce(data any, nonce string) any {
+	var m map[string]any
+	switch d := data.(type) {
+	case nil:
+	case gin.H:
+		m = d
+	case map[string]any:
+		m = d
+	default:
+		return data
+	}
+	out := make(gin.H, len(m)+1)
+	maps.Copy(out, m)
+	out[nonceData] = nonce
+	return out
+}
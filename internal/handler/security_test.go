@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecurityHeaders(DefaultSecurityPolicy()))
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, CSPNonce(c))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	nonce := w.Body.String()
+	assert.Len(t, nonce, 24)
+	csp := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "script-src 'self' 'nonce-"+nonce+"'")
+	assert.Contains(t, csp, "frame-ancestors 'none'")
+	assert.Contains(t, csp, "https://fonts.googleapis.com")
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"))
+	assert.Contains(t, w.Header().Get("Permissions-Policy"), "camera=()")
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"), "HSTS over plain HTTP")
+
+	// Every request gets its own nonce
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NotEqual(t, nonce, w.Body.String())
+
+	// HSTS is sent once TLS is terminated, here or at a proxy
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestSecurityHeadersPerGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecurityHeaders(DefaultSecurityPolicy()))
+
+	embeddable := DefaultSecurityPolicy()
+	embeddable.FrameAncestors = "'self'"
+	embeddable.PermissionsPolicy = ""
+	embeddable.HSTSMaxAge = 0
+	group := router.Group("/embed", SecurityHeaders(embeddable))
+	group.GET("", func(c *gin.Context) {
+		c.String(http.StatusOK, CSPNonce(c))
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/embed", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The group's policy replaces the engine's, keeping the same nonce
+	csp := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "frame-ancestors 'self'")
+	assert.Contains(t, csp, "'nonce-"+w.Body.String()+"'")
+	assert.Equal(t, "SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+	assert.Empty(t, w.Header().Get("Permissions-Policy"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	admin := AdminSecurityPolicy()
+	assert.Contains(t, admin.contentSecurityPolicy("n"), "img-src 'self' data:;")
+	assert.Equal(t, "no-referrer", admin.ReferrerPolicy)
+}
+
+func TestNonceTemplates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	page := `<p>{{with .Name}}{{upper .}}{{end}}</p><script nonce="{{$.CSPNonce}}">run()</script>`
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := newNonceTemplates(filepath.Join(dir, "*.html"), template.FuncMap{"upper": strings.ToUpper}, false)
+	if err != nil {
+		t.Fatalf("newNonceTemplates() error = %v", err)
+	}
+	router := gin.New()
+	router.HTMLRender = templates
+	router.Use(SecurityHeaders(DefaultSecurityPolicy()))
+	router.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "page.html", gin.H{"Name": "post"})
+	})
+	// Error pages are rendered without data
+	router.GET("/error", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "page.html", nil)
+	})
+
+	for _, path := range []string{"/", "/", "/error"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		if path == "/" {
+			assert.Contains(t, w.Body.String(), "<p>POST</p>")
+		}
+		nonce := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(w.Header().Get("Content-Security-Policy"))
+		if assert.Len(t, nonce, 2) {
+			assert.Contains(t, w.Body.String(), `<script nonce="`+nonce[1]+`">`)
+		}
+	}
+}
+
+// TestTemplatesUseNonces parses the site's templates and checks that no
+// inline event handlers or scripts without a nonce are left
+func TestTemplatesUseNonces(t *testing.T) {
+	glob := filepath.Join("..", "..", "templates", "*.html")
+	if _, err := newNonceTemplates(glob, template.FuncMap{
+		"safeHTML": func(string) template.HTML { return "" },
+		"tagSlug":  strings.ToLower,
+	}, false); err != nil {
+		t.Fatalf("Templates failed to parse: %v", err)
+	}
+
+	files, _ := filepath.Glob(glob)
+	inlineHandler := regexp.MustCompile(`\son[a-z]+=`)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inlineHandler.Match(src) {
+			t.Errorf("%s has an inline event handler", file)
+		}
+		if strings.Contains(string(src), "<script>") {
+			t.Errorf("%s has a script without a nonce", file)
+		}
+	}
+}
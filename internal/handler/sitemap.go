@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/sitemap"
+)
+
+const sitemapContentType = "application/xml; charset=utf-8"
+
+// SitemapHandler serves sitemap.xml and robots.txt
+type SitemapHandler struct {
+	postService domain.PostService
+	baseURL     string
+	pages       []sitemap.Page
+	robots      sitemap.RobotsConfig
+}
+
+// NewSitemapHandler creates a new SitemapHandler listing the given pages
+// alongside every post and tag. The robots.txt references the sitemap.
+func NewSitemapHandler(postService domain.PostService, baseURL string, pages []sitemap.Page, robots sitemap.RobotsConfig) *SitemapHandler {
+	baseURL = strings.TrimRight(baseURL, "/")
+	robots.SitemapURL = baseURL + "/sitemap.xml"
+	return &SitemapHandler{
+		postService: postService,
+		baseURL:     baseURL,
+		pages:       pages,
+		robots:      robots,
+	}
+}
+
+// Sitemap serves /sitemap.xml, switching to a sitemap index when there are
+// more URLs than a single sitemap may hold
+func (h *SitemapHandler) Sitemap(c *gin.Context) {
+	urls, ok := h.urls(c)
+	if !ok {
+		return
+	}
+
+	var body []byte
+	var err error
+	if sitemap.Pages(len(urls)) > 1 {
+		body, err = sitemap.Index(urls, h.pageURL)
+	} else {
+		body, err = sitemap.URLSet(urls)
+	}
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to render sitemap")
+		return
+	}
+	c.Data(http.StatusOK, sitemapContentType, body)
+}
+
+// SitemapPage serves a single page of a split sitemap, /sitemaps/:page
+// where page is e.g. "2.xml"
+func (h *SitemapHandler) SitemapPage(c *gin.Context) {
+	page, err := strconv.Atoi(strings.TrimSuffix(c.Param("page"), ".xml"))
+	if err != nil {
+		c.String(http.StatusNotFound, "Sitemap not found")
+		return
+	}
+
+	urls, ok := h.urls(c)
+	if !ok {
+		return
+	}
+
+	chunk, ok := sitemap.Chunk(urls, page)
+	if !ok {
+		c.String(http.StatusNotFound, "Sitemap not found")
+		return
+	}
+
+	body, err := sitemap.URLSet(chunk)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to render sitemap")
+		return
+	}
+	c.Data(http.StatusOK, sitemapContentType, body)
+}
+
+// Robots serves /robots.txt
+func (h *SitemapHandler) Robots(c *gin.Context) {
+	c.String(http.StatusOK, sitemap.Robots(h.robots))
+}
+
+// urls loads the posts and builds the sitemap entries, writing an error
+// response and returning false on failure
+func (h *SitemapHandler) urls(c *gin.Context) ([]sitemap.URL, bool) {
+	posts, err := h.postService.GetAllPosts(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load posts")
+		return nil, false
+	}
+	return sitemap.Build(h.baseURL, h.pages, posts), true
+}
+
+func (h *SitemapHandler) pageURL(page int) string {
+	return h.baseURL + "/sitemaps/" + strconv.Itoa(page) + ".xml"
+}
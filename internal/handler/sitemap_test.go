@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/blog/internal/sitemap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemapHandler(t *testing.T) {
+	_, svc := setupTestEnvironment(t)
+
+	h := NewSitemapHandler(svc, "https://example.com/", []sitemap.Page{
+		{Path: "/posts", LatestPost: true},
+	}, sitemap.RobotsConfig{Disallow: []string{"/preview"}})
+
+	router := gin.New()
+	router.GET("/sitemap.xml", h.Sitemap)
+	router.GET("/sitemaps/:page", h.SitemapPage)
+	router.GET("/robots.txt", h.Robots)
+
+	t.Run("Sitemap", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/sitemap.xml", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+		assert.Contains(t, w.Body.String(), "<urlset")
+		assert.Contains(t, w.Body.String(), "<loc>https://example.com/posts</loc>")
+		assert.Contains(t, w.Body.String(), "<loc>https://example.com/posts/test-post</loc>")
+		assert.Contains(t, w.Body.String(), "<loc>https://example.com/tags/software-development</loc>")
+	})
+
+	t.Run("Sitemap page", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/sitemaps/1.xml", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<loc>https://example.com/posts/test-post</loc>")
+	})
+
+	t.Run("Unknown sitemap page returns 404", func(t *testing.T) {
+		for _, page := range []string{"2.xml", "nope.xml"} {
+			req, _ := http.NewRequest(http.MethodGet, "/sitemaps/"+page, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("Robots", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/robots.txt", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+		assert.Contains(t, w.Body.String(), "Disallow: /preview")
+		assert.Contains(t, w.Body.String(), "Sitemap: https://example.com/sitemap.xml")
+	})
+}
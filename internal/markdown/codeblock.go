@@ -0,0 +1,330 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// kindCodeBlock is the node kind of codeBlock
+var kindCodeBlock = ast.NewNodeKind("CodeBlock")
+
+// codeBlock replaces a fenced code block once its info string has been
+// parsed and any include resolved
+type codeBlock struct {
+	ast.BaseBlock
+	lang    string
+	code    string
+	options blockOptions
+}
+
+// Kind implements ast.Node
+func (n *codeBlock) Kind() ast.NodeKind {
+	return kindCodeBlock
+}
+
+// IsRaw implements ast.Node
+func (n *codeBlock) IsRaw() bool {
+	return true
+}
+
+// Dump implements ast.Node
+func (n *codeBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Lang": n.lang, "Title": n.options.title}, nil)
+}
+
+// blockOptions are the attributes of a fenced code block, written after the
+// language in the info string:
+//
+//	```go {linenos=true linenostart=10 hl_lines=[3,"7-9"] title="main.go"}
+//	```go {include="server/main.go" region="routes"}
+type blockOptions struct {
+	title       string
+	lineNumbers bool
+	lineStart   int
+	highlight   [][2]int // Inclusive line ranges
+	include     string   // File in the snippets directory to show instead of the block's own code
+	region      string   // Named region of the included file
+}
+
+// parseInfo splits a fence info string into the language and block options.
+// Malformed options are ignored so the block still renders.
+func parseInfo(info []byte) (string, blockOptions) {
+	info = bytes.TrimSpace(info)
+	end := bytes.IndexAny(info, " {")
+	if end < 0 {
+		return string(info), blockOptions{}
+	}
+	lang := string(info[:end])
+
+	var opts blockOptions
+	attrs, ok := parser.ParseAttributes(text.NewReader(info[end:]))
+	if !ok {
+		return lang, opts
+	}
+	for _, attr := range attrs {
+		switch string(attr.Name) {
+		case "title":
+			opts.title = attrString(attr.Value)
+		case "linenos":
+			// Hugo also accepts linenos=table and linenos=inline
+			switch v := attr.Value.(type) {
+			case bool:
+				opts.lineNumbers = v
+			case []byte:
+				opts.lineNumbers = len(v) > 0 && string(v) != "false"
+			}
+		case "linenostart":
+			if n, ok := attrInt(attr.Value); ok && n > 0 {
+				opts.lineStart = n
+			}
+		case "hl_lines":
+			opts.highlight = parseLineRanges(attr.Value)
+		case "include":
+			opts.include = attrString(attr.Value)
+		case "region":
+			opts.region = attrString(attr.Value)
+		}
+	}
+	return lang, opts
+}
+
+// parseLineRanges parses hl_lines values such as [3, "7-9"], 3 or "3 7-9"
+func parseLineRanges(value any) [][2]int {
+	var items []any
+	switch v := value.(type) {
+	case []any:
+		items = v
+	case []byte:
+		for _, field := range strings.Fields(string(v)) {
+			items = append(items, []byte(field))
+		}
+	default:
+		items = []any{v}
+	}
+
+	var ranges [][2]int
+	for _, item := range items {
+		if n, ok := attrInt(item); ok {
+			ranges = append(ranges, [2]int{n, n})
+			continue
+		}
+		from, to, found := strings.Cut(attrString(item), "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			continue
+		}
+		end := start
+		if found {
+			if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || end < start {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+func attrString(value any) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func attrInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), v == float64(int(v))
+	case []byte:
+		n, err := strconv.Atoi(string(v))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Parser context keys for RenderWithSnippets
+var (
+	snippetsKey   = parser.NewContextKey()
+	includeErrKey = parser.NewContextKey()
+)
+
+// codeBlocks replaces fenced code blocks with codeBlock nodes, reading the
+// code of include blocks from the snippets in the parser context
+type codeBlocks struct {
+	includes bool
+}
+
+func (t codeBlocks) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var fenced []*ast.FencedCodeBlock
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && entering {
+			fenced = append(fenced, block)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, n := range fenced {
+		block := &codeBlock{}
+		if n.Info != nil {
+			block.lang, block.options = parseInfo(n.Info.Segment.Value(source))
+		}
+
+		var code bytes.Buffer
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			code.Write(segment.Value(source))
+		}
+		block.code = code.String()
+
+		if t.includes && block.options.include != "" {
+			block.code = t.include(pc, block.options)
+		}
+
+		n.Parent().ReplaceChild(n.Parent(), n, block)
+	}
+}
+
+// include returns the code for an include block. Without snippets, as in the
+// editor preview, it returns a placeholder; failures are recorded in the
+// context for RenderWithSnippets to return.
+func (t codeBlocks) include(pc parser.Context, opts blockOptions) string {
+	snippets, _ := pc.Get(snippetsKey).(fs.FS)
+	if snippets == nil {
+		if opts.region != "" {
+			return fmt.Sprintf("include %q, region %q\n", opts.include, opts.region)
+		}
+		return fmt.Sprintf("include %q\n", opts.include)
+	}
+
+	code, err := readSnippet(snippets, opts.include, opts.region)
+	if err != nil {
+		if pc.Get(includeErrKey) == nil {
+			pc.Set(includeErrKey, err)
+		}
+		return ""
+	}
+	return code
+}
+
+// readSnippet reads a file from snippets, or just the named region of it
+// when region is set. Region marker lines are never included.
+func readSnippet(snippets fs.FS, name, region string) (string, error) {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	data, err := fs.ReadFile(snippets, name)
+	if err != nil {
+		return "", fmt.Errorf("include %q: %w", name, err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var out []string
+	depth := 0
+	found := false
+	for _, line := range lines {
+		start, marker, ok := regionMarker(line)
+		if ok {
+			switch {
+			case start && depth == 0 && (region == "" || marker == region):
+				found = true
+				depth = 1
+			case start && depth > 0:
+				depth++
+			case !start && depth > 0:
+				depth--
+			}
+			continue
+		}
+		if region == "" || depth > 0 {
+			out = append(out, line)
+		}
+		if region != "" && found && depth == 0 {
+			break
+		}
+	}
+
+	if region != "" {
+		if !found {
+			return "", fmt.Errorf("include %q: region %q not found", name, region)
+		}
+		if depth > 0 {
+			return "", fmt.Errorf("include %q: region %q has no endregion marker", name, region)
+		}
+	}
+
+	code := dedent(out)
+	if code != "" && !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	return code, nil
+}
+
+// regionMarker reports whether line is a "region: name" or "endregion"
+// comment, in any of the common line comment styles
+func regionMarker(line string) (start bool, name string, ok bool) {
+	s := strings.TrimSpace(line)
+	comment := false
+	for _, prefix := range []string{"//", "#", "--", "/*", "<!--", ";"} {
+		if rest, found := strings.CutPrefix(s, prefix); found {
+			s, comment = rest, true
+			break
+		}
+	}
+	if !comment {
+		return false, "", false
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "*/"), "-->")
+	s = strings.TrimSpace(s)
+
+	if rest, found := strings.CutPrefix(s, "region:"); found {
+		return true, strings.TrimSpace(rest), true
+	}
+	if s == "endregion" || strings.HasPrefix(s, "endregion:") {
+		return false, strings.TrimSpace(strings.TrimPrefix(s, "endregion:")), true
+	}
+	return false, "", false
+}
+
+// dedent joins lines after removing the indentation they all share, so
+// regions from inside functions start at the left margin
+func dedent(lines []string) string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(strings.TrimPrefix(line, prefix))
+	}
+	return b.String()
+}
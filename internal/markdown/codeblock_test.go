@@ -0,0 +1,170 @@
+package markdown
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		info string
+		lang string
+		want blockOptions
+	}{
+		{info: "go", lang: "go"},
+		{info: "", lang: ""},
+		{
+			info: `go {linenos=true hl_lines=[3,"7-9"] title="main.go"}`,
+			lang: "go",
+			want: blockOptions{title: "main.go", lineNumbers: true, highlight: [][2]int{{3, 3}, {7, 9}}},
+		},
+		{
+			info: `sql{linenos=table linenostart=10 hl_lines="1 4-5"}`,
+			lang: "sql",
+			want: blockOptions{lineNumbers: true, lineStart: 10, highlight: [][2]int{{1, 1}, {4, 5}}},
+		},
+		{
+			info: `go {include="server/main.go" region="routes"}`,
+			lang: "go",
+			want: blockOptions{include: "server/main.go", region: "routes"},
+		},
+		{
+			// Malformed attributes are ignored
+			info: `go {title="unterminated}`,
+			lang: "go",
+		},
+		{
+			// Invalid ranges are skipped
+			info: `go {hl_lines=["9-2", "x", 4]}`,
+			lang: "go",
+			want: blockOptions{highlight: [][2]int{{4, 4}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.info, func(t *testing.T) {
+			lang, opts := parseInfo([]byte(tt.info))
+			if lang != tt.lang {
+				t.Errorf("parseInfo(%q) lang = %q, want %q", tt.info, lang, tt.lang)
+			}
+			if !reflect.DeepEqual(opts, tt.want) {
+				t.Errorf("parseInfo(%q) options = %+v, want %+v", tt.info, opts, tt.want)
+			}
+		})
+	}
+}
+
+var testSnippets = fstest.MapFS{
+	"server/main.go": {Data: []byte(`package main
+
+// region: routes
+func routes(r *Router) {
+	// region: health
+	r.GET("/health", health)
+	// endregion: health
+	r.GET("/posts", posts)
+}
+// endregion: routes
+
+func main() {
+	# region: nothing
+}
+`)},
+	"config.yaml": {Data: []byte("server:\n  # region: port\n  port: 8080\n  # endregion\n  host: localhost\n")},
+	"broken.go":   {Data: []byte("// region: open\nfunc f() {}\n")},
+}
+
+func TestReadSnippet(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		region string
+		want   string
+	}{
+		{
+			name: "Whole file without markers",
+			file: "server/main.go",
+			want: "package main\n\nfunc routes(r *Router) {\n\tr.GET(\"/health\", health)\n\tr.GET(\"/posts\", posts)\n}\n\nfunc main() {\n}\n",
+		},
+		{
+			name:   "Region with a nested region",
+			file:   "./server/main.go",
+			region: "routes",
+			want:   "func routes(r *Router) {\n\tr.GET(\"/health\", health)\n\tr.GET(\"/posts\", posts)\n}\n",
+		},
+		{
+			name:   "Nested region is dedented",
+			file:   "server/main.go",
+			region: "health",
+			want:   "r.GET(\"/health\", health)\n",
+		},
+		{
+			name:   "Hash comment markers",
+			file:   "config.yaml",
+			region: "port",
+			want:   "port: 8080\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSnippet(testSnippets, tt.file, tt.region)
+			if err != nil {
+				t.Fatalf("readSnippet() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("readSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readSnippet(testSnippets, "missing.go", ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("readSnippet() missing file error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := readSnippet(testSnippets, "server/main.go", "nope"); err == nil || !strings.Contains(err.Error(), `region "nope" not found`) {
+		t.Errorf("readSnippet() missing region error = %v", err)
+	}
+	if _, err := readSnippet(testSnippets, "broken.go", "open"); err == nil || !strings.Contains(err.Error(), "no endregion") {
+		t.Errorf("readSnippet() unclosed region error = %v", err)
+	}
+	if _, err := readSnippet(testSnippets, "../secret.go", ""); err == nil {
+		t.Error("readSnippet() expected an error for a path outside the snippets")
+	}
+}
+
+func TestRenderWithSnippets(t *testing.T) {
+	r := New(Includes)
+	src := "```go {include=\"server/main.go\" region=\"health\"}\n```\n"
+
+	got, err := r.RenderWithSnippets(src, testSnippets)
+	if err != nil {
+		t.Fatalf("RenderWithSnippets() error = %v", err)
+	}
+	if want := "<pre><code class=\"language-go\">r.GET(&quot;/health&quot;, health)\n</code></pre>\n"; got != want {
+		t.Errorf("RenderWithSnippets() = %q, want %q", got, want)
+	}
+
+	// The preview has no snippets and shows what would be included
+	got, err = r.Render(src)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.Contains(got, "include &quot;server/main.go&quot;, region &quot;health&quot;") {
+		t.Errorf("Render() expected an include placeholder, got %q", got)
+	}
+
+	_, err = r.RenderWithSnippets("```go {include=\"missing.go\"}\n```\n", testSnippets)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("RenderWithSnippets() missing include error = %v, want fs.ErrNotExist", err)
+	}
+
+	// Includes are left alone without the extension
+	got, _ = New(0).RenderWithSnippets(src, testSnippets)
+	if strings.Contains(got, "health") {
+		t.Errorf("Expected include to be ignored without Includes, got %q", got)
+	}
+}
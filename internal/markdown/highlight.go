@@ -0,0 +1,189 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Default chroma styles for the highlighting stylesheet
+const (
+	DefaultLightStyle = "github"
+	DefaultDarkStyle  = "github-dark"
+)
+
+// languageAliases maps fence languages chroma doesn't know to a lexer
+var languageAliases = map[string]string{
+	"clickhouse": "sql",
+	"console":    "bash",
+	"shell":      "bash",
+}
+
+// codeBlockRenderer renders codeBlock nodes. With highlighting they become
+// class-based chroma HTML inside a labelled wrapper:
+//
+//	<div class="code-block">
+//	<div class="code-header"><span class="code-title">main.go</span><span class="code-lang">Go</span></div>
+//	<pre class="chroma"><code class="language-go" data-lang="go">…</code></pre>
+//	</div>
+//
+// Colors come from the stylesheet generated by HighlightCSS, so no
+// JavaScript is needed in the browser. Without highlighting the output is
+// the plain <pre><code> of an ordinary fenced block.
+type codeBlockRenderer struct {
+	highlight bool
+}
+
+// RegisterFuncs implements renderer.NodeRenderer
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindCodeBlock, r.renderCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*codeBlock)
+
+	if !r.highlight {
+		_, _ = w.WriteString("<pre><code")
+		if n.lang != "" {
+			_, _ = fmt.Fprintf(w, " class=\"language-%s\"", html.EscapeString(n.lang))
+		}
+		_, _ = w.WriteString(">")
+		_, _ = w.Write(util.EscapeHTML([]byte(n.code)))
+		_, _ = w.WriteString("</code></pre>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	lexer, label := lookupLexer(n.lang)
+
+	_, _ = w.WriteString("<div class=\"code-block\">\n")
+	if label != "" || n.options.title != "" {
+		_, _ = w.WriteString("<div class=\"code-header\">")
+		if n.options.title != "" {
+			_, _ = fmt.Fprintf(w, "<span class=\"code-title\">%s</span>", html.EscapeString(n.options.title))
+		}
+		if label != "" {
+			_, _ = fmt.Fprintf(w, "<span class=\"code-lang\">%s</span>", html.EscapeString(label))
+		}
+		_, _ = w.WriteString("</div>\n")
+	}
+
+	wrapper := preWrapper{lang: n.lang}
+	iterator, err := lexer.Tokenise(nil, n.code)
+	if err == nil {
+		err = chromahtml.New(formatterOptions(n.options, wrapper)...).Format(w, styles.Fallback, iterator)
+	}
+	if err != nil {
+		// Show the code unhighlighted rather than failing the whole post
+		_, _ = w.WriteString(wrapper.Start(true, "") + html.EscapeString(n.code) + wrapper.End(true))
+	}
+
+	_, _ = w.WriteString("\n</div>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+// preWrapper writes the <pre><code> around chroma's lines, keeping the
+// language class of an unhighlighted block
+type preWrapper struct {
+	lang string
+}
+
+// Start implements chromahtml.PreWrapper
+func (p preWrapper) Start(code bool, styleAttr string) string {
+	if !code || p.lang == "" {
+		return `<pre class="chroma"><code>`
+	}
+	lang := html.EscapeString(p.lang)
+	return `<pre class="chroma"><code class="language-` + lang + `" data-lang="` + lang + `">`
+}
+
+// End implements chromahtml.PreWrapper
+func (p preWrapper) End(code bool) string {
+	return "</code></pre>"
+}
+
+// formatterOptions returns the chroma options for a block's attributes
+func formatterOptions(opts blockOptions, wrapper preWrapper) []chromahtml.Option {
+	options := []chromahtml.Option{chromahtml.WithClasses(true), chromahtml.WithPreWrapper(wrapper)}
+	if opts.lineNumbers {
+		options = append(options, chromahtml.WithLineNumbers(true))
+	}
+	if opts.lineStart > 0 {
+		options = append(options, chromahtml.BaseLineNumber(opts.lineStart))
+	}
+	if len(opts.highlight) > 0 {
+		options = append(options, chromahtml.HighlightLines(opts.highlight))
+	}
+	return options
+}
+
+// lookupLexer returns the lexer for a fence language and the label shown
+// above the block. Unknown languages are shown as plain text under their own
+// name; blocks without a language get no label.
+func lookupLexer(lang string) (chroma.Lexer, string) {
+	if lang == "" {
+		return lexers.Fallback, ""
+	}
+
+	name := lang
+	if alias, ok := languageAliases[lang]; ok {
+		name = alias
+	}
+	lexer := lexers.Get(name)
+	if lexer == nil {
+		return lexers.Fallback, lang
+	}
+	return chroma.Coalesce(lexer), lexer.Config().Name
+}
+
+// HighlightCSS generates the stylesheet for highlighted code blocks: the
+// light style by default and the dark style when the browser prefers a dark
+// color scheme.
+func HighlightCSS(light, dark string) ([]byte, error) {
+	lightStyle, ok := styles.Registry[light]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %q", light)
+	}
+	darkStyle, ok := styles.Registry[dark]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %q", dark)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "/* Code highlighting: %s */\n", light)
+	if err := writeStyleCSS(&buf, lightStyle); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "\n/* Code highlighting: %s */\n@media (prefers-color-scheme: dark) {\n", dark)
+	if err := writeStyleCSS(&buf, darkStyle); err != nil {
+		return nil, err
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// writeStyleCSS writes the class rules for a chroma style. The global .bg
+// rule is dropped so it can't clash with the site's own classes.
+func writeStyleCSS(buf *bytes.Buffer, style *chroma.Style) error {
+	var css bytes.Buffer
+	if err := chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(&css, style); err != nil {
+		return err
+	}
+	for _, line := range strings.SplitAfter(css.String(), "\n") {
+		if !strings.HasPrefix(line, "/* Background */") {
+			buf.WriteString(line)
+		}
+	}
+	return nil
+}
@@ -2,29 +2,242 @@ package markdown
 
 import (
 	"bytes"
+	"io/fs"
+	"strings"
+
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
-// Render converts Markdown to HTML
-func Render(markdown string) (string, error) {
+// Extension is a set of optional markdown features, combined with |
+type Extension uint
+
+const (
+	// Tables enables GFM pipe tables
+	Tables Extension = 1 << iota
+	// Strikethrough enables ~~deleted~~ text
+	Strikethrough
+	// TaskLists enables GFM "- [ ]" checkboxes
+	TaskLists
+	// Autolinks turns bare URLs into links
+	Autolinks
+	// Footnotes enables [^1] footnotes
+	Footnotes
+	// DefinitionLists enables "Term\n: Definition" lists
+	DefinitionLists
+	// HeadingIDs gives every heading an id generated from its text
+	HeadingIDs
+	// ExternalLinks opens absolute http(s) links in a new tab
+	ExternalLinks
+	// HardWraps renders newlines inside paragraphs as <br>
+	HardWraps
+	// RawHTML passes HTML in the markdown through instead of omitting it
+	RawHTML
+	// Highlight colors fenced code blocks and labels them with their
+	// language, honouring the title, linenos, linenostart and hl_lines block
+	// attributes; see HighlightCSS
+	Highlight
+	// Includes fills fenced code blocks with an include="file" attribute
+	// from the snippets passed to RenderWithSnippets
+	Includes
+)
+
+// GFM is the set of GitHub Flavored Markdown extensions
+const GFM = Tables | Strikethrough | TaskLists | Autolinks
+
+// DefaultExtensions is the feature set posts are written against
+const DefaultExtensions = GFM | Footnotes | DefinitionLists | HeadingIDs | ExternalLinks | RawHTML | Highlight | Includes
+
+// Renderer converts markdown to HTML with a fixed set of extensions and
+// sanitizes the result with a Policy. A Renderer is safe for concurrent use,
+// so one instance should be shared by everything that renders posts to keep
+// the output identical.
+type Renderer struct {
+	extensions Extension
+	md         goldmark.Markdown
+	policy     *Policy
+}
+
+// New creates a Renderer with the given extensions. Its output is sanitized
+// with TrustedPolicy; see WithPolicy.
+func New(extensions Extension) *Renderer {
+	var exts []goldmark.Extender
+	parserOpts := []parser.Option{parser.WithASTTransformers(util.Prioritized(outline{}, 1000))}
+	var htmlOpts []renderer.Option
+
+	if extensions&Tables != 0 {
+		exts = append(exts, extension.Table)
+	}
+	if extensions&Strikethrough != 0 {
+		exts = append(exts, extension.Strikethrough)
+	}
+	if extensions&TaskLists != 0 {
+		exts = append(exts, extension.TaskList)
+	}
+	if extensions&Autolinks != 0 {
+		exts = append(exts, extension.Linkify)
+	}
+	if extensions&Footnotes != 0 {
+		exts = append(exts, extension.Footnote)
+	}
+	if extensions&DefinitionLists != 0 {
+		exts = append(exts, extension.DefinitionList)
+	}
+	if extensions&HeadingIDs != 0 {
+		parserOpts = append(parserOpts, parser.WithAutoHeadingID())
+	}
+	if extensions&ExternalLinks != 0 {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(util.Prioritized(externalLinks{}, 100)))
+	}
+	if extensions&HardWraps != 0 {
+		htmlOpts = append(htmlOpts, html.WithHardWraps())
+	}
+	if extensions&RawHTML != 0 {
+		htmlOpts = append(htmlOpts, html.WithUnsafe())
+	}
+	if extensions&(Highlight|Includes) != 0 {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(util.Prioritized(codeBlocks{includes: extensions&Includes != 0}, 200)))
+		htmlOpts = append(htmlOpts, renderer.WithNodeRenderers(util.Prioritized(&codeBlockRenderer{highlight: extensions&Highlight != 0}, 100)))
+	}
+
 	md := goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-		),
-		goldmark.WithRendererOptions(
-			html.WithHardWraps(),
-			html.WithXHTML(),
-		),
+		goldmark.WithExtensions(exts...),
+		goldmark.WithParserOptions(parserOpts...),
+		goldmark.WithRendererOptions(htmlOpts...),
 	)
+	return &Renderer{extensions: extensions, md: md, policy: TrustedPolicy()}
+}
+
+// WithPolicy returns a copy of the renderer that sanitizes its output with
+// policy instead, sharing the parser. A nil policy disables sanitizing,
+// which is only safe for markdown from a trusted source.
+func (r *Renderer) WithPolicy(policy *Policy) *Renderer {
+	copy := *r
+	copy.policy = policy
+	return &copy
+}
 
+// Extensions returns the extensions the renderer was created with
+func (r *Renderer) Extensions() Extension {
+	return r.extensions
+}
+
+// Render converts markdown to HTML. Include blocks render as placeholders.
+func (r *Renderer) Render(markdown string) (string, error) {
+	return r.RenderWithSnippets(markdown, nil)
+}
+
+// RenderWithSnippets converts markdown to HTML, reading the code of include
+// blocks from snippets. It fails if an included file or region is missing.
+func (r *Renderer) RenderWithSnippets(markdown string, snippets fs.FS) (string, error) {
+	root, source, ctx := r.parse(markdown, snippets)
+	return r.render(root, source, ctx)
+}
+
+// RenderDocument converts markdown to HTML like RenderWithSnippets and also
+// returns the document's headings, word count and summary
+func (r *Renderer) RenderDocument(markdown string, snippets fs.FS) (*Document, error) {
+	root, source, ctx := r.parse(markdown, snippets)
+	html, err := r.render(root, source, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := ctx.Get(outlineKey).(*Document)
+	doc.HTML = html
+	if doc.Summary, err = r.summary(root, source); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Outline returns the headings, word count and summary of markdown without
+// rendering the rest of it. Include blocks are counted as their placeholders.
+func (r *Renderer) Outline(markdown string) (*Document, error) {
+	root, source, ctx := r.parse(markdown, nil)
+	doc := ctx.Get(outlineKey).(*Document)
+
+	var err error
+	if doc.Summary, err = r.summary(root, source); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// parse parses markdown with snippets in the parser context
+func (r *Renderer) parse(markdown string, snippets fs.FS) (ast.Node, []byte, parser.Context) {
+	ctx := parser.NewContext()
+	if snippets != nil {
+		ctx.Set(snippetsKey, snippets)
+	}
+	source := []byte(markdown)
+	return r.md.Parser().Parse(text.NewReader(source), parser.WithContext(ctx)), source, ctx
+}
+
+// render renders a parsed document, failing if an include could not be read
+func (r *Renderer) render(root ast.Node, source []byte, ctx parser.Context) (string, error) {
+	if err, ok := ctx.Get(includeErrKey).(error); ok {
+		return "", err
+	}
 	var buf bytes.Buffer
-	if err := md.Convert([]byte(markdown), &buf); err != nil {
+	if err := r.md.Renderer().Render(&buf, source, root); err != nil {
 		return "", err
 	}
+	return r.sanitize(buf.String()), nil
+}
+
+// sanitize applies the renderer's policy to rendered HTML
+func (r *Renderer) sanitize(html string) string {
+	if r.policy == nil {
+		return html
+	}
+	return r.policy.Sanitize(html)
+}
+
+// defaultRenderer backs Render
+var defaultRenderer = New(DefaultExtensions)
+
+// Render converts markdown to HTML with DefaultExtensions
+func Render(markdown string) (string, error) {
+	return defaultRenderer.Render(markdown)
+}
+
+// externalLinks marks absolute http(s) links to open in a new tab
+type externalLinks struct{}
+
+func (externalLinks) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var dest string
+		switch link := n.(type) {
+		case *ast.Link:
+			dest = string(link.Destination)
+		case *ast.AutoLink:
+			if link.AutoLinkType != ast.AutoLinkURL {
+				return ast.WalkContinue, nil
+			}
+			dest = string(link.URL(source))
+			// Linkify leaves the scheme off bare www. links
+			if !strings.Contains(dest, "://") {
+				dest = "http://" + dest
+			}
+		default:
+			return ast.WalkContinue, nil
+		}
 
-	return buf.String(), nil
-}
\ No newline at end of file
+		if strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") {
+			n.SetAttributeString("target", []byte("_blank"))
+			n.SetAttributeString("rel", []byte("noopener"))
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -1,9 +1,15 @@
 package markdown
 
 import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
 func TestRender(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -28,7 +34,9 @@ func TestRender(t *testing.T) {
 		{
 			name:     "Code blocks",
 			input:    "```go\nfunc main() {}\n```",
-			expected: "<pre><code class=\"language-go\">func main() {}\n</code></pre>\n",
+			expected: "<div class=\"code-block\">\n<div class=\"code-header\"><span class=\"code-lang\">Go</span></div>\n" +
+				"<pre class=\"chroma\"><code class=\"language-go\" data-lang=\"go\"><span class=\"line\"><span class=\"cl\"><span class=\"kd\">func</span><span class=\"w\"> </span>" +
+				"<span class=\"nf\">main</span><span class=\"p\">()</span><span class=\"w\"> </span><span class=\"p\">{}</span><span class=\"w\">\n</span></span></span></code></pre>\n</div>\n",
 		},
 		{
 			name:     "Lists",
@@ -38,12 +46,12 @@ func TestRender(t *testing.T) {
 		{
 			name:     "Links",
 			input:    "[Google](https://google.com)",
-			expected: "<p><a href=\"https://google.com\">Google</a></p>\n",
+			expected: "<p><a href=\"https://google.com\" target=\"_blank\" rel=\"noopener\">Google</a></p>\n",
 		},
 		{
 			name:     "Images",
 			input:    "![Alt text](image.jpg)",
-			expected: "<p><img src=\"image.jpg\" alt=\"Alt text\" /></p>\n",
+			expected: "<p><img src=\"image.jpg\" alt=\"Alt text\"></p>\n",
 		},
 		{
 			name:     "Blockquotes",
@@ -80,4 +88,117 @@ func TestRenderEmpty(t *testing.T) {
 	if got != "" {
 		t.Errorf("Render() = %v, want empty string", got)
 	}
-}
\ No newline at end of file
+}
+func TestRendererExtensions(t *testing.T) {
+	input := "Line one\nline two ~~old~~ https://example.com"
+
+	plain, err := New(0).Render(input)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "<p>Line one\nline two ~~old~~ https://example.com</p>\n"; plain != want {
+		t.Errorf("Render() without extensions = %q, want %q", plain, want)
+	}
+
+	got, err := New(Strikethrough | Autolinks | HardWraps).Render(input)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "<p>Line one<br>\nline two <del>old</del> <a href=\"https://example.com\">https://example.com</a></p>\n"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	// Raw HTML is dropped unless enabled
+	got, _ = New(0).Render("<div>raw</div>")
+	if strings.Contains(got, "<div>") {
+		t.Errorf("Expected raw HTML to be omitted, got %q", got)
+	}
+	got, _ = New(RawHTML).Render("<div>raw</div>\n")
+	if got != "<div>raw</div>\n" {
+		t.Errorf("Expected raw HTML to pass through, got %q", got)
+	}
+}
+
+func TestHighlightFallback(t *testing.T) {
+	r := New(Highlight)
+
+	got, err := r.Render("```nosuchlang\nif a < b {}\n```\n")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := "<div class=\"code-block\">\n<div class=\"code-header\"><span class=\"code-lang\">nosuchlang</span></div>\n" +
+		"<pre class=\"chroma\"><code class=\"language-nosuchlang\" data-lang=\"nosuchlang\"><span class=\"line\"><span class=\"cl\">if a &lt; b {}\n</span></span></code></pre>\n</div>\n"
+	if got != want {
+		t.Errorf("Render() unknown language = %q, want %q", got, want)
+	}
+
+	// Without the extension code blocks stay plain
+	got, _ = New(0).Render("```go\nx := 1\n```\n")
+	if got != "<pre><code class=\"language-go\">x := 1\n</code></pre>\n" {
+		t.Errorf("Render() without Highlight = %q", got)
+	}
+}
+
+func TestHighlightCSS(t *testing.T) {
+	css, err := HighlightCSS(DefaultLightStyle, DefaultDarkStyle)
+	if err != nil {
+		t.Fatalf("HighlightCSS() error = %v", err)
+	}
+	light, dark, ok := strings.Cut(string(css), "@media (prefers-color-scheme: dark) {")
+	if !ok {
+		t.Fatal("Expected a dark color scheme block")
+	}
+	for _, part := range []string{light, dark} {
+		if !strings.Contains(part, ".chroma .k {") || !strings.Contains(part, ".chroma {") {
+			t.Errorf("Expected keyword and wrapper rules in\n%s", part)
+		}
+		if strings.Contains(part, ".bg {") {
+			t.Error("Expected the global .bg rule to be dropped")
+		}
+	}
+
+	if _, err := HighlightCSS("no-such-style", DefaultDarkStyle); err == nil {
+		t.Error("Expected an error for an unknown style")
+	}
+}
+
+// TestRenderGolden renders each testdata/*.md file with DefaultExtensions and
+// compares it to the .html file beside it. Run with -update after an
+// intentional change to the output.
+func TestRenderGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("No golden inputs found in testdata")
+	}
+
+	r := New(DefaultExtensions)
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Render(string(src))
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			golden := strings.TrimSuffix(input, ".md") + ".html"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Missing golden file, run go test -update: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("Render(%s) =\n%s\nwant\n%s", input, got, want)
+			}
+		})
+	}
+}
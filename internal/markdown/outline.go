@@ -0,0 +1,92 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading is a heading with an id, as generated by HeadingIDs
+type Heading struct {
+	Level int    // 1 for <h1> through 6 for <h6>
+	ID    string // Anchor of the heading
+	Text  string // Plain text of the heading
+}
+
+// Document is rendered markdown with the outline collected while parsing it
+type Document struct {
+	HTML     string
+	Headings []Heading // Headings with ids, in document order
+	Words    int       // Words of text, including code
+	Summary  string    // HTML excerpt; see SummaryMarker
+}
+
+// outlineKey is the parser context key of the outline collected by outline
+var outlineKey = parser.NewContextKey()
+
+// outline records the headings and word count of a document in the parser
+// context. It runs after the other transformers so include blocks are
+// counted with their final code.
+type outline struct{}
+
+func (outline) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	result := &Document{}
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if heading, ok := n.(*ast.Heading); ok && entering {
+			if id, ok := heading.AttributeString("id"); ok {
+				if id, ok := id.([]byte); ok {
+					result.Headings = append(result.Headings, Heading{
+						Level: heading.Level,
+						ID:    string(id),
+						Text:  strings.TrimSpace(string(heading.Text(source))),
+					})
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	result.Words = len(strings.Fields(plainText(doc, source)))
+	pc.Set(outlineKey, result)
+}
+
+// plainText returns the text of a node and its descendants, including code,
+// with markup removed. Blocks and lines are separated by spaces, so words
+// split across inline nodes such as "foo*bar*" stay whole.
+func plainText(node ast.Node, source []byte) string {
+	var plain strings.Builder
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if n.Type() == ast.TypeBlock {
+			plain.WriteByte(' ')
+		}
+
+		switch n := n.(type) {
+		case *ast.Text:
+			plain.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				plain.WriteByte(' ')
+			}
+		case *ast.String:
+			plain.Write(n.Value)
+		case *codeBlock:
+			plain.WriteString(n.code)
+			return ast.WalkSkipChildren, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				plain.Write(segment.Value(source))
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return plain.String()
+}
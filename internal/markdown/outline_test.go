@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+const outlineSource = "# Title\n\nSome *intro*duction text.\n\n## First `code` step\n\n" +
+	"```go {include=\"main.go\"}\n```\n\n### Detail\n\nMore words here.\n\n## Second *part*\n"
+
+func TestRenderDocument(t *testing.T) {
+	snippets := fstest.MapFS{"main.go": {Data: []byte("func main() {}\n")}}
+	doc, err := New(DefaultExtensions).RenderDocument(outlineSource, snippets)
+	if err != nil {
+		t.Fatalf("RenderDocument() error = %v", err)
+	}
+
+	want := []Heading{
+		{Level: 1, ID: "title", Text: "Title"},
+		{Level: 2, ID: "first-code-step", Text: "First code step"},
+		{Level: 3, ID: "detail", Text: "Detail"},
+		{Level: 2, ID: "second-part", Text: "Second part"},
+	}
+	if !reflect.DeepEqual(doc.Headings, want) {
+		t.Errorf("Headings = %+v, want %+v", doc.Headings, want)
+	}
+
+	// Title, Some introduction text, First code step, func main() {},
+	// Detail, More words here, Second part
+	if doc.Words != 16 {
+		t.Errorf("Words = %d, want 16", doc.Words)
+	}
+
+	html, _ := New(DefaultExtensions).RenderWithSnippets(outlineSource, snippets)
+	if doc.HTML != html {
+		t.Errorf("HTML differs from RenderWithSnippets:\n%s\n%s", doc.HTML, html)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	r := New(DefaultExtensions)
+	outline, _ := r.Outline(outlineSource)
+	if len(outline.Headings) != 4 || outline.HTML != "" {
+		t.Errorf("Outline() = %+v", outline)
+	}
+
+	// Without HeadingIDs there are no anchors to link to
+	outline, _ = New(GFM).Outline(outlineSource)
+	if len(outline.Headings) != 0 {
+		t.Errorf("Outline() without HeadingIDs = %+v", outline.Headings)
+	}
+	if outline.Words == 0 {
+		t.Error("Outline() without HeadingIDs counted no words")
+	}
+}
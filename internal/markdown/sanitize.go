@@ -0,0 +1,249 @@
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Policy is an allowlist of the HTML kept in rendered markdown. Elements not
+// in the policy are removed but keep their text, except for elements such as
+// <script> whose content is never shown as text, which are removed whole.
+// Attributes not in the policy are removed, as are URLs with other schemes.
+type Policy struct {
+	// Elements maps each allowed element to the attributes allowed on it
+	Elements map[string][]string
+	// Attributes are allowed on every allowed element
+	Attributes []string
+	// Values restricts attributes to values matching a pattern, such as the
+	// text-align style goldmark puts on table cells
+	Values map[string]*regexp.Regexp
+	// URLSchemes are the schemes allowed in href and src. Relative URLs and
+	// fragments are always allowed.
+	URLSchemes []string
+}
+
+// StrictPolicy allows the HTML that markdown itself renders to, and no
+// embedded media. It is meant for untrusted input such as the preview.
+func StrictPolicy() *Policy {
+	return &Policy{
+		Elements: map[string][]string{
+			"a":          {"href", "target", "rel"},
+			"abbr":       nil,
+			"b":          nil,
+			"blockquote": {"cite"},
+			"br":         nil,
+			"cite":       nil,
+			"code":       {"data-lang"},
+			"dd":         nil,
+			"del":        nil,
+			"div":        nil,
+			"dl":         nil,
+			"dt":         nil,
+			"em":         nil,
+			"h1":         nil,
+			"h2":         nil,
+			"h3":         nil,
+			"h4":         nil,
+			"h5":         nil,
+			"h6":         nil,
+			"hr":         nil,
+			"i":          nil,
+			"input":      {"type", "checked", "disabled"},
+			"ins":        nil,
+			"kbd":        nil,
+			"li":         nil,
+			"mark":       nil,
+			"ol":         {"start"},
+			"p":          nil,
+			"pre":        nil,
+			"q":          {"cite"},
+			"s":          nil,
+			"small":      nil,
+			"span":       nil,
+			"strong":     nil,
+			"sub":        nil,
+			"sup":        nil,
+			"table":      nil,
+			"tbody":      nil,
+			"td":         {"style"},
+			"th":         {"style"},
+			"thead":      nil,
+			"tr":         nil,
+			"ul":         nil,
+		},
+		Attributes: []string{"id", "class", "title", "role"},
+		Values: map[string]*regexp.Regexp{
+			"style":  regexp.MustCompile(`^text-align:\s*(left|center|right);?$`),
+			"target": regexp.MustCompile(`^_blank$`),
+			"type":   regexp.MustCompile(`^checkbox$`),
+		},
+		URLSchemes: []string{"http", "https", "mailto"},
+	}
+}
+
+// TrustedPolicy extends StrictPolicy with images, figures and collapsible
+// sections for content written by the site's authors
+func TrustedPolicy() *Policy {
+	p := StrictPolicy()
+	p.Elements["img"] = []string{"src", "alt", "width", "height", "loading"}
+	p.Elements["figure"] = nil
+	p.Elements["figcaption"] = nil
+	p.Elements["details"] = []string{"open"}
+	p.Elements["summary"] = nil
+	return p
+}
+
+// dropContent lists the elements removed together with their content
+var dropContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "noembed": true, "noframes": true, "template": true,
+	"textarea": true, "title": true, "xmp": true, "svg": true, "math": true,
+	"select": true, "plaintext": true,
+}
+
+// voidElements have no end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true,
+	"track": true, "wbr": true,
+}
+
+// urlAttributes hold URLs checked against Policy.URLSchemes
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+// escaper escapes text and attribute values the way goldmark does
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;")
+
+// Sanitize returns fragment with everything the policy doesn't allow
+// removed. Comments and doctypes are dropped, end tags without a matching
+// start tag are dropped and elements left open are closed, so the result
+// can't break out of the page it is embedded in.
+func (p *Policy) Sanitize(fragment string) string {
+	var b strings.Builder
+	var open []string
+	skip, skipDepth := "", 0
+
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// io.EOF, as a strings.Reader can't fail
+			break
+		}
+		// Token unescapes text in place, so copy the raw text first
+		raw := string(z.Raw())
+		token := z.Token()
+
+		// Inside an element dropped with its content, only track nesting
+		if skip != "" {
+			switch {
+			case tt == html.StartTagToken && token.Data == skip:
+				skipDepth++
+			case tt == html.EndTagToken && token.Data == skip:
+				if skipDepth--; skipDepth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			if strings.ContainsAny(raw, "<>") {
+				raw = escaper.Replace(token.Data)
+			}
+			b.WriteString(raw)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if dropContent[token.Data] {
+				if tt == html.StartTagToken && !voidElements[token.Data] {
+					skip, skipDepth = token.Data, 1
+				}
+				continue
+			}
+			allowed, ok := p.Elements[token.Data]
+			if !ok {
+				continue
+			}
+			b.WriteString("<" + token.Data)
+			for _, attr := range token.Attr {
+				if attr.Namespace != "" || !p.allowAttribute(allowed, attr) {
+					continue
+				}
+				b.WriteString(" " + attr.Key + "=\"" + escaper.Replace(attr.Val) + "\"")
+			}
+			b.WriteString(">")
+			if !voidElements[token.Data] {
+				if tt == html.SelfClosingTagToken {
+					b.WriteString("</" + token.Data + ">")
+				} else {
+					open = append(open, token.Data)
+				}
+			}
+
+		case html.EndTagToken:
+			// Close the innermost open element of the same name, and any
+			// elements left open inside it
+			i := len(open) - 1
+			for i >= 0 && open[i] != token.Data {
+				i--
+			}
+			for j := len(open) - 1; i >= 0 && j >= i; j-- {
+				b.WriteString("</" + open[j] + ">")
+			}
+			if i >= 0 {
+				open = open[:i]
+			}
+		}
+	}
+
+	for j := len(open) - 1; j >= 0; j-- {
+		b.WriteString("</" + open[j] + ">")
+	}
+	return b.String()
+}
+
+// allowAttribute reports whether attr may be kept on an element allowing
+// the attributes in allowed
+func (p *Policy) allowAttribute(allowed []string, attr html.Attribute) bool {
+	if !slices.Contains(allowed, attr.Key) && !slices.Contains(p.Attributes, attr.Key) {
+		return false
+	}
+	if pattern, ok := p.Values[attr.Key]; ok && !pattern.MatchString(attr.Val) {
+		return false
+	}
+	if urlAttributes[attr.Key] {
+		return p.allowURL(attr.Val)
+	}
+	return true
+}
+
+// allowURL reports whether u is relative or has an allowed scheme.
+// Browsers ignore whitespace and control characters in a scheme, so
+// "java\tscript:" is checked as "javascript:".
+func (p *Policy) allowURL(u string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	parsed, err := url.Parse(cleaned)
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme == "" {
+		// A colon before any path, query or fragment would make a browser
+		// read a scheme that url.Parse didn't
+		end := strings.IndexAny(cleaned, "/?#")
+		if end < 0 {
+			end = len(cleaned)
+		}
+		return !strings.Contains(cleaned[:end], ":")
+	}
+	return slices.Contains(p.URLSchemes, strings.ToLower(parsed.Scheme))
+}
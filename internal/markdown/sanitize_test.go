@@ -0,0 +1,148 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Script removed with its content",
+			input:    "<p>a<script>alert(1)</script>b</p>",
+			expected: "<p>ab</p>",
+		},
+		{
+			name:     "Nested dropped elements",
+			input:    "<object><object></object><p>hidden</p></object>shown",
+			expected: "shown",
+		},
+		{
+			name:     "Event handlers removed",
+			input:    `<p onclick="alert(1)" class="x">a</p><span onmouseover=alert(1)>b</span>`,
+			expected: `<p class="x">a</p><span>b</span>`,
+		},
+		{
+			name:     "Unknown elements keep their text",
+			input:    "<marquee><b>hi</b></marquee>",
+			expected: "<b>hi</b>",
+		},
+		{
+			name:     "javascript URL",
+			input:    `<a href="javascript:alert(1)">x</a>`,
+			expected: "<a>x</a>",
+		},
+		{
+			name:     "Obfuscated javascript URLs",
+			input:    `<a href="JaVaScRiPt:alert(1)">a</a><a href="java&#x09;script:alert(1)">b</a><a href=" &#106;avascript:alert(1)">c</a>`,
+			expected: "<a>a</a><a>b</a><a>c</a>",
+		},
+		{
+			name:     "data and vbscript URLs",
+			input:    `<a href="data:text/html,<script>alert(1)</script>">a</a><a href="vbscript:msgbox">b</a>`,
+			expected: "<a>a</a><a>b</a>",
+		},
+		{
+			name:     "Allowed URLs",
+			input:    `<a href="https://example.com/?a=1&amp;b=2">a</a><a href="/posts/x#fn:1">b</a><a href="mailto:me@example.com">c</a>`,
+			expected: `<a href="https://example.com/?a=1&amp;b=2">a</a><a href="/posts/x#fn:1">b</a><a href="mailto:me@example.com">c</a>`,
+		},
+		{
+			name:     "Style only as table alignment",
+			input:    `<td style="text-align:right">a</td><p style="background:url(javascript:alert(1))">b</p><th style="text-align:left;color:red">c</th>`,
+			expected: `<td style="text-align:right">a</td><p>b</p><th>c</th>`,
+		},
+		{
+			name:     "Attribute values stay quoted",
+			input:    `<a title='x" onclick="alert(1)' href="/">a</a>`,
+			expected: `<a title="x&quot; onclick=&quot;alert(1)" href="/">a</a>`,
+		},
+		{
+			name:     "Comments and doctypes dropped",
+			input:    "<!DOCTYPE html><!-- <script>alert(1)</script> -->text",
+			expected: "text",
+		},
+		{
+			name:     "Stray end tags dropped and open tags closed",
+			input:    "</div></article><div><em>a",
+			expected: "<div><em>a</em></div>",
+		},
+		{
+			name:     "Mismatched end tag closes inner elements",
+			input:    "<div><p><em>a</div>b",
+			expected: "<div><p><em>a</em></p></div>b",
+		},
+		{
+			name:     "Text with angle brackets escaped",
+			input:    "a < b > c",
+			expected: "a &lt; b &gt; c",
+		},
+		{
+			name:     "SVG and forms removed",
+			input:    `<svg onload="alert(1)"><script>alert(1)</script></svg><form action="/x"><button>go</button></form>`,
+			expected: "go",
+		},
+		{
+			name:     "Task list checkbox kept",
+			input:    `<input checked="" disabled="" type="checkbox"><input type="password">`,
+			expected: `<input checked="" disabled="" type="checkbox"><input>`,
+		},
+	}
+
+	policy := StrictPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Sanitize(tt.input); got != tt.expected {
+				t.Errorf("Sanitize() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	input := `<figure><img src="/a.png" alt="a" onerror="alert(1)"><figcaption>A</figcaption></figure><img src="javascript:alert(1)">`
+
+	if got := StrictPolicy().Sanitize(input); got != "A" {
+		t.Errorf("StrictPolicy().Sanitize() = %q, want %q", got, "A")
+	}
+
+	want := `<figure><img src="/a.png" alt="a"><figcaption>A</figcaption></figure><img>`
+	if got := TrustedPolicy().Sanitize(input); got != want {
+		t.Errorf("TrustedPolicy().Sanitize() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderSanitizes(t *testing.T) {
+	input := "Hi <script>alert(1)</script>\n\n<img src=x onerror=alert(1)>\n\n[x](javascript:alert(1))\n\n" +
+		"<a href=\"javascript:alert(1)\">y</a>\n\n<!--more-->\n\nRest"
+
+	r := New(DefaultExtensions)
+	doc, err := r.RenderDocument(input, nil)
+	if err != nil {
+		t.Fatalf("RenderDocument() error = %v", err)
+	}
+	for _, html := range []string{doc.HTML, doc.Summary} {
+		for _, bad := range []string{"<script", "onerror", "javascript:"} {
+			if strings.Contains(html, bad) {
+				t.Errorf("Rendered HTML contains %q: %q", bad, html)
+			}
+		}
+	}
+	if !strings.Contains(doc.HTML, `<img src="x">`) {
+		t.Errorf("TrustedPolicy dropped an image: %q", doc.HTML)
+	}
+
+	strict, _ := r.WithPolicy(StrictPolicy()).Render(input)
+	if strings.Contains(strict, "<img") {
+		t.Errorf("StrictPolicy kept an image: %q", strict)
+	}
+
+	raw, _ := r.WithPolicy(nil).Render("<script>alert(1)</script>\n")
+	if raw != "<script>alert(1)</script>\n" {
+		t.Errorf("WithPolicy(nil) sanitized: %q", raw)
+	}
+}
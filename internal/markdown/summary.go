@@ -0,0 +1,88 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// SummaryMarker ends a post's excerpt when written on a line of its own.
+// Everything before it is rendered as the summary.
+const SummaryMarker = "<!--more-->"
+
+// Limits of the automatic summary of a document without a SummaryMarker
+const (
+	SummaryWords      = 50
+	SummaryParagraphs = 2
+)
+
+// summary returns the excerpt of a parsed document: the rendered content
+// before a SummaryMarker, or else the plain text of its leading paragraphs.
+// It detaches the marker and what follows it from root.
+func (r *Renderer) summary(root ast.Node, source []byte) (string, error) {
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		if !isSummaryMarker(n, source) {
+			continue
+		}
+		for n != nil {
+			next := n.NextSibling()
+			root.RemoveChild(root, n)
+			n = next
+		}
+		var buf bytes.Buffer
+		if err := r.md.Renderer().Render(&buf, source, root); err != nil {
+			return "", err
+		}
+		return r.sanitize(buf.String()), nil
+	}
+	return autoSummary(root, source), nil
+}
+
+// isSummaryMarker reports whether n is an HTML block holding only the
+// marker, allowing spaces inside the comment as in <!-- more -->
+func isSummaryMarker(n ast.Node, source []byte) bool {
+	block, ok := n.(*ast.HTMLBlock)
+	if !ok || block.HTMLBlockType != ast.HTMLBlockType2 {
+		return false
+	}
+	var text strings.Builder
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		text.Write(segment.Value(source))
+	}
+	return strings.Join(strings.Fields(text.String()), "") == SummaryMarker
+}
+
+// autoSummary returns up to SummaryParagraphs top-level paragraphs as plain
+// text, cut after SummaryWords words. Headings, code and lists are skipped.
+func autoSummary(root ast.Node, source []byte) string {
+	var b strings.Builder
+	words, paragraphs := 0, 0
+	for n := root.FirstChild(); n != nil && paragraphs < SummaryParagraphs && words < SummaryWords; n = n.NextSibling() {
+		if n.Kind() != ast.KindParagraph {
+			continue
+		}
+		fields := strings.Fields(plainText(n, source))
+		if len(fields) == 0 {
+			continue
+		}
+
+		text := fields
+		if words+len(fields) > SummaryWords {
+			text = fields[:SummaryWords-words]
+		}
+		words += len(text)
+		paragraphs++
+
+		b.WriteString("<p>")
+		b.WriteString(html.EscapeString(strings.Join(text, " ")))
+		if len(text) < len(fields) {
+			b.WriteString("…")
+		}
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	long := strings.Repeat("word ", SummaryWords+10)
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Marker ends the excerpt",
+			input:    "# Title\n\nFirst **bold** paragraph.\n\n<!--more-->\n\nThe rest.",
+			expected: "<h1 id=\"title\">Title</h1>\n<p>First <strong>bold</strong> paragraph.</p>\n",
+		},
+		{
+			name:     "Marker with spaces",
+			input:    "Intro.\n\n<!-- more -->\n\nThe rest.",
+			expected: "<p>Intro.</p>\n",
+		},
+		{
+			name:     "Marker inside a code block is ignored",
+			input:    "Intro with `code`.\n\n```html\n<!--more-->\n```\n\nSecond [link](/x).\n\nThird.",
+			expected: "<p>Intro with code.</p>\n<p>Second link.</p>\n",
+		},
+		{
+			name:     "Fallback skips headings and strips markdown",
+			input:    "# Title\n\nFish & *chips*\nwith <b>tags</b>.\n\n- a list\n",
+			expected: "<p>Fish &amp; chips with tags.</p>\n",
+		},
+		{
+			name:     "Fallback is cut after SummaryWords",
+			input:    long,
+			expected: "<p>" + strings.TrimSpace(strings.Repeat("word ", SummaryWords)) + "…</p>\n",
+		},
+		{
+			name:     "No paragraphs",
+			input:    "## Only a heading",
+			expected: "",
+		},
+	}
+
+	r := New(DefaultExtensions)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := r.RenderDocument(tt.input, nil)
+			if err != nil {
+				t.Fatalf("RenderDocument() error = %v", err)
+			}
+			if doc.Summary != tt.expected {
+				t.Errorf("Summary = %q, want %q", doc.Summary, tt.expected)
+			}
+
+			outline, err := r.Outline(tt.input)
+			if err != nil {
+				t.Fatalf("Outline() error = %v", err)
+			}
+			if outline.Summary != doc.Summary {
+				t.Errorf("Outline() summary = %q, want %q", outline.Summary, doc.Summary)
+			}
+		})
+	}
+}
+
+func TestSummaryKeepsFullHTML(t *testing.T) {
+	doc, err := New(DefaultExtensions).RenderDocument("Intro.\n\n<!--more-->\n\nThe rest.", nil)
+	if err != nil {
+		t.Fatalf("RenderDocument() error = %v", err)
+	}
+	if !strings.Contains(doc.HTML, "<p>The rest.</p>") {
+		t.Errorf("HTML lost the content after the marker: %q", doc.HTML)
+	}
+}
@@ -2,17 +2,22 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/seanankenbruck/blog/internal/content"
 	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/markdown"
 )
 
 // FilePostRepository implements domain.PostRepository using file-based storage
-type FilePostRepository struct{}
+type FilePostRepository struct {
+	store *content.ContentStore
+}
 
-// NewFilePostRepository creates a new FilePostRepository
-func NewFilePostRepository() *FilePostRepository {
-	return &FilePostRepository{}
+// NewFilePostRepository creates a new FilePostRepository backed by the given content store
+func NewFilePostRepository(store *content.ContentStore) *FilePostRepository {
+	return &FilePostRepository{store: store}
 }
 
 
@@ -23,7 +28,7 @@ func (r *FilePostRepository) GetByID(ctx context.Context, id uint) (*domain.Post
 
 // GetBySlug retrieves a post by its slug from the file system
 func (r *FilePostRepository) GetBySlug(ctx context.Context, slug string) (*domain.Post, error) {
-	contentPost, err := content.GetPostBySlug(slug)
+	contentPost, err := r.store.GetPostBySlug(slug)
 	if err != nil {
 		return nil, domain.ErrPostNotFound
 	}
@@ -34,7 +39,7 @@ func (r *FilePostRepository) GetBySlug(ctx context.Context, slug string) (*domai
 
 // GetAll retrieves all posts from the file system
 func (r *FilePostRepository) GetAll(ctx context.Context) ([]*domain.Post, error) {
-	contentPosts, err := content.GetAllPosts()
+	contentPosts, err := r.store.GetAllPosts()
 	if err != nil {
 		return nil, err
 	}
@@ -48,16 +53,185 @@ func (r *FilePostRepository) GetAll(ctx context.Context) ([]*domain.Post, error)
 	return domainPosts, nil
 }
 
+// GetBySlugIncludingDrafts retrieves a post by its slug, including drafts and
+// scheduled posts
+func (r *FilePostRepository) GetBySlugIncludingDrafts(ctx context.Context, slug string) (*domain.Post, error) {
+	contentPost, err := r.store.GetPostBySlugIncludingDrafts(slug)
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	return contentPostToDomainPost(contentPost), nil
+}
+
+// GetAllIncludingDrafts retrieves all posts, including drafts and scheduled
+// posts, from the file system
+func (r *FilePostRepository) GetAllIncludingDrafts(ctx context.Context) ([]*domain.Post, error) {
+	contentPosts, err := r.store.GetAllPostsIncludingDrafts()
+	if err != nil {
+		return nil, err
+	}
+
+	domainPosts := make([]*domain.Post, len(contentPosts))
+	for i, cp := range contentPosts {
+		domainPosts[i] = contentPostToDomainPost(cp)
+	}
+
+	return domainPosts, nil
+}
+
+// Create writes a new markdown file for the post and reloads the content store
+func (r *FilePostRepository) Create(ctx context.Context, post *domain.Post) error {
+	cp := &content.Post{
+		Title:       post.Title,
+		Slug:        post.Slug,
+		Date:        post.CreatedAt,
+		Tags:        post.Tags,
+		Description: post.Description,
+		Published:   post.Published,
+		PublishAt:   post.PublishAt,
+		Series:      post.Series,
+		SeriesOrder: post.SeriesOrder,
+		Related:     post.Related,
+		Content:     post.Content,
+	}
+
+	return storeError(r.store.CreatePost(cp))
+}
+
+// Update rewrites the post's markdown file and reloads the content store
+func (r *FilePostRepository) Update(ctx context.Context, slug string, post *domain.Post) error {
+	existing, err := r.store.GetPostBySlugIncludingDrafts(slug)
+	if err != nil {
+		return storeError(err)
+	}
+
+	cp := *existing
+	cp.Title = post.Title
+	cp.Slug = post.Slug
+	cp.Tags = post.Tags
+	cp.Description = post.Description
+	cp.Date = post.CreatedAt
+	cp.Published = post.Published
+	cp.PublishAt = post.PublishAt
+	cp.Series = post.Series
+	cp.SeriesOrder = post.SeriesOrder
+	cp.Related = post.Related
+	cp.Content = post.Content
+	cp.Updated = post.UpdatedAt
+
+	return storeError(r.store.UpdatePost(slug, &cp))
+}
+
+// Delete removes the post's markdown file and reloads the content store
+func (r *FilePostRepository) Delete(ctx context.Context, slug string) error {
+	return storeError(r.store.DeletePost(slug))
+}
+
+// Publish marks the post published and clears any future publish_at. A post
+// that was not yet visible is redated to now so it appears as the newest.
+func (r *FilePostRepository) Publish(ctx context.Context, slug string) error {
+	existing, err := r.store.GetPostBySlugIncludingDrafts(slug)
+	if err != nil {
+		return storeError(err)
+	}
+
+	now := time.Now()
+	if existing.IsVisibleAt(now) {
+		return nil
+	}
+
+	cp := *existing
+	cp.Published = true
+	cp.PublishAt = time.Time{}
+	cp.Date = now
+
+	return storeError(r.store.UpdatePost(slug, &cp))
+}
+
+// Search queries the content store's full-text index
+func (r *FilePostRepository) Search(ctx context.Context, query string) ([]*domain.SearchResult, error) {
+	hits, err := r.store.Search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*domain.SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = &domain.SearchResult{
+			Post:  contentPostToDomainPost(hit.Post),
+			Score: hit.Score,
+		}
+	}
+
+	return results, nil
+}
+
+// Related returns the posts related to the post with the given slug, as
+// ranked by the content store when posts were loaded
+func (r *FilePostRepository) Related(ctx context.Context, slug string, limit int) ([]*domain.Post, error) {
+	related, err := r.store.RelatedPosts(slug, limit)
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	posts := make([]*domain.Post, len(related))
+	for i, p := range related {
+		posts[i] = contentPostToDomainPost(p)
+	}
+	return posts, nil
+}
 
 // contentPostToDomainPost converts a content.Post to a domain.Post
 func contentPostToDomainPost(cp *content.Post) *domain.Post {
+	updatedAt := cp.Date
+	if cp.Updated.After(cp.Date) {
+		updatedAt = cp.Updated
+	}
+
+	var toc []domain.TOCEntry
+	if !cp.NoTOC {
+		toc = newTOC(cp.Headings)
+	}
+
 	return &domain.Post{
 		Title:       cp.Title,
 		Content:     cp.HTMLContent, // Use pre-rendered HTML
+		Markdown:    cp.Content,
 		Description: cp.Description,
+		Summary:     cp.Summary,
 		Slug:        cp.Slug,
+		Tags:        cp.Tags,
+		Series:      cp.Series,
+		SeriesOrder: cp.SeriesOrder,
+		Related:     cp.Related,
 		Published:   cp.Published,
+		Draft:       !cp.IsVisibleAt(time.Now()),
+		PublishAt:   cp.PublishAt,
 		CreatedAt:   cp.Date,
-		UpdatedAt:   cp.Date,
+		UpdatedAt:   updatedAt,
+		TOC:         toc,
+		WordCount:   cp.WordCount,
+		ReadingTime: domain.ReadingTime(cp.WordCount),
+	}
+}
+
+// newTOC builds a post's table of contents from its rendered headings
+func newTOC(headings []markdown.Heading) []domain.TOCEntry {
+	entries := make([]domain.TOCEntry, len(headings))
+	for i, h := range headings {
+		entries[i] = domain.TOCEntry{ID: h.ID, Text: h.Text, Level: h.Level}
+	}
+	return domain.NewTOC(entries)
+}
+
+// storeError maps content store errors to their domain equivalents
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, content.ErrPostNotFound):
+		return domain.ErrPostNotFound
+	case errors.Is(err, content.ErrPostExists):
+		return domain.ErrSlugExists
 	}
+	return err
 }
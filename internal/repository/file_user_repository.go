@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// userRecord is a user as stored in the users file
+type userRecord struct {
+	ID             uint      `json:"id"`
+	Username       string    `json:"username"`
+	PasswordHash   string    `json:"password_hash"`
+	SessionVersion int       `json:"session_version,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// FileUserRepository implements domain.UserRepository with a JSON file. The
+// file is read on every call so edits made by hand take effect immediately.
+type FileUserRepository struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileUserRepository creates a FileUserRepository storing users in path.
+// The file is created on the first write.
+func NewFileUserRepository(path string) *FileUserRepository {
+	return &FileUserRepository{path: path}
+}
+
+// GetByUsername retrieves a user by username
+func (r *FileUserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.Username == username {
+			return recordToUser(rec), nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+// GetAll retrieves all users ordered by username
+func (r *FileUserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*domain.User, len(records))
+	for i, rec := range records {
+		users[i] = recordToUser(rec)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users, nil
+}
+
+// Create adds a user to the file, assigning its ID
+func (r *FileUserRepository) Create(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	var maxID uint
+	for _, rec := range records {
+		if rec.Username == user.Username {
+			return domain.ErrUserExists
+		}
+		maxID = max(maxID, rec.ID)
+	}
+
+	user.ID = maxID + 1
+	records = append(records, userRecord{
+		ID:             user.ID,
+		Username:       user.Username,
+		PasswordHash:   user.PasswordHash,
+		SessionVersion: user.SessionVersion,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+	})
+	return r.write(records)
+}
+
+// Update saves a user's password hash and session version
+func (r *FileUserRepository) Update(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records, err := r.read()
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		if records[i].Username == user.Username {
+			records[i].PasswordHash = user.PasswordHash
+			records[i].SessionVersion = user.SessionVersion
+			records[i].UpdatedAt = user.UpdatedAt
+			return r.write(records)
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+// read returns the stored users; a missing file holds no users
+func (r *FileUserRepository) read() ([]userRecord, error) {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	var records []userRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse users file %s: %w", r.path, err)
+	}
+	return records, nil
+}
+
+// write replaces the users file atomically. It holds password hashes, so it
+// is only readable by its owner.
+func (r *FileUserRepository) write(records []userRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".users-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write users file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write users file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write users file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to write users file: %w", err)
+	}
+	return nil
+}
+
+func recordToUser(rec userRecord) *domain.User {
+	return &domain.User{
+		ID:             rec.ID,
+		Username:       rec.Username,
+		PasswordHash:   rec.PasswordHash,
+		SessionVersion: rec.SessionVersion,
+		CreatedAt:      rec.CreatedAt,
+		UpdatedAt:      rec.UpdatedAt,
+	}
+}
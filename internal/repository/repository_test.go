@@ -2,15 +2,18 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/seanankenbruck/blog/internal/content"
+	"github.com/seanankenbruck/blog/internal/domain"
 )
 
 func TestFilePostRepositoryCreation(t *testing.T) {
-	repo := NewFilePostRepository()
+	repo := NewFilePostRepository(content.NewContentStore(t.TempDir(), false))
 	if repo == nil {
 		t.Error("Expected FilePostRepository to be created, got nil")
 	}
@@ -52,13 +55,13 @@ This is the content of test post 2.`
 	}
 
 	// Initialize the content loader
-	content.Init(tempDir, false)
-	if err := content.LoadPosts(); err != nil {
+	store := content.NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
 		t.Fatalf("LoadPosts() failed: %v", err)
 	}
 
 	// Create the repository
-	repo := NewFilePostRepository()
+	repo := NewFilePostRepository(store)
 
 	// Call GetAll
 	posts, err := repo.GetAll(context.Background())
@@ -104,13 +107,13 @@ This is the content of the test post.`
 	}
 
 	// Initialize the content loader
-	content.Init(tempDir, false)
-	if err := content.LoadPosts(); err != nil {
+	store := content.NewContentStore(tempDir, false)
+	if err := store.LoadPosts(); err != nil {
 		t.Fatalf("LoadPosts() failed: %v", err)
 	}
 
 	// Create the repository
-	repo := NewFilePostRepository()
+	repo := NewFilePostRepository(store)
 
 	t.Run("Get existing post by slug", func(t *testing.T) {
 		post, err := repo.GetBySlug(context.Background(), "test-post")
@@ -129,6 +132,10 @@ This is the content of the test post.`
 		if post.Slug != "test-post" {
 			t.Errorf("Expected slug 'test-post', got '%s'", post.Slug)
 		}
+
+		if post.Draft {
+			t.Error("Expected published post not to be marked as draft")
+		}
 	})
 
 	t.Run("Get non-existent post by slug", func(t *testing.T) {
@@ -141,7 +148,7 @@ This is the content of the test post.`
 
 func TestGetByID(t *testing.T) {
 	// Create the repository
-	repo := NewFilePostRepository()
+	repo := NewFilePostRepository(content.NewContentStore(t.TempDir(), false))
 
 	// GetByID is not supported in file-based repository
 	_, err := repo.GetByID(context.Background(), 1)
@@ -149,3 +156,141 @@ func TestGetByID(t *testing.T) {
 		t.Error("GetByID() should return error for file-based repository")
 	}
 }
+
+func TestUpdatedDate(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testPost := `---
+title: "Revised Post"
+slug: "revised-post"
+date: 2024-01-15T10:00:00Z
+updated: 2024-02-01T08:00:00Z
+description: "This post was revised"
+published: true
+---
+
+Revised content.`
+
+	if err := os.WriteFile(filepath.Join(tempDir, "revised.md"), []byte(testPost), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	store := content.NewContentStore(tempDir, false)
+	repo := NewFilePostRepository(store)
+
+	post, err := repo.GetBySlug(context.Background(), "revised-post")
+	if err != nil {
+		t.Fatalf("GetBySlug() returned error: %v", err)
+	}
+
+	if !post.CreatedAt.Equal(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected CreatedAt from date, got %v", post.CreatedAt)
+	}
+	if !post.UpdatedAt.Equal(time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected UpdatedAt from updated, got %v", post.UpdatedAt)
+	}
+}
+
+func TestFilePostRepositoryWrites(t *testing.T) {
+	tempDir := t.TempDir()
+	store := content.NewContentStore(tempDir, false)
+	repo := NewFilePostRepository(store)
+	ctx := context.Background()
+
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	post := &domain.Post{
+		Title:       "Draft Post",
+		Slug:        "draft-post",
+		Description: "A draft",
+		Content:     "Draft **content**.",
+		Tags:        []string{"go"},
+		CreatedAt:   created,
+		UpdatedAt:   created,
+	}
+	if err := repo.Create(ctx, post); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if err := repo.Create(ctx, post); !errors.Is(err, domain.ErrSlugExists) {
+		t.Errorf("Create() expected ErrSlugExists for duplicate, got %v", err)
+	}
+
+	if _, err := repo.GetBySlug(ctx, "draft-post"); !errors.Is(err, domain.ErrPostNotFound) {
+		t.Errorf("Expected draft to be hidden, got %v", err)
+	}
+	draft, err := repo.GetBySlugIncludingDrafts(ctx, "draft-post")
+	if err != nil {
+		t.Fatalf("GetBySlugIncludingDrafts() returned error: %v", err)
+	}
+	if !draft.Draft || draft.Markdown != "Draft **content**." || draft.Content != "<p>Draft <strong>content</strong>.</p>\n" {
+		t.Errorf("Unexpected draft: %+v", draft)
+	}
+
+	draft.Title = "Draft Post Revised"
+	draft.Content = draft.Markdown
+	draft.UpdatedAt = created.Add(time.Hour)
+	if err := repo.Update(ctx, "draft-post", draft); err != nil {
+		t.Fatalf("Update() returned error: %v", err)
+	}
+
+	if err := repo.Publish(ctx, "draft-post"); err != nil {
+		t.Fatalf("Publish() returned error: %v", err)
+	}
+	published, err := repo.GetBySlug(ctx, "draft-post")
+	if err != nil {
+		t.Fatalf("GetBySlug() after publish returned error: %v", err)
+	}
+	if published.Title != "Draft Post Revised" || !published.CreatedAt.After(created) {
+		t.Errorf("Expected revised title and a new date after publishing, got %+v", published)
+	}
+
+	// The file keeps its original name across updates
+	if _, err := os.Stat(filepath.Join(tempDir, "2024-03-01-draft-post.md")); err != nil {
+		t.Errorf("Expected post file to exist: %v", err)
+	}
+
+	if err := repo.Delete(ctx, "draft-post"); err != nil {
+		t.Fatalf("Delete() returned error: %v", err)
+	}
+	if err := repo.Delete(ctx, "draft-post"); !errors.Is(err, domain.ErrPostNotFound) {
+		t.Errorf("Delete() expected ErrPostNotFound, got %v", err)
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"guide.md": "---\ntitle: \"Guide\"\nslug: \"guide\"\ndate: 2024-01-15T10:00:00Z\ndescription: \"A guide\"\npublished: true\n---\n\n" +
+			"# Guide\n\n## Install\n\nRun the installer.\n\n### Linux\n\nUse the package.\n\n## Usage\n\nStart it.\n",
+		"short.md": "---\ntitle: \"Short\"\nslug: \"short\"\ndate: 2024-01-16T10:00:00Z\ndescription: \"A short post\"\npublished: true\ntoc: false\n---\n\n" +
+			"## Only section\n\nText.\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	repo := NewFilePostRepository(content.NewContentStore(tempDir, false))
+
+	post, err := repo.GetBySlug(context.Background(), "guide")
+	if err != nil {
+		t.Fatalf("GetBySlug() returned error: %v", err)
+	}
+	if len(post.TOC) != 2 || post.TOC[0].ID != "install" || post.TOC[1].ID != "usage" {
+		t.Fatalf("Expected [install usage] at the top of the TOC, got %+v", post.TOC)
+	}
+	if len(post.TOC[0].Children) != 1 || post.TOC[0].Children[0].Text != "Linux" {
+		t.Errorf("Expected Linux nested under Install, got %+v", post.TOC[0].Children)
+	}
+	if post.WordCount != 12 || post.ReadingTime != 1 {
+		t.Errorf("Expected 12 words and 1 minute, got %d and %d", post.WordCount, post.ReadingTime)
+	}
+
+	post, err = repo.GetBySlug(context.Background(), "short")
+	if err != nil {
+		t.Fatalf("GetBySlug() returned error: %v", err)
+	}
+	if post.TOC != nil {
+		t.Errorf("Expected toc: false to hide the TOC, got %+v", post.TOC)
+	}
+}
@@ -0,0 +1,564 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/database"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/markdown"
+	"github.com/seanankenbruck/blog/internal/search"
+)
+
+// postColumns are the posts columns read into a domain.Post, in scan order
+const postColumns = "id, slug, title, description, content, content_html, published, publish_at, created_at, updated_at, series, series_order, summary, toc, word_count, reading_time"
+
+// SQLPostRepository implements domain.PostRepository on a SQL database
+type SQLPostRepository struct {
+	db      *sql.DB
+	dialect  database.Dialect
+	renderer *markdown.Renderer
+	isDev    bool
+	now      func() time.Time
+
+	related relatedRanking
+}
+
+// relatedRanking caches the similarity ranking of every post. It is rebuilt
+// once posts are written, here or by another replica.
+type relatedRanking struct {
+	mu      sync.Mutex
+	version string // Count, newest ID and latest update of the ranked posts
+	ranking *search.Related
+}
+
+// postList is a table holding an ordered list of strings for each post
+type postList struct {
+	table  string
+	column string
+	get    func(p *domain.Post) []string
+	add    func(p *domain.Post, value string)
+}
+
+// postLists are the lists read into and written from a domain.Post
+var postLists = []postList{
+	{
+		table:  "post_tags",
+		column: "name",
+		get:    func(p *domain.Post) []string { return p.Tags },
+		add:    func(p *domain.Post, value string) { p.Tags = append(p.Tags, value) },
+	},
+	{
+		table:  "post_related",
+		column: "slug",
+		get:    func(p *domain.Post) []string { return p.Related },
+		add:    func(p *domain.Post, value string) { p.Related = append(p.Related, value) },
+	},
+}
+
+// NewSQLPostRepository creates a new SQLPostRepository. The schema must already
+// be migrated with database.Migrate. As with the file repository, drafts and
+// scheduled posts are only returned in development mode. Written posts are
+// rendered to HTML with renderer, and their summary, table of contents and
+// word count are stored alongside so reads need not render them again.
+func NewSQLPostRepository(db *sql.DB, dialect database.Dialect, renderer *markdown.Renderer, devMode bool) *SQLPostRepository {
+	return &SQLPostRepository{db: db, dialect: dialect, renderer: renderer, isDev: devMode, now: time.Now}
+}
+
+// GetByID retrieves a post by its database ID
+func (r *SQLPostRepository) GetByID(ctx context.Context, id uint) (*domain.Post, error) {
+	return r.getOne(ctx, false, "id = ?", int64(id))
+}
+
+// GetBySlug retrieves a post by its slug
+func (r *SQLPostRepository) GetBySlug(ctx context.Context, slug string) (*domain.Post, error) {
+	return r.getOne(ctx, false, "slug = ?", slug)
+}
+
+// GetBySlugIncludingDrafts retrieves a post by its slug, including drafts and
+// scheduled posts
+func (r *SQLPostRepository) GetBySlugIncludingDrafts(ctx context.Context, slug string) (*domain.Post, error) {
+	return r.getOne(ctx, true, "slug = ?", slug)
+}
+
+// GetAll retrieves all posts, newest first
+func (r *SQLPostRepository) GetAll(ctx context.Context) ([]*domain.Post, error) {
+	return r.list(ctx, false)
+}
+
+// GetAllIncludingDrafts retrieves all posts, including drafts and scheduled
+// posts, newest first
+func (r *SQLPostRepository) GetAllIncludingDrafts(ctx context.Context) ([]*domain.Post, error) {
+	return r.list(ctx, true)
+}
+
+// list returns the visible posts, or every post if drafts is set
+func (r *SQLPostRepository) list(ctx context.Context, drafts bool) ([]*domain.Post, error) {
+	where, args := r.visibility(drafts)
+	rows, err := r.db.QueryContext(ctx,
+		r.dialect.Rebind("SELECT "+postColumns+" FROM posts"+where+" ORDER BY created_at DESC, id DESC"),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]*domain.Post, 0)
+	for rows.Next() {
+		p, err := r.scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadLists(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// getOne returns the single visible post matching cond, or the single post
+// matching cond if drafts is set
+func (r *SQLPostRepository) getOne(ctx context.Context, drafts bool, cond string, arg any) (*domain.Post, error) {
+	where, args := r.visibility(drafts)
+	if where == "" {
+		where = " WHERE " + cond
+	} else {
+		where += " AND " + cond
+	}
+
+	row := r.db.QueryRowContext(ctx,
+		r.dialect.Rebind("SELECT "+postColumns+" FROM posts"+where),
+		append(args, arg)...,
+	)
+	p, err := r.scanPost(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadLists(ctx, []*domain.Post{p}); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// visibility returns the WHERE clause hiding drafts and scheduled posts
+// outside development mode, unless drafts is set
+func (r *SQLPostRepository) visibility(drafts bool) (string, []any) {
+	if r.isDev || drafts {
+		return "", nil
+	}
+	return " WHERE published = ? AND (publish_at IS NULL OR publish_at <= ?)", []any{true, r.now().UTC()}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *SQLPostRepository) scanPost(s scanner) (*domain.Post, error) {
+	var p domain.Post
+	var id int64
+	var publishAt sql.NullTime
+	var toc sql.NullString
+	if err := s.Scan(&id, &p.Slug, &p.Title, &p.Description, &p.Markdown, &p.Content, &p.Published, &publishAt, &p.CreatedAt, &p.UpdatedAt, &p.Series, &p.SeriesOrder,
+		&p.Summary, &toc, &p.WordCount, &p.ReadingTime); err != nil {
+		return nil, err
+	}
+
+	p.ID = uint(id)
+	p.Tags = []string{}
+	if publishAt.Valid {
+		p.PublishAt = publishAt.Time
+	}
+	p.Draft = !p.Published || (publishAt.Valid && publishAt.Time.After(r.now()))
+
+	if toc.Valid {
+		if err := json.Unmarshal([]byte(toc.String), &p.TOC); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// loadLists fills in the tags and related pins of posts
+func (r *SQLPostRepository) loadLists(ctx context.Context, posts []*domain.Post) error {
+	for _, list := range postLists {
+		if err := r.loadList(ctx, posts, list); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadList fills in one list of posts in a single query. With one post
+// only its entries are read; otherwise every entry is read and matched up,
+// which avoids bind parameter limits on large listings.
+func (r *SQLPostRepository) loadList(ctx context.Context, posts []*domain.Post, list postList) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*domain.Post, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+	}
+
+	query := "SELECT post_id, " + list.column + " FROM " + list.table + " ORDER BY post_id, position"
+	var args []any
+	if len(posts) == 1 {
+		query = "SELECT post_id, " + list.column + " FROM " + list.table + " WHERE post_id = ? ORDER BY position"
+		args = append(args, int64(posts[0].ID))
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			return err
+		}
+		if p, ok := byID[uint(id)]; ok {
+			list.add(p, value)
+		}
+	}
+	return rows.Err()
+}
+
+// renderedPost holds the columns rendered from a post's markdown
+type renderedPost struct {
+	html        string
+	summary     string
+	toc         string // Table of contents as JSON
+	wordCount   int
+	readingTime int
+}
+
+// render renders markdown into the columns stored with it
+func (r *SQLPostRepository) render(markdown string) (*renderedPost, error) {
+	doc, err := r.renderer.RenderDocument(markdown, nil)
+	if err != nil {
+		return nil, err
+	}
+	toc, err := json.Marshal(newTOC(doc.Headings))
+	if err != nil {
+		return nil, err
+	}
+	return &renderedPost{
+		html:        doc.HTML,
+		summary:     doc.Summary,
+		toc:         string(toc),
+		wordCount:   doc.Words,
+		readingTime: domain.ReadingTime(doc.Words),
+	}, nil
+}
+
+// RenderOutlines stores the summary, table of contents and word count of
+// posts saved before those columns existed. It is a no-op once every post
+// has them.
+func (r *SQLPostRepository) RenderOutlines(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, content FROM posts WHERE toc IS NULL")
+	if err != nil {
+		return err
+	}
+	contents := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Rows are updated once read, as SQLite has a single connection
+	for id, content := range contents {
+		rendered, err := r.render(content)
+		if err != nil {
+			return err
+		}
+		if _, err := r.db.ExecContext(ctx,
+			r.dialect.Rebind("UPDATE posts SET summary = ?, toc = ?, word_count = ?, reading_time = ? WHERE id = ?"),
+			rendered.summary, rendered.toc, rendered.wordCount, rendered.readingTime, id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create inserts the post with its tags and related pins, rendering its
+// markdown to HTML. It returns domain.ErrSlugExists if the slug is taken.
+func (r *SQLPostRepository) Create(ctx context.Context, post *domain.Post) error {
+	rendered, err := r.render(post.Content)
+	if err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if err := r.checkSlugFree(ctx, tx, post.Slug, 0); err != nil {
+			return err
+		}
+
+		var id int64
+		err := tx.QueryRowContext(ctx, r.dialect.Rebind(`INSERT INTO posts
+			(slug, title, description, content, content_html, published, publish_at, created_at, updated_at, series, series_order,
+			summary, toc, word_count, reading_time)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+			post.Slug, post.Title, post.Description, post.Content, rendered.html,
+			post.Published, nullTime(post.PublishAt), post.CreatedAt.UTC(), post.UpdatedAt.UTC(),
+			post.Series, post.SeriesOrder,
+			rendered.summary, rendered.toc, rendered.wordCount, rendered.readingTime,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		post.ID = uint(id)
+		return r.saveLists(ctx, tx, id, post)
+	})
+}
+
+// Update replaces the post with the given slug
+func (r *SQLPostRepository) Update(ctx context.Context, slug string, post *domain.Post) error {
+	rendered, err := r.render(post.Content)
+	if err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		id, err := r.lookupID(ctx, tx, slug)
+		if err != nil {
+			return err
+		}
+		if err := r.checkSlugFree(ctx, tx, post.Slug, id); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, r.dialect.Rebind(`UPDATE posts SET
+			slug = ?, title = ?, description = ?, content = ?, content_html = ?, published = ?,
+			publish_at = ?, created_at = ?, updated_at = ?, series = ?, series_order = ?,
+			summary = ?, toc = ?, word_count = ?, reading_time = ?
+			WHERE id = ?`),
+			post.Slug, post.Title, post.Description, post.Content, rendered.html,
+			post.Published, nullTime(post.PublishAt), post.CreatedAt.UTC(), post.UpdatedAt.UTC(),
+			post.Series, post.SeriesOrder,
+			rendered.summary, rendered.toc, rendered.wordCount, rendered.readingTime, id,
+		); err != nil {
+			return err
+		}
+
+		for _, list := range postLists {
+			if _, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM "+list.table+" WHERE post_id = ?"), id); err != nil {
+				return err
+			}
+		}
+		return r.saveLists(ctx, tx, id, post)
+	})
+}
+
+// Delete removes the post with the given slug; its tags and related pins are
+// removed by the foreign key cascade
+func (r *SQLPostRepository) Delete(ctx context.Context, slug string) error {
+	res, err := r.db.ExecContext(ctx, r.dialect.Rebind("DELETE FROM posts WHERE slug = ?"), slug)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrPostNotFound
+	}
+	return nil
+}
+
+// Publish marks the post published and clears any future publish_at. A post
+// that was not yet visible is redated to now so it appears as the newest.
+func (r *SQLPostRepository) Publish(ctx context.Context, slug string) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		var id int64
+		var published bool
+		var publishAt sql.NullTime
+		err := tx.QueryRowContext(ctx,
+			r.dialect.Rebind("SELECT id, published, publish_at FROM posts WHERE slug = ?"), slug,
+		).Scan(&id, &published, &publishAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		now := r.now().UTC()
+		if published && !(publishAt.Valid && publishAt.Time.After(now)) {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx,
+			r.dialect.Rebind("UPDATE posts SET published = ?, publish_at = NULL, created_at = ?, updated_at = ? WHERE id = ?"),
+			true, now, now, id,
+		)
+		return err
+	})
+}
+
+// inTx runs fn in a transaction, committing if it returns nil
+func (r *SQLPostRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lookupID returns the ID of the post with the given slug
+func (r *SQLPostRepository) lookupID(ctx context.Context, tx *sql.Tx, slug string) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, r.dialect.Rebind("SELECT id FROM posts WHERE slug = ?"), slug).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, domain.ErrPostNotFound
+	}
+	return id, err
+}
+
+// checkSlugFree returns domain.ErrSlugExists if a post other than self has
+// the slug
+func (r *SQLPostRepository) checkSlugFree(ctx context.Context, tx *sql.Tx, slug string, self int64) error {
+	id, err := r.lookupID(ctx, tx, slug)
+	if errors.Is(err, domain.ErrPostNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if id != self {
+		return domain.ErrSlugExists
+	}
+	return nil
+}
+
+// saveLists inserts the post's tags and related pins in order
+func (r *SQLPostRepository) saveLists(ctx context.Context, tx *sql.Tx, id int64, post *domain.Post) error {
+	for _, list := range postLists {
+		for i, value := range list.get(post) {
+			if _, err := tx.ExecContext(ctx,
+				r.dialect.Rebind("INSERT INTO "+list.table+" (post_id, position, "+list.column+") VALUES (?, ?, ?)"),
+				id, i, value,
+			); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Related returns up to limit visible posts related to the post with the
+// given slug: first those pinned in its related list, in order, then the
+// most similar by tags and text
+func (r *SQLPostRepository) Related(ctx context.Context, slug string, limit int) ([]*domain.Post, error) {
+	post, err := r.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return []*domain.Post{}, nil
+	}
+
+	ranking, err := r.ranking(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidates := append([]string{}, post.Related...)
+	for _, hit := range ranking.Ranked(slug) {
+		candidates = append(candidates, hit.ID)
+	}
+
+	related := make([]*domain.Post, 0, limit)
+	seen := map[string]bool{slug: true}
+	for _, candidate := range candidates {
+		if len(related) == limit {
+			break
+		}
+		if seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+
+		// Pins may name missing posts, and drafts are ranked but not shown
+		p, err := r.GetBySlug(ctx, candidate)
+		if errors.Is(err, domain.ErrPostNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		related = append(related, p)
+	}
+	return related, nil
+}
+
+// ranking returns the similarity ranking of every post, drafts included,
+// rebuilding it if the posts table changed since it was built
+func (r *SQLPostRepository) ranking(ctx context.Context) (*search.Related, error) {
+	var count, newest int64
+	var updated sql.NullString
+	if err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(MAX(id), 0), MAX(updated_at) FROM posts",
+	).Scan(&count, &newest, &updated); err != nil {
+		return nil, err
+	}
+	version := fmt.Sprint(count, newest, updated.String)
+
+	r.related.mu.Lock()
+	defer r.related.mu.Unlock()
+	if r.related.ranking != nil && r.related.version == version {
+		return r.related.ranking, nil
+	}
+
+	posts, err := r.list(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]search.Document, len(posts))
+	for i, p := range posts {
+		docs[i] = search.Document{ID: p.Slug, Title: p.Title, Description: p.Description, Tags: p.Tags, Body: p.Markdown}
+	}
+	r.related.ranking = search.NewRelated(docs)
+	r.related.version = version
+	return r.related.ranking, nil
+}
+
+// nullTime stores a zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/config"
+	"github.com/seanankenbruck/blog/internal/database"
+	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/markdown"
+)
+
+// setupSQLite returns a migrated SQLite database seeded with a published, a
+// draft and a scheduled post
+func setupSQLite(t *testing.T) (*sql.DB, database.Dialect) {
+	ctx := context.Background()
+	db, dialect, err := database.Open(ctx, &config.Config{DBDriver: "sqlite", DBPath: filepath.Join(t.TempDir(), "blog.db")})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.Migrate(ctx, db, dialect); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	base := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	posts := []struct {
+		slug      string
+		published bool
+		publishAt any
+		created   time.Time
+		tags      []string
+	}{
+		{"older-post", true, nil, base, []string{"go", "testing"}},
+		{"newer-post", true, nil, base.AddDate(0, 0, 5), []string{"observability"}},
+		{"draft-post", false, nil, base.AddDate(0, 0, 1), nil},
+		{"scheduled-post", true, base.AddDate(0, 1, 0), base.AddDate(0, 1, 0), nil},
+	}
+	for _, p := range posts {
+		res, err := db.ExecContext(ctx,
+			`INSERT INTO posts (slug, title, description, content, content_html, published, publish_at, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.slug, "Title "+p.slug, "Description", "Body", "<p>Body</p>", p.published, p.publishAt, p.created, p.created.Add(time.Hour),
+		)
+		if err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		id, _ := res.LastInsertId()
+		for i, tag := range p.tags {
+			if _, err := db.ExecContext(ctx, "INSERT INTO post_tags (post_id, position, name) VALUES (?, ?, ?)", id, i, tag); err != nil {
+				t.Fatalf("Failed to insert tag: %v", err)
+			}
+		}
+	}
+
+	return db, dialect
+}
+
+func TestSQLPostRepository(t *testing.T) {
+	db, dialect := setupSQLite(t)
+	repo := NewSQLPostRepository(db, dialect, markdown.New(markdown.DefaultExtensions), false)
+	repo.now = func() time.Time { return time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	// The seeded posts were inserted without their outline columns
+	if err := repo.RenderOutlines(ctx); err != nil {
+		t.Fatalf("RenderOutlines() error = %v", err)
+	}
+
+	t.Run("GetAll returns published posts newest first", func(t *testing.T) {
+		posts, err := repo.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("GetAll() error = %v", err)
+		}
+		if len(posts) != 2 {
+			t.Fatalf("Expected 2 posts, got %d", len(posts))
+		}
+		if posts[0].Slug != "newer-post" || posts[1].Slug != "older-post" {
+			t.Errorf("Expected [newer-post older-post], got [%s %s]", posts[0].Slug, posts[1].Slug)
+		}
+		if len(posts[1].Tags) != 2 || posts[1].Tags[0] != "go" || posts[1].Tags[1] != "testing" {
+			t.Errorf("Expected tags [go testing], got %v", posts[1].Tags)
+		}
+		if posts[0].Content != "<p>Body</p>" {
+			t.Errorf("Expected rendered HTML content, got %q", posts[0].Content)
+		}
+		if posts[0].Draft {
+			t.Error("Expected published post not to be a draft")
+		}
+		if posts[0].WordCount != 1 || posts[0].ReadingTime != 1 {
+			t.Errorf("Expected 1 word and 1 minute, got %d and %d", posts[0].WordCount, posts[0].ReadingTime)
+		}
+		if posts[0].Summary != "<p>Body</p>\n" {
+			t.Errorf("Expected the first paragraph as the summary, got %q", posts[0].Summary)
+		}
+	})
+
+	t.Run("GetBySlug", func(t *testing.T) {
+		post, err := repo.GetBySlug(ctx, "older-post")
+		if err != nil {
+			t.Fatalf("GetBySlug() error = %v", err)
+		}
+		if post.ID == 0 || post.Title != "Title older-post" {
+			t.Errorf("Unexpected post: %+v", post)
+		}
+		if want := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC); !post.CreatedAt.Equal(want) {
+			t.Errorf("Expected created_at %v, got %v", want, post.CreatedAt)
+		}
+		if !post.UpdatedAt.After(post.CreatedAt) {
+			t.Errorf("Expected updated_at after created_at, got %v", post.UpdatedAt)
+		}
+
+		for _, slug := range []string{"draft-post", "scheduled-post", "missing"} {
+			if _, err := repo.GetBySlug(ctx, slug); err != domain.ErrPostNotFound {
+				t.Errorf("GetBySlug(%q) expected ErrPostNotFound, got %v", slug, err)
+			}
+		}
+	})
+
+	t.Run("GetByID", func(t *testing.T) {
+		bySlug, _ := repo.GetBySlug(ctx, "newer-post")
+		post, err := repo.GetByID(ctx, bySlug.ID)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if post.Slug != "newer-post" || len(post.Tags) != 1 {
+			t.Errorf("Unexpected post: %+v", post)
+		}
+		if _, err := repo.GetByID(ctx, 9999); err != domain.ErrPostNotFound {
+			t.Errorf("GetByID() expected ErrPostNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Scheduled post appears once publish_at passes", func(t *testing.T) {
+		repo.now = func() time.Time { return time.Date(2024, 2, 15, 10, 0, 0, 0, time.UTC) }
+		defer func() { repo.now = func() time.Time { return time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC) } }()
+
+		post, err := repo.GetBySlug(ctx, "scheduled-post")
+		if err != nil {
+			t.Fatalf("GetBySlug() error = %v", err)
+		}
+		if post.Draft {
+			t.Error("Expected scheduled post to be live")
+		}
+	})
+}
+
+func TestSQLPostRepositoryDevMode(t *testing.T) {
+	db, dialect := setupSQLite(t)
+	repo := NewSQLPostRepository(db, dialect, markdown.New(markdown.DefaultExtensions), true)
+	repo.now = func() time.Time { return time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC) }
+
+	posts, err := repo.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(posts) != 4 {
+		t.Fatalf("Expected all 4 posts in dev mode, got %d", len(posts))
+	}
+
+	drafts := 0
+	for _, p := range posts {
+		if p.Draft {
+			drafts++
+		}
+	}
+	if drafts != 2 {
+		t.Errorf("Expected draft and scheduled posts flagged as drafts, got %d", drafts)
+	}
+}
+
+func TestSQLPostRepositoryWrites(t *testing.T) {
+	db, dialect := setupSQLite(t)
+	repo := NewSQLPostRepository(db, dialect, markdown.New(markdown.DefaultExtensions), false)
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	t.Run("Create renders markdown and stores tags", func(t *testing.T) {
+		post := &domain.Post{
+			Title:       "Created",
+			Slug:        "created",
+			Description: "Desc",
+			Content:     "# Hello",
+			Tags:        []string{"go", "sql"},
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := repo.Create(ctx, post); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if post.ID == 0 {
+			t.Error("Expected Create to set the post ID")
+		}
+
+		got, err := repo.GetBySlugIncludingDrafts(ctx, "created")
+		if err != nil {
+			t.Fatalf("GetBySlugIncludingDrafts() error = %v", err)
+		}
+		if !strings.Contains(got.Content, "<h1") || got.Markdown != "# Hello" {
+			t.Errorf("Unexpected content %q / markdown %q", got.Content, got.Markdown)
+		}
+		if len(got.Tags) != 2 || got.Tags[1] != "sql" {
+			t.Errorf("Expected tags [go sql], got %v", got.Tags)
+		}
+		if _, err := repo.GetBySlug(ctx, "created"); !errors.Is(err, domain.ErrPostNotFound) {
+			t.Errorf("Expected unpublished post to be hidden, got %v", err)
+		}
+
+		if err := repo.Create(ctx, &domain.Post{Slug: "created", CreatedAt: now, UpdatedAt: now}); !errors.Is(err, domain.ErrSlugExists) {
+			t.Errorf("Expected ErrSlugExists, got %v", err)
+		}
+	})
+
+	t.Run("Create stores the outline so reads need not render", func(t *testing.T) {
+		post := &domain.Post{
+			Title:     "Outlined",
+			Slug:      "outlined",
+			Content:   "Intro text here.\n\n## Setup\n\nMore words.",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := repo.Create(ctx, post); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		// A repository without a renderer can still read every field
+		reader := NewSQLPostRepository(db, dialect, nil, false)
+		got, err := reader.GetBySlugIncludingDrafts(ctx, "outlined")
+		if err != nil {
+			t.Fatalf("GetBySlugIncludingDrafts() error = %v", err)
+		}
+		if len(got.TOC) != 1 || got.TOC[0].ID != "setup" || got.TOC[0].Text != "Setup" {
+			t.Errorf("Expected a Setup entry in the table of contents, got %+v", got.TOC)
+		}
+		if got.WordCount != 6 || got.ReadingTime != 1 || !strings.HasPrefix(got.Summary, "<p>Intro text here.</p>") {
+			t.Errorf("Unexpected outline: %d words, %d minutes, summary %q", got.WordCount, got.ReadingTime, got.Summary)
+		}
+
+		if err := repo.Delete(ctx, "outlined"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	})
+
+	t.Run("Update replaces fields and tags", func(t *testing.T) {
+		post, _ := repo.GetBySlugIncludingDrafts(ctx, "created")
+		post.Title = "Updated"
+		post.Content = "Plain"
+		post.Tags = []string{"observability"}
+		post.Series = "SQL"
+		post.SeriesOrder = 2
+		post.UpdatedAt = now.Add(time.Hour)
+		if err := repo.Update(ctx, "created", post); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		got, _ := repo.GetBySlugIncludingDrafts(ctx, "created")
+		if got.Title != "Updated" || got.Content != "<p>Plain</p>\n" || len(got.Tags) != 1 {
+			t.Errorf("Unexpected post after update: %+v", got)
+		}
+		if got.Series != "SQL" || got.SeriesOrder != 2 {
+			t.Errorf("Expected series SQL part 2, got %q part %d", got.Series, got.SeriesOrder)
+		}
+
+		post.Slug = "older-post"
+		if err := repo.Update(ctx, "created", post); !errors.Is(err, domain.ErrSlugExists) {
+			t.Errorf("Expected ErrSlugExists when renaming onto another post, got %v", err)
+		}
+		if err := repo.Update(ctx, "missing", post); !errors.Is(err, domain.ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Publish makes drafts and scheduled posts visible now", func(t *testing.T) {
+		for _, slug := range []string{"created", "scheduled-post"} {
+			if err := repo.Publish(ctx, slug); err != nil {
+				t.Fatalf("Publish(%s) error = %v", slug, err)
+			}
+			post, err := repo.GetBySlug(ctx, slug)
+			if err != nil {
+				t.Fatalf("GetBySlug(%s) error = %v", slug, err)
+			}
+			if !post.CreatedAt.Equal(now) {
+				t.Errorf("Expected %s to be redated to %v, got %v", slug, now, post.CreatedAt)
+			}
+		}
+		if err := repo.Publish(ctx, "missing"); !errors.Is(err, domain.ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Delete removes the post and its tags", func(t *testing.T) {
+		if err := repo.Delete(ctx, "created"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if err := repo.Delete(ctx, "created"); !errors.Is(err, domain.ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got %v", err)
+		}
+
+		var tags int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM post_tags WHERE name = 'observability'").Scan(&tags); err != nil {
+			t.Fatal(err)
+		}
+		if tags != 1 {
+			t.Errorf("Expected the deleted post's tags to be removed, %d observability tags left", tags)
+		}
+
+		posts, err := repo.GetAllIncludingDrafts(ctx)
+		if err != nil {
+			t.Fatalf("GetAllIncludingDrafts() error = %v", err)
+		}
+		if len(posts) != 4 {
+			t.Errorf("Expected the 4 seeded posts, got %d", len(posts))
+		}
+	})
+}
+
+func TestSQLPostRepositoryRelated(t *testing.T) {
+	db, dialect := setupSQLite(t)
+	repo := NewSQLPostRepository(db, dialect, markdown.New(markdown.DefaultExtensions), false)
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	create := func(slug, content string, tags, related []string) {
+		t.Helper()
+		post := &domain.Post{
+			Title:     "Title " + slug,
+			Slug:      slug,
+			Content:   content,
+			Tags:      tags,
+			Related:   related,
+			Published: true,
+			CreatedAt: now.Add(-time.Hour),
+			UpdatedAt: now.Add(-time.Hour),
+		}
+		if err := repo.Create(ctx, post); err != nil {
+			t.Fatalf("Create(%s) error = %v", slug, err)
+		}
+	}
+	create("metrics", "Counters and gauges for services.", []string{"observability"}, []string{"missing", "draft-post", "older-post"})
+	create("tracing", "Spans across services.", []string{"observability"}, nil)
+
+	t.Run("Pins come first and are stored in order", func(t *testing.T) {
+		post, err := repo.GetBySlug(ctx, "metrics")
+		if err != nil {
+			t.Fatalf("GetBySlug() error = %v", err)
+		}
+		if len(post.Related) != 3 || post.Related[0] != "missing" || post.Related[2] != "older-post" {
+			t.Errorf("Expected the pins to be read back in order, got %v", post.Related)
+		}
+
+		related, err := repo.Related(ctx, "metrics", 2)
+		if err != nil {
+			t.Fatalf("Related() error = %v", err)
+		}
+		// Missing and draft pins are skipped
+		if len(related) != 2 || related[0].Slug != "older-post" || related[1].Slug != "tracing" {
+			t.Errorf("Expected [older-post tracing], got %v", slugs(related))
+		}
+	})
+
+	t.Run("Writes rebuild the ranking", func(t *testing.T) {
+		related, _ := repo.Related(ctx, "tracing", 1)
+		if len(related) != 1 || related[0].Slug != "metrics" {
+			t.Fatalf("Expected [metrics], got %v", slugs(related))
+		}
+
+		create("spans", "Spans across services, with tracing.", []string{"observability"}, nil)
+		related, _ = repo.Related(ctx, "tracing", 1)
+		if len(related) != 1 || related[0].Slug != "spans" {
+			t.Errorf("Expected the new post to rank first, got %v", slugs(related))
+		}
+	})
+
+	t.Run("Unknown and hidden posts are not found", func(t *testing.T) {
+		for _, slug := range []string{"missing", "draft-post"} {
+			if _, err := repo.Related(ctx, slug, 3); !errors.Is(err, domain.ErrPostNotFound) {
+				t.Errorf("Related(%s) expected ErrPostNotFound, got %v", slug, err)
+			}
+		}
+	})
+}
+
+func slugs(posts []*domain.Post) []string {
+	out := make([]string, len(posts))
+	for i, p := range posts {
+		out[i] = p.Slug
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/seanankenbruck/blog/internal/database"
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// userColumns are the users columns read into a domain.User, in scan order
+const userColumns = "id, username, password_hash, session_version, created_at, updated_at"
+
+// SQLUserRepository implements domain.UserRepository on a SQL database
+type SQLUserRepository struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+// NewSQLUserRepository creates a new SQLUserRepository. The schema must
+// already be migrated with database.Migrate.
+func NewSQLUserRepository(db *sql.DB, dialect database.Dialect) *SQLUserRepository {
+	return &SQLUserRepository{db: db, dialect: dialect}
+}
+
+// GetByUsername retrieves a user by username
+func (r *SQLUserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	row := r.db.QueryRowContext(ctx,
+		r.dialect.Rebind("SELECT "+userColumns+" FROM users WHERE username = ?"), username)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, err
+}
+
+// GetAll retrieves all users ordered by username
+func (r *SQLUserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Create inserts a user, assigning its ID. It returns domain.ErrUserExists
+// if the username is taken.
+func (r *SQLUserRepository) Create(ctx context.Context, user *domain.User) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, r.dialect.Rebind("SELECT 1 FROM users WHERE username = ?"), user.Username).Scan(&exists)
+	if err == nil {
+		return domain.ErrUserExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, r.dialect.Rebind(
+		"INSERT INTO users (username, password_hash, session_version, created_at, updated_at) VALUES (?, ?, ?, ?, ?) RETURNING id"),
+		user.Username, user.PasswordHash, user.SessionVersion, user.CreatedAt.UTC(), user.UpdatedAt.UTC(),
+	).Scan(&id); err != nil {
+		return err
+	}
+	user.ID = uint(id)
+
+	return tx.Commit()
+}
+
+// Update saves a user's password hash and session version
+func (r *SQLUserRepository) Update(ctx context.Context, user *domain.User) error {
+	res, err := r.db.ExecContext(ctx, r.dialect.Rebind(
+		"UPDATE users SET password_hash = ?, session_version = ?, updated_at = ? WHERE username = ?"),
+		user.PasswordHash, user.SessionVersion, user.UpdatedAt.UTC(), user.Username,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func scanUser(s scanner) (*domain.User, error) {
+	var user domain.User
+	var id int64
+	if err := s.Scan(&id, &user.Username, &user.PasswordHash, &user.SessionVersion, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	user.ID = uint(id)
+	return &user, nil
+}
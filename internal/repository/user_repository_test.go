@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+func TestUserRepositories(t *testing.T) {
+	db, dialect := setupSQLite(t)
+	usersFile := filepath.Join(t.TempDir(), "users.json")
+
+	repos := map[string]domain.UserRepository{
+		"file": NewFileUserRepository(usersFile),
+		"sql":  NewSQLUserRepository(db, dialect),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			users, err := repo.GetAll(ctx)
+			if err != nil || len(users) != 0 {
+				t.Fatalf("GetAll() = %v, %v; want no users", users, err)
+			}
+			if _, err := repo.GetByUsername(ctx, "admin"); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("GetByUsername() expected ErrUserNotFound, got %v", err)
+			}
+
+			now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+			for _, username := range []string{"zoe", "admin"} {
+				user := &domain.User{Username: username, PasswordHash: "hash-" + username, CreatedAt: now, UpdatedAt: now}
+				if err := repo.Create(ctx, user); err != nil {
+					t.Fatalf("Create(%s) error = %v", username, err)
+				}
+				if user.ID == 0 {
+					t.Errorf("Expected Create(%s) to assign an ID", username)
+				}
+			}
+			if err := repo.Create(ctx, &domain.User{Username: "admin", PasswordHash: "x", CreatedAt: now, UpdatedAt: now}); !errors.Is(err, domain.ErrUserExists) {
+				t.Errorf("Create() expected ErrUserExists, got %v", err)
+			}
+
+			user, err := repo.GetByUsername(ctx, "admin")
+			if err != nil {
+				t.Fatalf("GetByUsername() error = %v", err)
+			}
+			if user.PasswordHash != "hash-admin" || !user.CreatedAt.Equal(now) {
+				t.Errorf("Unexpected user %+v", user)
+			}
+
+			user.PasswordHash = "new-hash"
+			user.SessionVersion = 3
+			if err := repo.Update(ctx, user); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if user, err = repo.GetByUsername(ctx, "admin"); err != nil || user.PasswordHash != "new-hash" || user.SessionVersion != 3 {
+				t.Errorf("Expected the update to be saved, got %+v, %v", user, err)
+			}
+			if err := repo.Update(ctx, &domain.User{Username: "nobody"}); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("Update() expected ErrUserNotFound, got %v", err)
+			}
+
+			users, err = repo.GetAll(ctx)
+			if err != nil {
+				t.Fatalf("GetAll() error = %v", err)
+			}
+			if len(users) != 2 || users[0].Username != "admin" || users[1].Username != "zoe" {
+				t.Errorf("Expected [admin zoe], got %v", users)
+			}
+		})
+	}
+
+	info, err := os.Stat(usersFile)
+	if err != nil {
+		t.Fatalf("Expected users file to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected users file mode 0600, got %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,66 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are common English words left out of the index
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a about above after again against all am an and any are as at
+		be because been before being below between both but by can could did do does doing down during
+		each few for from further had has have having he her here hers herself him himself his how i if
+		in into is it its itself just me more most my myself no nor not now of off on once only or other
+		our ours ourselves out over own same she should so some such than that the their theirs them
+		themselves then there these they this those through to too under until up very was we were what
+		when where which while who whom why will with would you your yours yourself yourselves`) {
+		stopWords[w] = true
+	}
+}
+
+// Analyze splits text into lowercase words, drops stop words and stems the
+// rest. Queries and documents go through the same analysis so their terms match.
+func Analyze(text string) []string {
+	var terms []string
+	for _, word := range words(text) {
+		if term, ok := analyzeWord(word); ok {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// analyzeWord returns the index term for a single word, or false if the word
+// is not indexed
+func analyzeWord(word string) (string, bool) {
+	word = strings.ToLower(word)
+	if stopWords[word] {
+		return "", false
+	}
+	return Stem(word), true
+}
+
+// words splits text on anything that is not a letter or digit. Apostrophes
+// inside a word are dropped so "don't" becomes "dont".
+func words(text string) []string {
+	var out []string
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’':
+		default:
+			if b.Len() > 0 {
+				out = append(out, b.String())
+				b.Reset()
+			}
+		}
+	}
+	if b.Len() > 0 {
+		out = append(out, b.String())
+	}
+	return out
+}
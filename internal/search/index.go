@@ -0,0 +1,167 @@
+package search
+
+import (
+	"math"
+	"sort"
+)
+
+// BM25 parameters: k1 controls term frequency saturation and b how strongly
+// scores are normalised by field length
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// field identifies the part of a document a term occurred in
+type field int
+
+const (
+	fieldTitle field = iota
+	fieldDescription
+	fieldTags
+	fieldBody
+	numFields
+)
+
+// fieldWeights boosts matches in the title, description and tags over the body
+var fieldWeights = [numFields]float64{
+	fieldTitle:       3,
+	fieldDescription: 2,
+	fieldTags:        2,
+	fieldBody:        1,
+}
+
+// Document is a post to index
+type Document struct {
+	ID          string // Returned in hits, e.g. the post slug
+	Title       string
+	Description string
+	Tags        []string
+	Body        string // Markdown source
+}
+
+// Hit is a document matching a query
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// posting records how often a term occurs in each field of one document
+type posting struct {
+	doc int
+	tf  [numFields]int
+}
+
+// Index is an immutable in-memory inverted index ranked with BM25F
+type Index struct {
+	ids      []string
+	lengths  [][numFields]int
+	avgLen   [numFields]float64
+	postings map[string][]posting
+}
+
+// NewIndex builds an index over docs
+func NewIndex(docs []Document) *Index {
+	ix := &Index{
+		ids:      make([]string, len(docs)),
+		lengths:  make([][numFields]int, len(docs)),
+		postings: make(map[string][]posting),
+	}
+
+	var total [numFields]int
+	for i, d := range docs {
+		ix.ids[i] = d.ID
+
+		tfs := make(map[string]*posting)
+		add := func(f field, text string) {
+			for _, term := range Analyze(text) {
+				p, ok := tfs[term]
+				if !ok {
+					p = &posting{doc: i}
+					tfs[term] = p
+				}
+				p.tf[f]++
+				ix.lengths[i][f]++
+				total[f]++
+			}
+		}
+		add(fieldTitle, d.Title)
+		add(fieldDescription, d.Description)
+		for _, tag := range d.Tags {
+			add(fieldTags, tag)
+		}
+		add(fieldBody, d.Body)
+
+		for term, p := range tfs {
+			ix.postings[term] = append(ix.postings[term], *p)
+		}
+	}
+
+	if len(docs) > 0 {
+		for f := range total {
+			ix.avgLen[f] = float64(total[f]) / float64(len(docs))
+		}
+	}
+
+	return ix
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	return len(ix.ids)
+}
+
+// Search returns the documents matching any term of query, best first.
+// Documents for which keep returns false are skipped; a nil keep keeps all.
+func (ix *Index) Search(query string, keep func(id string) bool) []Hit {
+	scores := make(map[int]float64)
+	n := float64(len(ix.ids))
+
+	seen := make(map[string]bool)
+	for _, term := range Analyze(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := ix.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for _, p := range postings {
+			// BM25F: combine length-normalised field frequencies before saturation
+			var tf float64
+			for f := field(0); f < numFields; f++ {
+				if p.tf[f] == 0 {
+					continue
+				}
+				norm := 1 - b
+				if ix.avgLen[f] > 0 {
+					norm += b * float64(ix.lengths[p.doc][f]) / ix.avgLen[f]
+				}
+				tf += fieldWeights[f] * float64(p.tf[f]) / norm
+			}
+			scores[p.doc] += idf * tf / (k1 + tf)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for doc, score := range scores {
+		if keep != nil && !keep(ix.ids[doc]) {
+			continue
+		}
+		hits = append(hits, Hit{ID: ix.ids[doc], Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	return hits
+}
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// relatedTagWeight scales the tag overlap of two documents, between 0 and 1,
+// against the cosine similarity of their text, also between 0 and 1
+const relatedTagWeight = 1.0
+
+// Related holds, for every document, the other documents ranked by how
+// similar they are: the Jaccard overlap of their tags plus the TF-IDF cosine
+// similarity of their title, description and body.
+type Related struct {
+	ranked map[string][]Hit
+}
+
+// NewRelated ranks every pair of docs. The cost is quadratic in the number
+// of documents, which is fine for a blog but not for a large corpus.
+func NewRelated(docs []Document) *Related {
+	vectors := make([]map[string]float64, len(docs))
+	tags := make([]map[string]bool, len(docs))
+	df := make(map[string]int)
+
+	for i, d := range docs {
+		tf := make(map[string]float64)
+		for _, text := range []string{d.Title, d.Description, d.Body} {
+			for _, term := range Analyze(text) {
+				tf[term]++
+			}
+		}
+		for term := range tf {
+			df[term]++
+		}
+		vectors[i] = tf
+
+		tags[i] = make(map[string]bool, len(d.Tags))
+		for _, tag := range d.Tags {
+			if key := tagKey(tag); key != "" {
+				tags[i][key] = true
+			}
+		}
+	}
+
+	// Weight terms by log-scaled frequency and inverse document frequency,
+	// then normalise so the dot product of two vectors is their cosine
+	n := float64(len(docs))
+	for _, vec := range vectors {
+		var norm float64
+		for term, tf := range vec {
+			w := (1 + math.Log(tf)) * math.Log(n/float64(df[term]))
+			vec[term] = w
+			norm += w * w
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for term := range vec {
+				vec[term] /= norm
+			}
+		}
+	}
+
+	r := &Related{ranked: make(map[string][]Hit, len(docs))}
+	for i := range docs {
+		for j := i + 1; j < len(docs); j++ {
+			score := relatedTagWeight*jaccard(tags[i], tags[j]) + dot(vectors[i], vectors[j])
+			if score <= 0 {
+				continue
+			}
+			r.ranked[docs[i].ID] = append(r.ranked[docs[i].ID], Hit{ID: docs[j].ID, Score: score})
+			r.ranked[docs[j].ID] = append(r.ranked[docs[j].ID], Hit{ID: docs[i].ID, Score: score})
+		}
+	}
+	for _, hits := range r.ranked {
+		sort.Slice(hits, func(i, j int) bool {
+			if hits[i].Score != hits[j].Score {
+				return hits[i].Score > hits[j].Score
+			}
+			return hits[i].ID < hits[j].ID
+		})
+	}
+	return r
+}
+
+// Ranked returns the documents related to id, most similar first. Documents
+// with no tags or terms in common are left out.
+func (r *Related) Ranked(id string) []Hit {
+	return r.ranked[id]
+}
+
+// tagKey normalises a tag the way domain.TagSlug does, so "Go" and "go" or
+// "Software Development" and "software-development" count as the same tag
+func tagKey(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(tag, "-", " "))), "-")
+}
+
+// jaccard returns the size of the intersection of a and b over their union
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for key := range a {
+		if b[key] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// dot returns the dot product of two sparse vectors
+func dot(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var sum float64
+	for term, w := range a {
+		sum += w * b[term]
+	}
+	return sum
+}
@@ -0,0 +1,193 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"hopping":        "hop",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"digitizer":      "digit",
+		"hopefulness":    "hope",
+		"electrical":     "electr",
+		"adjustment":     "adjust",
+		"adoption":       "adopt",
+		"controlling":    "control",
+		"generalization": "gener",
+		"connections":    "connect",
+		"connected":      "connect",
+		"go":             "go",
+		"k8s":            "k8s",
+	}
+
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	got := Analyze("The Observability of Go's running services, don't panic!")
+	want := []string{"observ", "go", "run", "servic", "dont", "panic"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Analyze() = %v, want %v", got, want)
+	}
+}
+
+func testDocs() []Document {
+	return []Document{
+		{
+			ID:    "tracing",
+			Title: "Distributed tracing with OpenTelemetry",
+			Tags:  []string{"observability"},
+			Body:  "Traces show how a request moves through services.",
+		},
+		{
+			ID:          "metrics",
+			Title:       "Storing metrics in ClickHouse",
+			Description: "A metrics backend",
+			Body:        "Metrics and traces are both telemetry. " + strings.Repeat("Columns and compression. ", 50),
+		},
+		{
+			ID:    "kubernetes",
+			Title: "Running Kubernetes at home",
+			Body:  "A small cluster for side projects.",
+		},
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := NewIndex(testDocs())
+	if ix.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", ix.Len())
+	}
+
+	t.Run("Title match outranks long body match", func(t *testing.T) {
+		hits := ix.Search("trace", nil)
+		if len(hits) != 2 {
+			t.Fatalf("Expected 2 hits, got %v", hits)
+		}
+		if hits[0].ID != "tracing" || hits[1].ID != "metrics" {
+			t.Errorf("Expected [tracing metrics], got %v", hits)
+		}
+		if hits[0].Score <= hits[1].Score {
+			t.Errorf("Expected descending scores, got %v", hits)
+		}
+	})
+
+	t.Run("Tags are searchable", func(t *testing.T) {
+		hits := ix.Search("observability", nil)
+		if len(hits) != 1 || hits[0].ID != "tracing" {
+			t.Errorf("Expected [tracing], got %v", hits)
+		}
+	})
+
+	t.Run("Stop words match nothing", func(t *testing.T) {
+		if hits := ix.Search("the and a", nil); len(hits) != 0 {
+			t.Errorf("Expected no hits, got %v", hits)
+		}
+	})
+
+	t.Run("Filter drops documents", func(t *testing.T) {
+		hits := ix.Search("traces", func(id string) bool { return id != "tracing" })
+		if len(hits) != 1 || hits[0].ID != "metrics" {
+			t.Errorf("Expected [metrics], got %v", hits)
+		}
+	})
+
+	t.Run("Rarer terms weigh more", func(t *testing.T) {
+		hits := ix.Search("kubernetes telemetry", nil)
+		if len(hits) != 2 {
+			t.Fatalf("Expected 2 hits, got %v", hits)
+		}
+		if hits[0].ID != "kubernetes" {
+			t.Errorf("Expected the title match first, got %v", hits)
+		}
+	})
+}
+
+func TestPlainText(t *testing.T) {
+	got := PlainText("<h1 id=\"x\">Title</h1>\n<p>Fish &amp; chips</p><script>alert(1)</script>")
+	if got != "Title Fish & chips" {
+		t.Errorf("PlainText() = %q", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	t.Run("Highlights stemmed matches", func(t *testing.T) {
+		got := Snippet("Tracing <b> requests and the traces they leave.", "trace", 200)
+		want := "<mark>Tracing</mark> &lt;b&gt; requests and the <mark>traces</mark> they leave."
+		if got != want {
+			t.Errorf("Snippet() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Centres on first match in long text", func(t *testing.T) {
+		text := strings.Repeat("filler words here ", 40) + "the clickhouse backend " + strings.Repeat("more words ", 40)
+		got := Snippet(text, "ClickHouse", 80)
+		if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+			t.Errorf("Expected ellipses on both ends, got %q", got)
+		}
+		if !strings.Contains(got, "<mark>clickhouse</mark>") {
+			t.Errorf("Expected highlighted match, got %q", got)
+		}
+		if len(got) > 120 {
+			t.Errorf("Expected a short snippet, got %d bytes", len(got))
+		}
+	})
+
+	t.Run("Falls back to the start of the text", func(t *testing.T) {
+		got := Snippet("No match in this text at all.", "kubernetes", 10)
+		if got != "No match in…" {
+			t.Errorf("Snippet() = %q", got)
+		}
+	})
+}
+
+func TestRelated(t *testing.T) {
+	docs := []Document{
+		{ID: "clickhouse", Title: "ClickHouse for metrics", Tags: []string{"Observability", "Databases"},
+			Body: "Columnar storage compresses metrics and aggregates time series quickly."},
+		{ID: "prometheus", Title: "Scaling Prometheus", Tags: []string{"observability"},
+			Body: "Prometheus stores metrics as time series and struggles with high cardinality."},
+		{ID: "postgres", Title: "Postgres indexes", Tags: []string{"Databases"},
+			Body: "B-tree indexes speed up lookups in Postgres tables."},
+		{ID: "sourdough", Title: "Baking sourdough",
+			Body: "Flour, water and patience make good bread."},
+	}
+	related := NewRelated(docs)
+
+	hits := related.Ranked("clickhouse")
+	if len(hits) != 2 || hits[0].ID != "prometheus" || hits[1].ID != "postgres" {
+		t.Errorf("Ranked(clickhouse) = %+v, want prometheus then postgres", hits)
+	}
+	if hits := related.Ranked("sourdough"); len(hits) != 0 {
+		t.Errorf("Ranked(sourdough) = %+v, want nothing in common", hits)
+	}
+	if hits := related.Ranked("missing"); len(hits) != 0 {
+		t.Errorf("Ranked(missing) = %+v", hits)
+	}
+
+	// Similarity is symmetric
+	var fromPrometheus float64
+	for _, hit := range related.Ranked("prometheus") {
+		if hit.ID == "clickhouse" {
+			fromPrometheus = hit.Score
+		}
+	}
+	if fromPrometheus != hits[0].Score {
+		t.Errorf("Expected symmetric scores, got %v and %v", hits[0].Score, fromPrometheus)
+	}
+}
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultSnippetLength is the approximate number of characters in a snippet
+const DefaultSnippetLength = 200
+
+var (
+	tagPattern        = regexp.MustCompile(`(?s)<[^>]*>`)
+	scriptPattern     = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// PlainText strips the tags from rendered HTML and collapses whitespace,
+// giving text suitable for snippets
+func PlainText(htmlText string) string {
+	text := scriptPattern.ReplaceAllString(htmlText, " ")
+	text = tagPattern.ReplaceAllString(text, " ")
+	text = html.UnescapeString(text)
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " "))
+}
+
+// Snippet returns an HTML-escaped excerpt of text around the first word
+// matching query, with every matching word wrapped in <mark>. If nothing
+// matches, the start of the text is used.
+func Snippet(text, query string, length int) string {
+	terms := make(map[string]bool)
+	for _, term := range Analyze(query) {
+		terms[term] = true
+	}
+
+	spans := matchSpans(text, terms)
+
+	start := 0
+	if len(spans) > 0 {
+		// Show some context before the first match
+		start = wordStart(text, spans[0][0]-length/4)
+	}
+	end := start + length
+	if end >= len(text) {
+		end = len(text)
+	} else {
+		end = wordEnd(text, end)
+	}
+
+	var out strings.Builder
+	if start > 0 {
+		out.WriteString("…")
+	}
+	pos := start
+	for _, span := range spans {
+		if span[0] < start || span[1] > end {
+			continue
+		}
+		out.WriteString(html.EscapeString(text[pos:span[0]]))
+		out.WriteString("<mark>")
+		out.WriteString(html.EscapeString(text[span[0]:span[1]]))
+		out.WriteString("</mark>")
+		pos = span[1]
+	}
+	out.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		out.WriteString("…")
+	}
+	return out.String()
+}
+
+// matchSpans returns the byte ranges of the words in text whose index term
+// is in terms
+func matchSpans(text string, terms map[string]bool) [][2]int {
+	var spans [][2]int
+	wordStart := -1
+	flush := func(end int) {
+		if wordStart < 0 {
+			return
+		}
+		word := strings.NewReplacer("'", "", "’", "").Replace(text[wordStart:end])
+		if term, ok := analyzeWord(word); ok && terms[term] {
+			spans = append(spans, [2]int{wordStart, end})
+		}
+		wordStart = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || ((r == '\'' || r == '’') && wordStart >= 0) {
+			if wordStart < 0 {
+				wordStart = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return spans
+}
+
+// wordStart moves i back to the start of the word containing it
+func wordStart(text string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	for i > 0 && text[i-1] != ' ' {
+		i--
+	}
+	return i
+}
+
+// wordEnd moves i forward to the end of the word containing it
+func wordEnd(text string, i int) int {
+	for i < len(text) && text[i] != ' ' {
+		i++
+	}
+	return i
+}
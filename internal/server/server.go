@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/config"
+)
+
+// New creates an http.Server for handler with the timeouts and header
+// limit from cfg
+func New(handler http.Handler, cfg *config.Config) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// Readiness reports whether the server should be sent traffic. It starts
+// ready and stays unready once draining starts.
+type Readiness struct {
+	draining atomic.Bool
+}
+
+// Ready reports whether the server is accepting new traffic
+func (r *Readiness) Ready() bool {
+	return !r.draining.Load()
+}
+
+// Drain marks the server unready
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// Serve serves srv on ln until ctx is cancelled, then shuts down in two
+// steps. First readiness fails for delay, long enough for load balancers
+// and Kubernetes readiness probes to stop sending new requests, while
+// requests still arriving are served. Then the listener closes and
+// in-flight requests get up to timeout to finish before their connections
+// are closed.
+//
+// Serve returns nil after a clean shutdown, or the error that stopped the
+// server.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, ready *Readiness, delay, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down: failing readiness for %v before draining", delay)
+	ready.Drain()
+	select {
+	case <-time.After(delay):
+	case err := <-errc:
+		return err
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("requests still running after %v: %w", timeout, err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("Server stopped")
+	return nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowServer returns a server whose requests signal started, then block
+// until release is closed
+func slowServer(started chan<- struct{}, release <-chan struct{}) *http.Server {
+	return &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		io.WriteString(w, "done")
+	})}
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.Default()
+	cfg.ServerPort = "9090"
+	srv := New(http.NotFoundHandler(), cfg)
+
+	assert.Equal(t, ":9090", srv.Addr)
+	assert.Equal(t, cfg.ReadHeaderTimeout, srv.ReadHeaderTimeout)
+	assert.Equal(t, cfg.WriteTimeout, srv.WriteTimeout)
+	assert.Equal(t, cfg.IdleTimeout, srv.IdleTimeout)
+	assert.Equal(t, cfg.MaxHeaderBytes, srv.MaxHeaderBytes)
+}
+
+func TestServeDrains(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	ln := listen(t)
+	ready := &Readiness{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, slowServer(started, release), ln, ready, 50*time.Millisecond, 5*time.Second)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+	assert.True(t, ready.Ready())
+
+	// Readiness fails as soon as shutdown starts, while the request runs on
+	cancel()
+	assert.Eventually(t, func() bool { return !ready.Ready() }, time.Second, time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("Serve() returned %v with a request in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, "done", <-body)
+	assert.NoError(t, <-done)
+}
+
+func TestServeTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, slowServer(started, release), ln, &Readiness{}, 0, 50*time.Millisecond)
+	}()
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not give up on the stuck request")
+	}
+}
+
+func TestServeError(t *testing.T) {
+	ln := listen(t)
+	ln.Close()
+
+	// A server that fails by itself returns without waiting for ctx
+	err := Serve(context.Background(), &http.Server{}, ln, &Readiness{}, time.Second, time.Second)
+	assert.Error(t, err)
+}
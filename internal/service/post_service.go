@@ -2,9 +2,14 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/seanankenbruck/blog/internal/domain"
+	"github.com/seanankenbruck/blog/internal/search"
 )
 
 // postService implements domain.PostService
@@ -58,3 +63,433 @@ func (s *postService) GetAllPosts(ctx context.Context) ([]*domain.Post, error) {
 	}
 }
 
+func (s *postService) ListPosts(ctx context.Context, q domain.PostQuery) (*domain.PostPage, error) {
+	log.Printf("Listing posts: %+v", q)
+	if q.Sort == "" {
+		q.Sort = domain.SortNewest
+	}
+	if q.PerPage == 0 {
+		q.PerPage = domain.DefaultPerPage
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+
+	switch {
+	case !q.Sort.Valid():
+		return nil, fmt.Errorf("%w: unknown sort %q", domain.ErrInvalidQuery, q.Sort)
+	case q.PerPage < 0 || q.PerPage > domain.MaxPerPage:
+		return nil, fmt.Errorf("%w: per_page must be between 1 and %d", domain.ErrInvalidQuery, domain.MaxPerPage)
+	case q.Page < 0:
+		return nil, fmt.Errorf("%w: page must be positive", domain.ErrInvalidQuery)
+	case !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From):
+		return nil, fmt.Errorf("%w: to is before from", domain.ErrInvalidQuery)
+	}
+
+	var after *domain.Post
+	if q.Cursor != "" {
+		var err error
+		if after, err = decodeCursor(q.Cursor, q.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	var posts []*domain.Post
+	var err error
+	if q.IncludeDrafts {
+		posts, err = s.repo.GetAllIncludingDrafts(ctx)
+	} else {
+		posts, err = s.GetAllPosts(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tag := domain.TagSlug(q.Tag)
+	matched := make([]*domain.Post, 0, len(posts))
+	for _, p := range posts {
+		if tag != "" && !p.HasTag(tag) {
+			continue
+		}
+		if !q.From.IsZero() && p.CreatedAt.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && p.CreatedAt.After(q.To) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+
+	less := postLess(q.Sort)
+	sort.SliceStable(matched, func(i, j int) bool {
+		return less(matched[i], matched[j])
+	})
+
+	page := &domain.PostPage{
+		Total:      len(matched),
+		PerPage:    q.PerPage,
+		TotalPages: (len(matched) + q.PerPage - 1) / q.PerPage,
+	}
+
+	var start int
+	if after != nil {
+		// Continue with the first post ordered after the cursor
+		start = sort.Search(len(matched), func(i int) bool {
+			return less(after, matched[i])
+		})
+	} else {
+		page.Page = q.Page
+		start = (q.Page - 1) * q.PerPage
+	}
+
+	start = min(start, len(matched))
+	end := min(start+q.PerPage, len(matched))
+	page.Posts = matched[start:end]
+	if end < len(matched) {
+		page.NextCursor = encodeCursor(q.Sort, matched[end-1])
+	}
+
+	return page, nil
+}
+
+func (s *postService) GetTags(ctx context.Context) ([]*domain.Tag, error) {
+	log.Println("Getting all tags")
+	posts, err := s.GetAllPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tagsBySlug := make(map[string]*domain.Tag)
+	tags := make([]*domain.Tag, 0)
+	for _, p := range posts {
+		seen := make(map[string]bool)
+		for _, name := range p.Tags {
+			slug := domain.TagSlug(name)
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+
+			tag, ok := tagsBySlug[slug]
+			if !ok {
+				tag = &domain.Tag{Name: name, Slug: slug}
+				tagsBySlug[slug] = tag
+				tags = append(tags, tag)
+			}
+			tag.Count++
+		}
+	}
+
+	// Most used tags first, then alphabetically
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Slug < tags[j].Slug
+	})
+
+	return tags, nil
+}
+
+func (s *postService) GetPostsByTag(ctx context.Context, tagSlug string) (*domain.Tag, []*domain.Post, error) {
+	log.Printf("Getting posts with tag: %s", tagSlug)
+	posts, err := s.GetAllPosts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slug := domain.TagSlug(tagSlug)
+	var tag *domain.Tag
+	tagged := make([]*domain.Post, 0)
+	for _, p := range posts {
+		if !p.HasTag(slug) {
+			continue
+		}
+		if tag == nil {
+			tag = &domain.Tag{Name: tagName(p.Tags, slug), Slug: slug}
+		}
+		tag.Count++
+		tagged = append(tagged, p)
+	}
+
+	if tag == nil {
+		return nil, nil, domain.ErrTagNotFound
+	}
+	return tag, tagged, nil
+}
+
+func (s *postService) GetSeries(ctx context.Context, seriesSlug string) (*domain.Series, error) {
+	log.Printf("Getting series: %s", seriesSlug)
+	posts, err := s.GetAllPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	series := seriesOf(posts, domain.SeriesSlug(seriesSlug))
+	if series == nil {
+		return nil, domain.ErrSeriesNotFound
+	}
+	return series, nil
+}
+
+func (s *postService) GetPostNavigation(ctx context.Context, post *domain.Post) (*domain.PostNavigation, error) {
+	posts, err := s.GetAllPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nav := &domain.PostNavigation{}
+	if slug := domain.SeriesSlug(post.Series); slug != "" {
+		if series := seriesOf(posts, slug); series != nil {
+			for i, p := range series.Posts {
+				if p.Slug != post.Slug {
+					continue
+				}
+				nav.Series = series
+				nav.Part = i + 1
+				if i > 0 {
+					nav.Prev = series.Posts[i-1]
+				}
+				if i < len(series.Posts)-1 {
+					nav.Next = series.Posts[i+1]
+				}
+				return nav, nil
+			}
+		}
+	}
+
+	// Otherwise link to the neighbouring posts by date, newest first as the
+	// repositories list them, so the previous post is the next one in the list
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].CreatedAt.After(posts[j].CreatedAt)
+	})
+	for i, p := range posts {
+		if p.Slug != post.Slug {
+			continue
+		}
+		if i < len(posts)-1 {
+			nav.Prev = posts[i+1]
+		}
+		if i > 0 {
+			nav.Next = posts[i-1]
+		}
+		break
+	}
+	return nav, nil
+}
+
+func (s *postService) GetRelatedPosts(ctx context.Context, slug string, limit int) ([]*domain.Post, error) {
+	if finder, ok := s.repo.(domain.RelatedPostFinder); ok {
+		return finder.Related(ctx, slug, limit)
+	}
+
+	// Rank on demand for repositories that don't keep a ranking
+	posts, err := s.GetAllPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, postsBySlug := searchDocuments(posts)
+	if _, ok := postsBySlug[slug]; !ok {
+		return nil, domain.ErrPostNotFound
+	}
+
+	related := make([]*domain.Post, 0)
+	for _, hit := range search.NewRelated(docs).Ranked(slug) {
+		if len(related) >= limit {
+			break
+		}
+		related = append(related, postsBySlug[hit.ID])
+	}
+	return related, nil
+}
+
+func (s *postService) Search(ctx context.Context, query string) ([]*domain.SearchResult, error) {
+	log.Printf("Searching posts for: %q", query)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []*domain.SearchResult{}, nil
+	}
+
+	var results []*domain.SearchResult
+	var err error
+	if searcher, ok := s.repo.(domain.PostSearcher); ok {
+		results, err = searcher.Search(ctx, query)
+	} else {
+		results, err = s.scan(ctx, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		if r.Snippet == "" {
+			r.Snippet = search.Snippet(search.PlainText(r.Post.Content), query, search.DefaultSnippetLength)
+		}
+	}
+
+	return results, nil
+}
+
+func (s *postService) GetPostBySlugIncludingDrafts(ctx context.Context, slug string) (*domain.Post, error) {
+	log.Printf("Getting post or draft with slug: %s", slug)
+	return s.repo.GetBySlugIncludingDrafts(ctx, slug)
+}
+
+func (s *postService) CreatePost(ctx context.Context, post *domain.Post) (*domain.Post, error) {
+	log.Printf("Creating post: %q", post.Title)
+	if err := post.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPost, err)
+	}
+
+	if post.Slug == "" {
+		existing, err := s.repo.GetAllIncludingDrafts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		slugs := make(map[string]bool, len(existing))
+		for _, p := range existing {
+			slugs[p.Slug] = true
+		}
+		post.Slug = post.GenerateUniqueSlug(slugs)
+	}
+	if !validSlug(post.Slug) {
+		return nil, fmt.Errorf("%w: invalid slug %q", domain.ErrInvalidPost, post.Slug)
+	}
+
+	now := time.Now()
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = now
+	}
+	post.UpdatedAt = post.CreatedAt
+
+	if err := s.repo.Create(ctx, post); err != nil {
+		return nil, err
+	}
+	return s.repo.GetBySlugIncludingDrafts(ctx, post.Slug)
+}
+
+func (s *postService) UpdatePost(ctx context.Context, slug string, post *domain.Post) (*domain.Post, error) {
+	log.Printf("Updating post with slug: %s", slug)
+	if err := post.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPost, err)
+	}
+
+	existing, err := s.repo.GetBySlugIncludingDrafts(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Update(post)
+	existing.Tags = post.Tags
+	existing.PublishAt = post.PublishAt
+	existing.Series = post.Series
+	existing.SeriesOrder = post.SeriesOrder
+	existing.Related = post.Related
+	if !post.CreatedAt.IsZero() {
+		existing.CreatedAt = post.CreatedAt
+	}
+	// Slugs are permalinks, so retitling a post keeps its URL
+	existing.Slug = slug
+
+	if err := s.repo.Update(ctx, slug, existing); err != nil {
+		return nil, err
+	}
+	return s.repo.GetBySlugIncludingDrafts(ctx, slug)
+}
+
+func (s *postService) DeletePost(ctx context.Context, slug string) error {
+	log.Printf("Deleting post with slug: %s", slug)
+	return s.repo.Delete(ctx, slug)
+}
+
+func (s *postService) PublishPost(ctx context.Context, slug string) (*domain.Post, error) {
+	log.Printf("Publishing post with slug: %s", slug)
+	if err := s.repo.Publish(ctx, slug); err != nil {
+		return nil, err
+	}
+	return s.repo.GetBySlugIncludingDrafts(ctx, slug)
+}
+
+// scan searches repositories without their own index by indexing every post
+// for the query
+func (s *postService) scan(ctx context.Context, query string) ([]*domain.SearchResult, error) {
+	posts, err := s.GetAllPosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, postsBySlug := searchDocuments(posts)
+
+	hits := search.NewIndex(docs).Search(query, nil)
+	results := make([]*domain.SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = &domain.SearchResult{Post: postsBySlug[hit.ID], Score: hit.Score}
+	}
+	return results, nil
+}
+
+// validSlug reports whether slug can be used in a URL path segment and a
+// file name
+func validSlug(slug string) bool {
+	return slug != "" && !strings.HasPrefix(slug, ".") && !strings.ContainsAny(slug, "/\\?#% \t\n")
+}
+
+// searchDocuments returns posts as search documents keyed by slug, along
+// with the posts by slug
+func searchDocuments(posts []*domain.Post) ([]search.Document, map[string]*domain.Post) {
+	docs := make([]search.Document, len(posts))
+	postsBySlug := make(map[string]*domain.Post, len(posts))
+	for i, p := range posts {
+		docs[i] = search.Document{
+			ID:          p.Slug,
+			Title:       p.Title,
+			Description: p.Description,
+			Tags:        p.Tags,
+			Body:        search.PlainText(p.Content),
+		}
+		postsBySlug[p.Slug] = p
+	}
+	return docs, postsBySlug
+}
+
+// seriesOf collects the posts of the series with the given slug in reading
+// order: by series_order, then posts without one by date. It returns nil if
+// no post is in the series.
+func seriesOf(posts []*domain.Post, slug string) *domain.Series {
+	var series *domain.Series
+	for _, p := range posts {
+		if p.Series == "" || domain.SeriesSlug(p.Series) != slug {
+			continue
+		}
+		if series == nil {
+			series = &domain.Series{Name: p.Series, Slug: slug}
+		}
+		series.Posts = append(series.Posts, p)
+	}
+	if series == nil {
+		return nil
+	}
+
+	sort.SliceStable(series.Posts, func(i, j int) bool {
+		a, b := series.Posts[i], series.Posts[j]
+		if (a.SeriesOrder == 0) != (b.SeriesOrder == 0) {
+			return a.SeriesOrder != 0
+		}
+		if a.SeriesOrder != b.SeriesOrder {
+			return a.SeriesOrder < b.SeriesOrder
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return series
+}
+
+// tagName returns the display name of the tag matching slug
+func tagName(tags []string, slug string) string {
+	for _, name := range tags {
+		if domain.TagSlug(name) == slug {
+			return name
+		}
+	}
+	return slug
+}
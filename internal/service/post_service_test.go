@@ -2,8 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/seanankenbruck/blog/internal/domain"
 )
@@ -39,6 +43,51 @@ func (m *mockPostRepository) GetAll(ctx context.Context) ([]*domain.Post, error)
 	return posts, nil
 }
 
+func (m *mockPostRepository) GetBySlugIncludingDrafts(ctx context.Context, slug string) (*domain.Post, error) {
+	return m.GetBySlug(ctx, slug)
+}
+
+func (m *mockPostRepository) GetAllIncludingDrafts(ctx context.Context) ([]*domain.Post, error) {
+	return m.GetAll(ctx)
+}
+
+func (m *mockPostRepository) Create(ctx context.Context, post *domain.Post) error {
+	if _, ok := m.posts[post.Slug]; ok {
+		return domain.ErrSlugExists
+	}
+	stored := *post
+	m.posts[post.Slug] = &stored
+	return nil
+}
+
+func (m *mockPostRepository) Update(ctx context.Context, slug string, post *domain.Post) error {
+	if _, ok := m.posts[slug]; !ok {
+		return domain.ErrPostNotFound
+	}
+	stored := *post
+	delete(m.posts, slug)
+	m.posts[post.Slug] = &stored
+	return nil
+}
+
+func (m *mockPostRepository) Delete(ctx context.Context, slug string) error {
+	if _, ok := m.posts[slug]; !ok {
+		return domain.ErrPostNotFound
+	}
+	delete(m.posts, slug)
+	return nil
+}
+
+func (m *mockPostRepository) Publish(ctx context.Context, slug string) error {
+	post, ok := m.posts[slug]
+	if !ok {
+		return domain.ErrPostNotFound
+	}
+	post.Published = true
+	post.Draft = false
+	return nil
+}
+
 func TestNewPostService(t *testing.T) {
 	log.Println("Testing NewPostService...")
 
@@ -188,7 +237,9 @@ func TestGetPostContextCancelled(t *testing.T) {
 }
 
 // mockReturnsNilPostRepository returns nil for GetBySlug to test nil handling
-type mockReturnsNilPostRepository struct{}
+type mockReturnsNilPostRepository struct {
+	mockPostRepository
+}
 
 func (m *mockReturnsNilPostRepository) GetByID(ctx context.Context, id uint) (*domain.Post, error) {
 	return nil, nil
@@ -215,3 +266,450 @@ func TestGetPostBySlugReturnsNil(t *testing.T) {
 
 	log.Println("GetPostBySlug nil test completed")
 }
+
+func TestGetTags(t *testing.T) {
+	log.Println("Testing GetTags...")
+
+	mockRepo := newMockPostRepository()
+	mockRepo.posts["post-1"] = &domain.Post{Slug: "post-1", Tags: []string{"Go", "Observability"}}
+	mockRepo.posts["post-2"] = &domain.Post{Slug: "post-2", Tags: []string{"go", "ClickHouse"}}
+	mockRepo.posts["post-3"] = &domain.Post{Slug: "post-3"}
+	service := NewPostService(mockRepo)
+
+	tags, err := service.GetTags(context.Background())
+	if err != nil {
+		t.Fatalf("GetTags() returned error: %v", err)
+	}
+
+	if len(tags) != 3 {
+		t.Fatalf("Expected 3 tags, got %d", len(tags))
+	}
+	// Tags differing only in case are merged and the most used comes first
+	if tags[0].Slug != "go" || tags[0].Count != 2 {
+		t.Errorf("Expected first tag 'go' with count 2, got '%s' with count %d", tags[0].Slug, tags[0].Count)
+	}
+	if tags[1].Slug != "clickhouse" || tags[2].Slug != "observability" {
+		t.Errorf("Expected remaining tags sorted by name, got '%s', '%s'", tags[1].Slug, tags[2].Slug)
+	}
+
+	log.Println("GetTags test completed")
+}
+
+func TestGetPostsByTag(t *testing.T) {
+	log.Println("Testing GetPostsByTag...")
+
+	mockRepo := newMockPostRepository()
+	mockRepo.posts["post-1"] = &domain.Post{Slug: "post-1", Tags: []string{"Software Development"}}
+	mockRepo.posts["post-2"] = &domain.Post{Slug: "post-2", Tags: []string{"go"}}
+	service := NewPostService(mockRepo)
+
+	tag, posts, err := service.GetPostsByTag(context.Background(), "software-development")
+	if err != nil {
+		t.Fatalf("GetPostsByTag() returned error: %v", err)
+	}
+	if tag.Name != "Software Development" || tag.Count != 1 {
+		t.Errorf("Expected tag 'Software Development' with count 1, got '%s' with count %d", tag.Name, tag.Count)
+	}
+	if len(posts) != 1 || posts[0].Slug != "post-1" {
+		t.Errorf("Expected only 'post-1', got %d posts", len(posts))
+	}
+
+	_, _, err = service.GetPostsByTag(context.Background(), "rust")
+	if err != domain.ErrTagNotFound {
+		t.Errorf("Expected ErrTagNotFound for unknown tag, got: %v", err)
+	}
+
+	log.Println("GetPostsByTag test completed")
+}
+
+func seriesRepository() *mockPostRepository {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo := newMockPostRepository()
+	for i, p := range []*domain.Post{
+		{Slug: "intro", Series: "Building a Blog", SeriesOrder: 1},
+		{Slug: "standalone"},
+		{Slug: "templates", Series: "Building a Blog", SeriesOrder: 2},
+		{Slug: "appendix", Series: "building a blog"}, // Unordered parts come last
+		{Slug: "latest"},
+	} {
+		p.CreatedAt = base.AddDate(0, 0, i)
+		mockRepo.posts[p.Slug] = p
+	}
+	return mockRepo
+}
+
+func TestGetSeries(t *testing.T) {
+	service := NewPostService(seriesRepository())
+
+	series, err := service.GetSeries(context.Background(), "Building-A-Blog")
+	if err != nil {
+		t.Fatalf("GetSeries() returned error: %v", err)
+	}
+	if series.Slug != "building-a-blog" {
+		t.Errorf("Expected slug 'building-a-blog', got %q", series.Slug)
+	}
+	var slugs []string
+	for _, p := range series.Posts {
+		slugs = append(slugs, p.Slug)
+	}
+	if strings.Join(slugs, ",") != "intro,templates,appendix" {
+		t.Errorf("Expected parts intro,templates,appendix, got %v", slugs)
+	}
+
+	if _, err := service.GetSeries(context.Background(), "missing"); err != domain.ErrSeriesNotFound {
+		t.Errorf("Expected ErrSeriesNotFound, got %v", err)
+	}
+}
+
+func TestGetPostNavigation(t *testing.T) {
+	mockRepo := seriesRepository()
+	service := NewPostService(mockRepo)
+	slug := func(p *domain.Post) string {
+		if p == nil {
+			return ""
+		}
+		return p.Slug
+	}
+
+	tests := []struct {
+		slug       string
+		part       int
+		prev, next string
+	}{
+		// Series parts link to each other, not to posts in between
+		{"intro", 1, "", "templates"},
+		{"templates", 2, "intro", "appendix"},
+		{"appendix", 3, "templates", ""},
+		// Other posts fall back to date order
+		{"standalone", 0, "intro", "templates"},
+		{"latest", 0, "appendix", ""},
+	}
+	for _, tt := range tests {
+		nav, err := service.GetPostNavigation(context.Background(), mockRepo.posts[tt.slug])
+		if err != nil {
+			t.Fatalf("GetPostNavigation(%s) returned error: %v", tt.slug, err)
+		}
+		if nav.Part != tt.part || slug(nav.Prev) != tt.prev || slug(nav.Next) != tt.next {
+			t.Errorf("GetPostNavigation(%s) = part %d, prev %q, next %q; want %d, %q, %q",
+				tt.slug, nav.Part, slug(nav.Prev), slug(nav.Next), tt.part, tt.prev, tt.next)
+		}
+		if (nav.Series != nil) != (tt.part > 0) {
+			t.Errorf("GetPostNavigation(%s) series = %+v", tt.slug, nav.Series)
+		}
+	}
+}
+
+func TestGetRelatedPosts(t *testing.T) {
+	// The mock repository keeps no ranking, so the service ranks on demand
+	mockRepo := newMockPostRepository()
+	mockRepo.posts["metrics"] = &domain.Post{Slug: "metrics", Title: "Metrics", Tags: []string{"Observability"}, Content: "<p>Storing metrics</p>"}
+	mockRepo.posts["tracing"] = &domain.Post{Slug: "tracing", Title: "Tracing", Tags: []string{"observability"}, Content: "<p>Sampling traces</p>"}
+	mockRepo.posts["logs"] = &domain.Post{Slug: "logs", Title: "Logs", Content: "<p>Storing metrics from logs</p>"}
+	mockRepo.posts["bread"] = &domain.Post{Slug: "bread", Title: "Bread", Content: "<p>Baking</p>"}
+	service := NewPostService(mockRepo)
+
+	related, err := service.GetRelatedPosts(context.Background(), "metrics", 5)
+	if err != nil {
+		t.Fatalf("GetRelatedPosts() returned error: %v", err)
+	}
+	if len(related) != 2 || related[0].Slug != "tracing" || related[1].Slug != "logs" {
+		t.Errorf("Expected [tracing logs], got %d posts", len(related))
+	}
+
+	related, _ = service.GetRelatedPosts(context.Background(), "metrics", 1)
+	if len(related) != 1 {
+		t.Errorf("Expected the limit to apply, got %d posts", len(related))
+	}
+
+	if _, err := service.GetRelatedPosts(context.Background(), "missing", 3); err != domain.ErrPostNotFound {
+		t.Errorf("Expected ErrPostNotFound, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	log.Println("Testing Search...")
+
+	mockRepo := newMockPostRepository()
+	mockRepo.posts["tracing"] = &domain.Post{
+		Slug:    "tracing",
+		Title:   "Distributed Tracing",
+		Content: "<p>Tracing requests across services.</p>",
+	}
+	mockRepo.posts["metrics"] = &domain.Post{
+		Slug:    "metrics",
+		Title:   "Metrics",
+		Content: "<p>Counters, gauges and a note on traces.</p>",
+	}
+	service := NewPostService(mockRepo)
+
+	results, err := service.Search(context.Background(), "traced")
+	if err != nil {
+		t.Fatalf("Search() returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Post.Slug != "tracing" {
+		t.Errorf("Expected title match 'tracing' to rank first, got '%s'", results[0].Post.Slug)
+	}
+	if !strings.Contains(results[1].Snippet, "<mark>traces</mark>") {
+		t.Errorf("Expected highlighted snippet, got %q", results[1].Snippet)
+	}
+
+	results, err = service.Search(context.Background(), "   ")
+	if err != nil || len(results) != 0 {
+		t.Errorf("Expected no results for blank query, got %d (%v)", len(results), err)
+	}
+
+	log.Println("Search test completed")
+}
+
+func TestListPosts(t *testing.T) {
+	log.Println("Testing ListPosts...")
+
+	mockRepo := newMockPostRepository()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		slug := fmt.Sprintf("post-%d", i)
+		tags := []string{"go"}
+		if i%2 == 0 {
+			tags = append(tags, "Kubernetes")
+		}
+		mockRepo.posts[slug] = &domain.Post{
+			Slug:      slug,
+			Title:     fmt.Sprintf("Title %d", 6-i),
+			Tags:      tags,
+			CreatedAt: base.AddDate(0, 0, i),
+		}
+	}
+	service := NewPostService(mockRepo)
+	ctx := context.Background()
+
+	slugs := func(page *domain.PostPage) string {
+		var s []string
+		for _, p := range page.Posts {
+			s = append(s, p.Slug)
+		}
+		return strings.Join(s, ",")
+	}
+
+	t.Run("Pages newest first", func(t *testing.T) {
+		page, err := service.ListPosts(ctx, domain.PostQuery{Page: 2, PerPage: 2})
+		if err != nil {
+			t.Fatalf("ListPosts() returned error: %v", err)
+		}
+		if got := slugs(page); got != "post-3,post-2" {
+			t.Errorf("Expected post-3,post-2, got %s", got)
+		}
+		if page.Total != 5 || page.TotalPages != 3 || page.Page != 2 {
+			t.Errorf("Expected total 5 over 3 pages on page 2, got %d over %d on page %d", page.Total, page.TotalPages, page.Page)
+		}
+		if !page.HasPrev() || !page.HasNext() {
+			t.Errorf("Expected prev and next pages")
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		page, err := service.ListPosts(ctx, domain.PostQuery{})
+		if err != nil {
+			t.Fatalf("ListPosts() returned error: %v", err)
+		}
+		if page.Page != 1 || page.PerPage != domain.DefaultPerPage || len(page.Posts) != 5 || page.HasNext() {
+			t.Errorf("Expected all posts on page 1, got %d posts on page %d", len(page.Posts), page.Page)
+		}
+	})
+
+	t.Run("Filters and sorts", func(t *testing.T) {
+		page, err := service.ListPosts(ctx, domain.PostQuery{
+			Tag:  "kubernetes",
+			From: base.AddDate(0, 0, 2),
+			To:   base.AddDate(0, 0, 4),
+			Sort: domain.SortOldest,
+		})
+		if err != nil {
+			t.Fatalf("ListPosts() returned error: %v", err)
+		}
+		if got := slugs(page); got != "post-2,post-4" || page.Total != 2 {
+			t.Errorf("Expected post-2,post-4, got %s (total %d)", got, page.Total)
+		}
+
+		page, err = service.ListPosts(ctx, domain.PostQuery{Sort: domain.SortTitle, PerPage: 2})
+		if err != nil {
+			t.Fatalf("ListPosts() returned error: %v", err)
+		}
+		if got := slugs(page); got != "post-5,post-4" {
+			t.Errorf("Expected post-5,post-4 by title, got %s", got)
+		}
+	})
+
+	t.Run("Cursor pagination", func(t *testing.T) {
+		var seen []string
+		q := domain.PostQuery{PerPage: 2, Sort: domain.SortTitle}
+		for {
+			page, err := service.ListPosts(ctx, q)
+			if err != nil {
+				t.Fatalf("ListPosts() returned error: %v", err)
+			}
+			seen = append(seen, slugs(page))
+			if !page.HasNext() {
+				break
+			}
+			q.Cursor = page.NextCursor
+
+			// A post added to an earlier page must not shift later pages
+			mockRepo.posts["post-0"] = &domain.Post{Slug: "post-0", Title: "Title 0", CreatedAt: base}
+		}
+		if got := strings.Join(seen, "|"); got != "post-5,post-4|post-3,post-2|post-1" {
+			t.Errorf("Expected post-5,post-4|post-3,post-2|post-1, got %s", got)
+		}
+		delete(mockRepo.posts, "post-0")
+	})
+
+	t.Run("Invalid queries", func(t *testing.T) {
+		queries := []domain.PostQuery{
+			{Sort: "popular"},
+			{PerPage: domain.MaxPerPage + 1},
+			{Page: -1},
+			{From: base.AddDate(0, 0, 2), To: base},
+			{Cursor: "not a cursor"},
+			{Cursor: encodeCursor(domain.SortTitle, &domain.Post{Slug: "post-1"})},
+		}
+		for _, q := range queries {
+			if _, err := service.ListPosts(ctx, q); !errors.Is(err, domain.ErrInvalidQuery) {
+				t.Errorf("Expected ErrInvalidQuery for %+v, got: %v", q, err)
+			}
+		}
+	})
+
+	log.Println("ListPosts test completed")
+}
+
+func TestPostLifecycle(t *testing.T) {
+	log.Println("Testing CreatePost, UpdatePost, PublishPost and DeletePost...")
+
+	ctx := context.Background()
+	mockRepo := newMockPostRepository()
+	mockRepo.posts["hello-world"] = &domain.Post{Slug: "hello-world", Title: "Hello World"}
+	service := NewPostService(mockRepo)
+
+	t.Run("Create validates the post", func(t *testing.T) {
+		_, err := service.CreatePost(ctx, &domain.Post{Title: "No Content", Description: "Missing content"})
+		if !errors.Is(err, domain.ErrInvalidPost) {
+			t.Errorf("Expected ErrInvalidPost, got: %v", err)
+		}
+
+		_, err = service.CreatePost(ctx, &domain.Post{Title: "Bad", Slug: "../bad", Content: "Body", Description: "Desc"})
+		if !errors.Is(err, domain.ErrInvalidPost) {
+			t.Errorf("Expected ErrInvalidPost for path-like slug, got: %v", err)
+		}
+	})
+
+	t.Run("Create generates a unique slug", func(t *testing.T) {
+		post, err := service.CreatePost(ctx, &domain.Post{Title: "Hello World", Content: "# Hi", Description: "Desc"})
+		if err != nil {
+			t.Fatalf("CreatePost() returned error: %v", err)
+		}
+		if post.Slug != "hello-world-1" {
+			t.Errorf("Expected slug hello-world-1, got %s", post.Slug)
+		}
+		if post.CreatedAt.IsZero() || !post.UpdatedAt.Equal(post.CreatedAt) {
+			t.Errorf("Expected timestamps to be set, got created %v updated %v", post.CreatedAt, post.UpdatedAt)
+		}
+	})
+
+	t.Run("Create generates URL-safe slugs", func(t *testing.T) {
+		for title, want := range map[string]string{"What is Go?": "what-is-go", "CI/CD tips": "ci-cd-tips"} {
+			post, err := service.CreatePost(ctx, &domain.Post{Title: title, Content: "Body", Description: "Desc"})
+			if err != nil {
+				t.Fatalf("CreatePost(%q) returned error: %v", title, err)
+			}
+			if post.Slug != want {
+				t.Errorf("CreatePost(%q) slug = %s, want %s", title, post.Slug, want)
+			}
+		}
+	})
+
+	t.Run("Create rejects an explicit slug that is taken", func(t *testing.T) {
+		_, err := service.CreatePost(ctx, &domain.Post{Title: "Other", Slug: "hello-world", Content: "Body", Description: "Desc"})
+		if !errors.Is(err, domain.ErrSlugExists) {
+			t.Errorf("Expected ErrSlugExists, got: %v", err)
+		}
+	})
+
+	t.Run("Update keeps the slug", func(t *testing.T) {
+		post, err := service.UpdatePost(ctx, "hello-world-1", &domain.Post{
+			Title:       "Retitled",
+			Content:     "New body",
+			Description: "New desc",
+			Tags:        []string{"Go"},
+		})
+		if err != nil {
+			t.Fatalf("UpdatePost() returned error: %v", err)
+		}
+		if post.Slug != "hello-world-1" || post.Title != "Retitled" || len(post.Tags) != 1 {
+			t.Errorf("Unexpected post after update: %+v", post)
+		}
+
+		created := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+		publishAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		post, err = service.UpdatePost(ctx, "hello-world-1", &domain.Post{
+			Title:       "Retitled",
+			Content:     "New body",
+			Description: "New desc",
+			CreatedAt:   created,
+			PublishAt:   publishAt,
+		})
+		if err != nil {
+			t.Fatalf("UpdatePost() returned error: %v", err)
+		}
+		if !post.CreatedAt.Equal(created) || !post.PublishAt.Equal(publishAt) {
+			t.Errorf("Expected dates from the editor to be saved, got created %v publish at %v", post.CreatedAt, post.PublishAt)
+		}
+
+		// Posts can join, reorder within and leave a series
+		post, err = service.UpdatePost(ctx, "hello-world-1", &domain.Post{
+			Title:       "Retitled",
+			Content:     "New body",
+			Description: "New desc",
+			Series:      "Go Basics",
+			SeriesOrder: 2,
+		})
+		if err != nil {
+			t.Fatalf("UpdatePost() returned error: %v", err)
+		}
+		if post.Series != "Go Basics" || post.SeriesOrder != 2 {
+			t.Errorf("Expected the post to join the series, got %q part %d", post.Series, post.SeriesOrder)
+		}
+		post, err = service.UpdatePost(ctx, "hello-world-1", &domain.Post{Title: "Retitled", Content: "New body", Description: "New desc"})
+		if err != nil {
+			t.Fatalf("UpdatePost() returned error: %v", err)
+		}
+		if post.Series != "" || post.SeriesOrder != 0 {
+			t.Errorf("Expected the post to leave the series, got %q part %d", post.Series, post.SeriesOrder)
+		}
+
+		_, err = service.UpdatePost(ctx, "missing", &domain.Post{Title: "T", Content: "C", Description: "D"})
+		if !errors.Is(err, domain.ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("Publish and delete", func(t *testing.T) {
+		post, err := service.PublishPost(ctx, "hello-world-1")
+		if err != nil {
+			t.Fatalf("PublishPost() returned error: %v", err)
+		}
+		if !post.Published {
+			t.Error("Expected post to be published")
+		}
+
+		if err := service.DeletePost(ctx, "hello-world-1"); err != nil {
+			t.Fatalf("DeletePost() returned error: %v", err)
+		}
+		if _, err := service.GetPostBySlugIncludingDrafts(ctx, "hello-world-1"); !errors.Is(err, domain.ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound after delete, got: %v", err)
+		}
+	})
+
+	log.Println("Post lifecycle test completed")
+}
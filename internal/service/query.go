@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// postLess returns the ordering for a sort, breaking ties by slug so that
+// every post has a fixed position a cursor can refer to
+func postLess(sort domain.PostSort) func(a, b *domain.Post) bool {
+	return func(a, b *domain.Post) bool {
+		switch sort {
+		case domain.SortOldest:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		case domain.SortTitle:
+			if at, bt := strings.ToLower(a.Title), strings.ToLower(b.Title); at != bt {
+				return at < bt
+			}
+		default:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+		}
+		return a.Slug < b.Slug
+	}
+}
+
+// cursor is the position of the last post on a page: its sort keys and the
+// sort they belong to
+type cursor struct {
+	Sort  domain.PostSort `json:"s"`
+	Time  int64           `json:"t,omitempty"`
+	Title string          `json:"ti,omitempty"`
+	Slug  string          `json:"sl"`
+}
+
+// encodeCursor returns an opaque cursor pointing just after p
+func encodeCursor(sort domain.PostSort, p *domain.Post) string {
+	c := cursor{Sort: sort, Slug: p.Slug}
+	if sort == domain.SortTitle {
+		c.Title = p.Title
+	} else {
+		c.Time = p.CreatedAt.UnixNano()
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor into a stand-in post that can be compared
+// with postLess
+func decodeCursor(s string, sort domain.PostSort) (*domain.Post, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", domain.ErrInvalidQuery)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Slug == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", domain.ErrInvalidQuery)
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("%w: cursor was issued for sort %q", domain.ErrInvalidQuery, c.Sort)
+	}
+
+	return &domain.Post{
+		Slug:      c.Slug,
+		Title:     c.Title,
+		CreatedAt: time.Unix(0, c.Time),
+	}, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/auth"
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// dummyHash is compared against when a username doesn't exist, so failed
+// sign-ins take as long whether or not the user exists. It is hashed on
+// first use rather than whenever the package is loaded.
+var dummyHash = sync.OnceValues(func() (string, error) {
+	return auth.HashPassword("dummy-password-for-timing")
+})
+
+// userService implements domain.UserService
+type userService struct {
+	repo domain.UserRepository
+}
+
+// NewUserService creates a new UserService with the given repository
+func NewUserService(repo domain.UserRepository) domain.UserService {
+	return &userService{repo: repo}
+}
+
+func (s *userService) Register(ctx context.Context, username, password string) (*domain.User, error) {
+	username = strings.TrimSpace(username)
+	log.Printf("Registering user: %s", username)
+	if username == "" || strings.ContainsAny(username, " \t\r\n") {
+		return nil, fmt.Errorf("%w: username must be non-empty with no spaces", domain.ErrInvalidUser)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if errors.Is(err, auth.ErrWeakPassword) || errors.Is(err, auth.ErrLongPassword) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidUser, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	user := &domain.User{Username: username, PasswordHash: hash, CreatedAt: now, UpdatedAt: now}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) Authenticate(ctx context.Context, username, password string) (*domain.User, error) {
+	user, err := s.repo.GetByUsername(ctx, strings.TrimSpace(username))
+	if errors.Is(err, domain.ErrUserNotFound) {
+		hash, err := dummyHash()
+		if err != nil {
+			return nil, err
+		}
+		auth.CheckPassword(hash, password)
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.CheckPassword(user.PasswordHash, password) {
+		log.Printf("Failed sign-in for user: %s", user.Username)
+		return nil, domain.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (s *userService) GetUser(ctx context.Context, username string) (*domain.User, error) {
+	return s.repo.GetByUsername(ctx, username)
+}
+
+func (s *userService) GetUsers(ctx context.Context) ([]*domain.User, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *userService) RevokeSessions(ctx context.Context, username string) error {
+	log.Printf("Revoking sessions of user: %s", username)
+	user, err := s.repo.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	user.SessionVersion++
+	user.UpdatedAt = time.Now().UTC()
+	return s.repo.Update(ctx, user)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// mockUserRepository is a mock implementation of domain.UserRepository
+type mockUserRepository struct {
+	users map[string]*domain.User
+}
+
+func (m *mockUserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	user, ok := m.users[username]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (m *mockUserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
+	users := make([]*domain.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (m *mockUserRepository) Create(ctx context.Context, user *domain.User) error {
+	if _, ok := m.users[user.Username]; ok {
+		return domain.ErrUserExists
+	}
+	m.users[user.Username] = user
+	return nil
+}
+
+func (m *mockUserRepository) Update(ctx context.Context, user *domain.User) error {
+	if _, ok := m.users[user.Username]; !ok {
+		return domain.ErrUserNotFound
+	}
+	m.users[user.Username] = user
+	return nil
+}
+
+func TestUserService(t *testing.T) {
+	ctx := context.Background()
+	service := NewUserService(&mockUserRepository{users: make(map[string]*domain.User)})
+
+	t.Run("Register validates input", func(t *testing.T) {
+		for _, tc := range []struct{ username, password string }{
+			{"", "long enough password"},
+			{"has space", "long enough password"},
+			{"admin", "short"},
+			{"admin", strings.Repeat("long", 19)},
+		} {
+			if _, err := service.Register(ctx, tc.username, tc.password); !errors.Is(err, domain.ErrInvalidUser) {
+				t.Errorf("Register(%q, %q) expected ErrInvalidUser, got %v", tc.username, tc.password, err)
+			}
+		}
+	})
+
+	t.Run("Register hashes the password", func(t *testing.T) {
+		user, err := service.Register(ctx, "admin", "long enough password")
+		if err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+		if user.PasswordHash == "" || user.PasswordHash == "long enough password" {
+			t.Errorf("Expected a password hash, got %q", user.PasswordHash)
+		}
+
+		if _, err := service.Register(ctx, "admin", "another long password"); !errors.Is(err, domain.ErrUserExists) {
+			t.Errorf("Register() expected ErrUserExists, got %v", err)
+		}
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		user, err := service.Authenticate(ctx, "admin", "long enough password")
+		if err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+		if user.Username != "admin" {
+			t.Errorf("Expected admin, got %s", user.Username)
+		}
+
+		for _, tc := range []struct{ username, password string }{
+			{"admin", "wrong password here"},
+			{"nobody", "long enough password"},
+		} {
+			if _, err := service.Authenticate(ctx, tc.username, tc.password); !errors.Is(err, domain.ErrInvalidCredentials) {
+				t.Errorf("Authenticate(%q) expected ErrInvalidCredentials, got %v", tc.username, err)
+			}
+		}
+	})
+
+	t.Run("RevokeSessions bumps the session version", func(t *testing.T) {
+		if err := service.RevokeSessions(ctx, "admin"); err != nil {
+			t.Fatalf("RevokeSessions() error = %v", err)
+		}
+		user, _ := service.GetUser(ctx, "admin")
+		if user.SessionVersion != 1 {
+			t.Errorf("Expected session version 1, got %d", user.SessionVersion)
+		}
+		if err := service.RevokeSessions(ctx, "nobody"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("RevokeSessions() expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
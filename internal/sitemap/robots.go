@@ -0,0 +1,41 @@
+package sitemap
+
+import (
+	"strings"
+)
+
+// RobotsConfig controls the generated robots.txt
+type RobotsConfig struct {
+	// DisallowAll blocks all crawlers, e.g. for staging deployments
+	DisallowAll bool
+	// Disallow lists path prefixes crawlers should not fetch
+	Disallow []string
+	// SitemapURL is the absolute URL of the sitemap, if any
+	SitemapURL string
+}
+
+// Robots renders a robots.txt document for cfg
+func Robots(cfg RobotsConfig) string {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+
+	switch {
+	case cfg.DisallowAll:
+		b.WriteString("Disallow: /\n")
+	case len(cfg.Disallow) == 0:
+		// An empty Disallow allows everything
+		b.WriteString("Disallow:\n")
+	default:
+		for _, path := range cfg.Disallow {
+			if path = strings.TrimSpace(path); path != "" {
+				b.WriteString("Disallow: " + path + "\n")
+			}
+		}
+	}
+
+	if cfg.SitemapURL != "" && !cfg.DisallowAll {
+		b.WriteString("\nSitemap: " + cfg.SitemapURL + "\n")
+	}
+
+	return b.String()
+}
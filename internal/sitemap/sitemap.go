@@ -0,0 +1,234 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+// MaxURLs is the maximum number of URLs a single sitemap may contain under
+// the sitemaps.org protocol. Larger sets are split and served via an index.
+const MaxURLs = 50000
+
+const (
+	sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	imageNS   = "http://www.google.com/schemas/sitemap-image/1.1"
+)
+
+// Page is a fixed page of the site to list in the sitemap
+type Page struct {
+	Path       string
+	ChangeFreq string // Optional hint such as "daily" or "monthly"
+	// LatestPost uses the most recent post update as the page's lastmod,
+	// for listing pages whose content changes with every post
+	LatestPost bool
+}
+
+// URL is a single sitemap entry
+type URL struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	Images     []string
+}
+
+// Build returns the sitemap entries for the registered pages, every post and
+// every tag listing. Drafts, which are only listed in dev mode, are left
+// out. baseURL must be the absolute URL of the site root.
+func Build(baseURL string, pages []Page, posts []*domain.Post) []URL {
+	base := strings.TrimRight(baseURL, "/")
+
+	posts = domain.WithoutDrafts(posts)
+	urls := make([]URL, 0, len(pages)+len(posts))
+
+	var latest time.Time
+	for _, p := range posts {
+		if t := lastModified(p); t.After(latest) {
+			latest = t
+		}
+	}
+
+	for _, page := range pages {
+		u := URL{Loc: base + page.Path, ChangeFreq: page.ChangeFreq}
+		if page.LatestPost {
+			u.LastMod = latest
+		}
+		urls = append(urls, u)
+	}
+
+	tagLastMod := make(map[string]time.Time)
+	for _, p := range posts {
+		loc := base + "/posts/" + p.Slug
+		urls = append(urls, URL{
+			Loc:     loc,
+			LastMod: lastModified(p),
+			Images:  imageURLs(p.Content, loc),
+		})
+
+		for _, tag := range p.Tags {
+			slug := domain.TagSlug(tag)
+			if slug == "" {
+				continue
+			}
+			if t := lastModified(p); t.After(tagLastMod[slug]) {
+				tagLastMod[slug] = t
+			}
+		}
+	}
+
+	tagSlugs := make([]string, 0, len(tagLastMod))
+	for slug := range tagLastMod {
+		tagSlugs = append(tagSlugs, slug)
+	}
+	sort.Strings(tagSlugs)
+	for _, slug := range tagSlugs {
+		urls = append(urls, URL{Loc: base + "/tags/" + slug, LastMod: tagLastMod[slug]})
+	}
+
+	return urls
+}
+
+// Pages returns the number of sitemap files needed for n URLs
+func Pages(n int) int {
+	if n <= MaxURLs {
+		return 1
+	}
+	return (n + MaxURLs - 1) / MaxURLs
+}
+
+// Chunk returns the URLs belonging to the given 1-based sitemap page, or
+// false if the page does not exist
+func Chunk(urls []URL, page int) ([]URL, bool) {
+	if page < 1 || page > Pages(len(urls)) {
+		return nil, false
+	}
+	start := (page - 1) * MaxURLs
+	end := start + MaxURLs
+	if end > len(urls) {
+		end = len(urls)
+	}
+	return urls[start:end], true
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	NS      string     `xml:"xmlns,attr"`
+	ImageNS string     `xml:"xmlns:image,attr"`
+	URLs    []urlEntry `xml:"url"`
+}
+
+type urlEntry struct {
+	Loc        string       `xml:"loc"`
+	LastMod    string       `xml:"lastmod,omitempty"`
+	ChangeFreq string       `xml:"changefreq,omitempty"`
+	Images     []imageEntry `xml:"image:image"`
+}
+
+type imageEntry struct {
+	Loc string `xml:"image:loc"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	NS       string       `xml:"xmlns,attr"`
+	Sitemaps []indexEntry `xml:"sitemap"`
+}
+
+type indexEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// URLSet renders urls as a sitemap <urlset> document
+func URLSet(urls []URL) ([]byte, error) {
+	doc := urlSet{NS: sitemapNS, ImageNS: imageNS}
+	for _, u := range urls {
+		entry := urlEntry{
+			Loc:        u.Loc,
+			LastMod:    formatLastMod(u.LastMod),
+			ChangeFreq: u.ChangeFreq,
+		}
+		for _, img := range u.Images {
+			entry.Images = append(entry.Images, imageEntry{Loc: img})
+		}
+		doc.URLs = append(doc.URLs, entry)
+	}
+	return marshal(doc)
+}
+
+// Index renders a <sitemapindex> document pointing at each page of urls.
+// pageURL returns the absolute URL of a 1-based sitemap page.
+func Index(urls []URL, pageURL func(page int) string) ([]byte, error) {
+	doc := sitemapIndex{NS: sitemapNS}
+	for page := 1; page <= Pages(len(urls)); page++ {
+		chunk, _ := Chunk(urls, page)
+
+		var latest time.Time
+		for _, u := range chunk {
+			if u.LastMod.After(latest) {
+				latest = u.LastMod
+			}
+		}
+
+		doc.Sitemaps = append(doc.Sitemaps, indexEntry{
+			Loc:     pageURL(page),
+			LastMod: formatLastMod(latest),
+		})
+	}
+	return marshal(doc)
+}
+
+func marshal(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func formatLastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// lastModified returns when a post was last changed
+func lastModified(p *domain.Post) time.Time {
+	if p.UpdatedAt.After(p.CreatedAt) {
+		return p.UpdatedAt
+	}
+	return p.CreatedAt
+}
+
+// imgSrcPattern matches the src attribute of <img> tags in rendered post HTML
+var imgSrcPattern = regexp.MustCompile(`<img\b[^>]*?\bsrc="([^"]+)"`)
+
+// imageURLs returns the distinct absolute URLs of the images in a post, such
+// as its banner, resolved against the post's URL
+func imageURLs(html, postURL string) []string {
+	base, err := url.Parse(postURL)
+	if err != nil {
+		return nil
+	}
+
+	var images []string
+	seen := make(map[string]bool)
+	for _, m := range imgSrcPattern.FindAllStringSubmatch(html, -1) {
+		ref, err := url.Parse(m[1])
+		if err != nil || ref.Scheme == "data" {
+			continue
+		}
+		abs := base.ResolveReference(ref).String()
+		if !seen[abs] {
+			seen[abs] = true
+			images = append(images, abs)
+		}
+	}
+	return images
+}
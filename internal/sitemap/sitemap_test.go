@@ -0,0 +1,184 @@
+package sitemap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seanankenbruck/blog/internal/domain"
+)
+
+func testPosts() []*domain.Post {
+	created := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	return []*domain.Post{
+		{
+			Slug:      "newer-post",
+			Content:   `<p><img src="../../static/images/banner.png" alt="banner"><img src="../../static/images/banner.png"><img src="https://cdn.example.com/b.png"></p>`,
+			Tags:      []string{"Go", "Testing"},
+			CreatedAt: created.AddDate(0, 0, 5),
+			UpdatedAt: created.AddDate(0, 0, 9),
+		},
+		{
+			Slug:      "older-post",
+			Content:   "<p>No images.</p>",
+			Tags:      []string{"go"},
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+		{
+			Slug:      "draft-post",
+			Tags:      []string{"drafts"},
+			Draft:     true,
+			CreatedAt: created.AddDate(0, 1, 0),
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	pages := []Page{
+		{Path: "/", ChangeFreq: "monthly"},
+		{Path: "/posts", LatestPost: true},
+	}
+	urls := Build("https://example.com/", pages, testPosts())
+
+	var locs []string
+	for _, u := range urls {
+		locs = append(locs, u.Loc)
+	}
+	want := []string{
+		"https://example.com/",
+		"https://example.com/posts",
+		"https://example.com/posts/newer-post",
+		"https://example.com/posts/older-post",
+		"https://example.com/tags/go",
+		"https://example.com/tags/testing",
+	}
+	if strings.Join(locs, " ") != strings.Join(want, " ") {
+		t.Fatalf("Build() locs = %v, want %v", locs, want)
+	}
+
+	latest := time.Date(2024, 1, 24, 10, 0, 0, 0, time.UTC)
+	if !urls[0].LastMod.IsZero() {
+		t.Errorf("Expected no lastmod for /, got %v", urls[0].LastMod)
+	}
+	if !urls[1].LastMod.Equal(latest) {
+		t.Errorf("Expected /posts lastmod %v, got %v", latest, urls[1].LastMod)
+	}
+	if !urls[2].LastMod.Equal(latest) {
+		t.Errorf("Expected post lastmod to use the updated date %v, got %v", latest, urls[2].LastMod)
+	}
+	if !urls[4].LastMod.Equal(latest) {
+		t.Errorf("Expected tag lastmod %v, got %v", latest, urls[4].LastMod)
+	}
+
+	wantImages := []string{
+		"https://example.com/static/images/banner.png",
+		"https://cdn.example.com/b.png",
+	}
+	if strings.Join(urls[2].Images, " ") != strings.Join(wantImages, " ") {
+		t.Errorf("Expected images %v, got %v", wantImages, urls[2].Images)
+	}
+	if len(urls[3].Images) != 0 {
+		t.Errorf("Expected no images, got %v", urls[3].Images)
+	}
+}
+
+func TestURLSet(t *testing.T) {
+	out, err := URLSet(Build("https://example.com", []Page{{Path: "/", ChangeFreq: "monthly"}}, testPosts()))
+	if err != nil {
+		t.Fatalf("URLSet() error = %v", err)
+	}
+
+	body := string(out)
+	for _, want := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">`,
+		"<loc>https://example.com/posts/newer-post</loc>",
+		"<lastmod>2024-01-24T10:00:00Z</lastmod>",
+		"<changefreq>monthly</changefreq>",
+		"<image:loc>https://example.com/static/images/banner.png</image:loc>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("URLSet() missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "draft-post") {
+		t.Error("URLSet() should not list drafts")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	urls := make([]URL, MaxURLs+1)
+	for i := range urls {
+		urls[i] = URL{Loc: fmt.Sprintf("https://example.com/posts/%d", i), LastMod: base}
+	}
+	urls[MaxURLs].LastMod = base.AddDate(0, 1, 0)
+
+	if got := Pages(len(urls)); got != 2 {
+		t.Fatalf("Pages() = %d, want 2", got)
+	}
+	if got := Pages(MaxURLs); got != 1 {
+		t.Errorf("Pages(MaxURLs) = %d, want 1", got)
+	}
+
+	chunk, ok := Chunk(urls, 2)
+	if !ok || len(chunk) != 1 {
+		t.Errorf("Chunk(2) = %d urls, %v, want 1 url", len(chunk), ok)
+	}
+	if _, ok := Chunk(urls, 3); ok {
+		t.Error("Chunk(3) should not exist")
+	}
+
+	out, err := Index(urls, func(page int) string {
+		return fmt.Sprintf("https://example.com/sitemaps/%d.xml", page)
+	})
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+
+	body := string(out)
+	for _, want := range []string{
+		"<sitemapindex",
+		"<loc>https://example.com/sitemaps/1.xml</loc>",
+		"<lastmod>2024-01-01T00:00:00Z</lastmod>",
+		"<loc>https://example.com/sitemaps/2.xml</loc>",
+		"<lastmod>2024-02-01T00:00:00Z</lastmod>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Index() missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestRobots(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  RobotsConfig
+		want string
+	}{
+		{
+			name: "Allow all",
+			cfg:  RobotsConfig{SitemapURL: "https://example.com/sitemap.xml"},
+			want: "User-agent: *\nDisallow:\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			name: "Disallowed paths",
+			cfg:  RobotsConfig{Disallow: []string{"/admin", " /preview "}, SitemapURL: "https://example.com/sitemap.xml"},
+			want: "User-agent: *\nDisallow: /admin\nDisallow: /preview\n\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			name: "Disallow all",
+			cfg:  RobotsConfig{DisallowAll: true, Disallow: []string{"/admin"}, SitemapURL: "https://example.com/sitemap.xml"},
+			want: "User-agent: *\nDisallow: /\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Robots(tt.cfg); got != tt.want {
+				t.Errorf("Robots() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}